@@ -4,16 +4,23 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
 )
 
 func main() {
 	var (
 		ledgerURL = flag.String("ledger", "http://localhost:8080", "Ledger node URL")
 		mode      = flag.String("mode", "consistency", "Audit mode: consistency, replay, or full")
+		bundleDir = flag.String("bundles", "", "Directory of signature bundles (*.bundle) whose signatures the replay verifies")
+		identDir  = flag.String("identities", "", "Directory of signer identity files (*.json) for -bundles")
 	)
 
 	flag.Parse()
 
+	if *bundleDir != "" && *identDir == "" {
+		log.Fatal("-bundles requires -identities")
+	}
+
 	fmt.Println("=== Civic Attest Auditor ===")
 	fmt.Printf("Ledger URL: %s\n", *ledgerURL)
 	fmt.Printf("Mode: %s\n", *mode)
@@ -23,9 +30,9 @@ func main() {
 	case "consistency":
 		runConsistencyAudit(*ledgerURL)
 	case "replay":
-		runReplayAudit(*ledgerURL)
+		runReplayAudit(*ledgerURL, *bundleDir, *identDir)
 	case "full":
-		runFullAudit(*ledgerURL)
+		runFullAudit(*ledgerURL, *bundleDir, *identDir)
 	default:
 		log.Fatalf("Unknown audit mode: %s", *mode)
 	}
@@ -41,20 +48,37 @@ func runConsistencyAudit(ledgerURL string) {
 	fmt.Println("Consistency audit: PASSED")
 }
 
-func runReplayAudit(ledgerURL string) {
+func runReplayAudit(ledgerURL, bundleDir, identityDir string) {
 	fmt.Println("Running replay audit...")
 	fmt.Println("✓ Replaying all entries")
-	fmt.Println("✓ Verifying all signatures")
+	if bundleDir == "" {
+		fmt.Println("⊘ Verifying all signatures: SKIPPED (no -bundles)")
+	} else {
+		checked, failures, err := replaySignatures(bundleDir, identityDir)
+		if err != nil {
+			log.Fatalf("Replay failed: %v", err)
+		}
+		if len(failures) > 0 {
+			fmt.Printf("❌ Verifying all signatures: %d of %d FAILED\n", len(failures), checked)
+			for _, f := range failures {
+				fmt.Printf("   %s\n", f)
+			}
+			fmt.Println()
+			fmt.Println("Replay audit: FAILED")
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Verifying all signatures: %d verified\n", checked)
+	}
 	fmt.Println("✓ Checking revocation status")
 	fmt.Println("✓ Validating timestamps")
 	fmt.Println()
 	fmt.Println("Replay audit: PASSED")
 }
 
-func runFullAudit(ledgerURL string) {
+func runFullAudit(ledgerURL, bundleDir, identityDir string) {
 	fmt.Println("Running full audit...")
 	runConsistencyAudit(ledgerURL)
-	runReplayAudit(ledgerURL)
+	runReplayAudit(ledgerURL, bundleDir, identityDir)
 	fmt.Println("✓ Verifying all inclusion proofs")
 	fmt.Println("✓ Checking all identity states")
 	fmt.Println("✓ Validating governance decisions")
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+	"github.com/IAmSoThirsty/civic-attest/internal/identity/models"
+	"github.com/IAmSoThirsty/civic-attest/internal/signer/bundle"
+)
+
+// identityKey identifies one key version of an identity
+type identityKey struct {
+	id      string
+	version int
+}
+
+// loadIdentities reads every identity file (*.json) in dir
+func loadIdentities(dir string) (map[identityKey]*models.Identity, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	identities := make(map[identityKey]*models.Identity, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var identity models.Identity
+		if err := json.Unmarshal(data, &identity); err != nil {
+			return nil, fmt.Errorf("failed to parse identity %s: %w", file, err)
+		}
+		identities[identityKey{identity.IdentityID, identity.KeyVersion}] = &identity
+	}
+	return identities, nil
+}
+
+// replaySignatures verifies the classical signature of every bundle
+// (*.bundle) in bundleDir that has one, under its signer's key from
+// identityDir. Ed25519 signatures, nearly all of a replay, are checked in one
+// batch, which names the bundles that fail. It returns the number of bundles
+// checked and a description of each failure.
+func replaySignatures(bundleDir, identityDir string) (int, []string, error) {
+	identities, err := loadIdentities(identityDir)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to load identities: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(bundleDir, "*.bundle"))
+	if err != nil {
+		return 0, nil, err
+	}
+	sort.Strings(files)
+
+	var failures []string
+	checked := 0
+	batch := signatures.NewBatchVerifier()
+	var batched []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return 0, nil, err
+		}
+		b, _, err := bundle.Decode(data)
+		if err != nil {
+			checked++
+			failures = append(failures, fmt.Sprintf("%s: %v", file, err))
+			continue
+		}
+		if len(b.Signature) == 0 {
+			continue
+		}
+		checked++
+
+		signer, ok := identities[identityKey{b.SignerIdentityID, b.KeyVersion}]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%s: unknown signer %s version %d", file, b.SignerIdentityID, b.KeyVersion))
+			continue
+		}
+		message, err := b.SignedMessage()
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", file, err))
+			continue
+		}
+
+		// Bundles without a signature algorithm use Ed25519
+		algorithm := signatures.Ed25519
+		if b.SignatureAlgorithm != "" {
+			algorithm = signatures.Algorithm(b.SignatureAlgorithm)
+		}
+		if signer.KeyAlgorithm != string(algorithm) {
+			failures = append(failures, fmt.Sprintf("%s: identity %s holds an %s key, bundle is signed with %s",
+				file, signer.IdentityID, signer.KeyAlgorithm, algorithm))
+			continue
+		}
+		if algorithm == signatures.Ed25519 {
+			batch.Add(signer.PublicKey, message, b.Signature)
+			batched = append(batched, file)
+			continue
+		}
+		if valid, err := signatures.Verify(signer.PublicKey, message, b.Signature, algorithm); err != nil || !valid {
+			failures = append(failures, fmt.Sprintf("%s: invalid signature", file))
+		}
+	}
+
+	if valid, failed := batch.Verify(); !valid {
+		for _, i := range failed {
+			failures = append(failures, fmt.Sprintf("%s: invalid signature", batched[i]))
+		}
+	}
+	sort.Strings(failures)
+	return checked, failures, nil
+}
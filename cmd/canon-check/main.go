@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/canonical"
+)
+
+const usage = `Usage:
+  canon-check validate [-format CBOR|JSON] FILE...
+  canon-check compare [-format CBOR|JSON] FILE1 FILE2
+  canon-check vectors [-file VECTORS] [-export DIR] [-dir DIR] [-v]
+
+The format is detected from the .cbor or .json extension unless -format is given.
+`
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var ok bool
+	switch os.Args[1] {
+	case "validate":
+		ok = validate(os.Args[2:])
+	case "compare":
+		ok = compare(os.Args[2:])
+	case "vectors":
+		ok = runVectors(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// validate checks that each file is canonical and explains every violation
+func validate(args []string) bool {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	formatName := fs.String("format", "", "Encoding format (CBOR or JSON)")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		log.Fatal("no files given")
+	}
+
+	ok := true
+	for _, filename := range fs.Args() {
+		format, err := detectFormat(filename, *formatName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			log.Fatalf("Failed to read file: %v", err)
+		}
+
+		violations, err := canonical.CheckCanonical(data, format)
+		switch {
+		case err != nil:
+			ok = false
+			fmt.Printf("❌ %s: malformed %s: %v\n", filename, format, err)
+		case len(violations) > 0:
+			ok = false
+			fmt.Printf("❌ %s: not canonical %s\n", filename, format)
+			for _, v := range violations {
+				fmt.Printf("   %s: %s\n", location(data, v.Offset, format), v.Reason)
+				// JSON reasons quote the offending text already
+				if format == canonical.CBOR {
+					fmt.Printf("      %s\n", excerpt(data, v.Offset, format))
+				}
+			}
+		default:
+			fmt.Printf("✓ %s: canonical %s\n", filename, format)
+		}
+	}
+	return ok
+}
+
+// compare reports whether two encodings are identical and, if not, whether
+// they carry the same data and where they first differ
+func compare(args []string) bool {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	formatName := fs.String("format", "", "Encoding format (CBOR or JSON)")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		log.Fatal("two files are required")
+	}
+	names := [2]string{fs.Arg(0), fs.Arg(1)}
+	format, err := detectFormat(names[0], *formatName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var encodings, canonicalForms [2][]byte
+	for i, filename := range names {
+		if encodings[i], err = os.ReadFile(filename); err != nil {
+			log.Fatalf("Failed to read file: %v", err)
+		}
+		if canonicalForms[i], err = canonical.Recanonicalize(encodings[i], format); err != nil {
+			log.Fatalf("Failed to decode %s: %v", filename, err)
+		}
+	}
+
+	if bytes.Equal(encodings[0], encodings[1]) {
+		fmt.Printf("✓ Encodings are identical (%d bytes)\n", len(encodings[0]))
+		return true
+	}
+
+	if bytes.Equal(canonicalForms[0], canonicalForms[1]) {
+		fmt.Println("⚠ Encodings differ but carry the same data")
+		for i, filename := range names {
+			if bytes.Equal(encodings[i], canonicalForms[i]) {
+				fmt.Printf("   %s: canonical\n", filename)
+			} else {
+				fmt.Printf("   %s: not canonical\n", filename)
+			}
+		}
+		printDifference(names, encodings, format)
+	} else {
+		fmt.Println("❌ Encodings carry different data")
+		printDifference([2]string{names[0] + " (canonical)", names[1] + " (canonical)"}, canonicalForms, format)
+	}
+	return false
+}
+
+// printDifference shows where two encodings first differ
+func printDifference(names [2]string, encodings [2][]byte, format canonical.Format) {
+	offset := 0
+	for offset < len(encodings[0]) && offset < len(encodings[1]) && encodings[0][offset] == encodings[1][offset] {
+		offset++
+	}
+
+	fmt.Printf("   First difference at %s:\n", location(encodings[0], offset, format))
+	for i, name := range names {
+		fmt.Printf("      %s: %s\n", name, excerpt(encodings[i], offset, format))
+	}
+}
+
+// runVectors runs test vectors through the canonical package and, with -dir,
+// checks the encodings another encoder produced for them
+func runVectors(args []string) bool {
+	fs := flag.NewFlagSet("vectors", flag.ExitOnError)
+	file := fs.String("file", "", "Test vector file (JSON); the bundled conformance suite if empty")
+	exportDir := fs.String("export", "", "Write each vector's input to this directory, as NAME.cbor or NAME.json")
+	dir := fs.String("dir", "", "Check the canonical encodings another encoder wrote to this directory, as NAME.cbor or NAME.json")
+	verbose := fs.Bool("v", false, "List every vector, not only failures")
+	fs.Parse(args)
+
+	vectors := canonical.ConformanceVectors()
+	if *file != "" {
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			log.Fatalf("Failed to read vectors: %v", err)
+		}
+		if vectors, err = canonical.LoadVectors(data); err != nil {
+			log.Fatalf("Failed to load vectors: %v", err)
+		}
+	}
+
+	if *exportDir != "" {
+		for _, v := range vectors {
+			input, err := v.InputBytes()
+			if err != nil {
+				log.Fatalf("Vector %s: invalid input: %v", v.Name, err)
+			}
+			if err := writeVectorFile(*exportDir, v, input); err != nil {
+				log.Fatalf("Failed to export vectors: %v", err)
+			}
+		}
+		fmt.Printf("Wrote %d vector inputs to %s\n", len(vectors), *exportDir)
+		return true
+	}
+
+	passed, failed, skipped := 0, 0, 0
+	for _, v := range vectors {
+		var err error
+		if *dir == "" {
+			err = v.Check()
+		} else if v.Refused != "" {
+			// An encoder must refuse these inputs, so there is no encoding to check
+			skipped++
+			continue
+		} else {
+			err = checkVectorFile(*dir, v)
+		}
+
+		if err != nil {
+			failed++
+			fmt.Printf("❌ %s: %v\n", v.Name, err)
+			continue
+		}
+		passed++
+		if *verbose {
+			fmt.Printf("✓ %s\n", v.Name)
+		}
+	}
+
+	fmt.Printf("\n%d vectors: %d passed, %d failed", len(vectors), passed, failed)
+	if skipped > 0 {
+		fmt.Printf(", %d skipped (inputs to refuse)", skipped)
+	}
+	fmt.Println()
+	return failed == 0
+}
+
+// vectorPath returns the file of a vector in dir, named after the vector
+func vectorPath(dir string, v canonical.Vector) string {
+	return filepath.Join(dir, filepath.FromSlash(v.Name)+"."+strings.ToLower(string(v.Format)))
+}
+
+// writeVectorFile writes data to the vector's file in dir
+func writeVectorFile(dir string, v canonical.Vector, data []byte) error {
+	path := vectorPath(dir, v)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// checkVectorFile checks the encoding of a vector in dir
+func checkVectorFile(dir string, v canonical.Vector) error {
+	data, err := os.ReadFile(vectorPath(dir, v))
+	if err != nil {
+		return err
+	}
+	return v.CheckEncoding(data)
+}
+
+// detectFormat returns the format named by flag, or the one of the file extension
+func detectFormat(filename, name string) (canonical.Format, error) {
+	if name == "" {
+		name = strings.TrimPrefix(filepath.Ext(filename), ".")
+	}
+	switch strings.ToUpper(name) {
+	case "CBOR":
+		return canonical.CBOR, nil
+	case "JSON":
+		return canonical.JSON, nil
+	default:
+		return "", fmt.Errorf("cannot tell the format of %s; use -format CBOR or -format JSON", filename)
+	}
+}
+
+// location describes an offset, with the line and column for JSON
+func location(data []byte, offset int, format canonical.Format) string {
+	if format != canonical.JSON {
+		return fmt.Sprintf("offset %d", offset)
+	}
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(data[:offset], '\n')
+	return fmt.Sprintf("offset %d (line %d, column %d)", offset, line, column)
+}
+
+// excerpt shows up to 16 bytes of data from offset, in hex for CBOR
+func excerpt(data []byte, offset int, format canonical.Format) string {
+	if offset >= len(data) {
+		return "(end of data)"
+	}
+	end := offset + 16
+	if end > len(data) {
+		end = len(data)
+	}
+
+	if format == canonical.CBOR {
+		s := hex.EncodeToString(data[offset:end])
+		if end < len(data) {
+			s += "…"
+		}
+		return s
+	}
+	return fmt.Sprintf("%q", data[offset:end])
+}
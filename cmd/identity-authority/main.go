@@ -2,13 +2,16 @@ package main
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
 	"github.com/IAmSoThirsty/civic-attest/internal/identity/models"
+	"github.com/IAmSoThirsty/civic-attest/internal/identity/pki"
 )
 
 func main() {
@@ -17,6 +20,8 @@ func main() {
 		jurisdiction = flag.String("jurisdiction", "", "Jurisdiction")
 		validYears   = flag.Int("valid-years", 1, "Validity period in years")
 		output       = flag.String("output", "", "Output file for identity")
+		keyAlgo      = flag.String("algorithm", string(signatures.Ed25519), "Key algorithm (Ed25519, Ed448, ECDSA-P256 or ECDSA-P384)")
+		certificate  = flag.String("certificate", "", "Bind the identity to an existing credential: its X.509 certificate chain (PEM, leaf first)")
 	)
 
 	flag.Parse()
@@ -29,10 +34,30 @@ func main() {
 	fmt.Println("=== Identity Authority ===")
 	fmt.Println("Generating new identity...")
 
-	// Generate key pair (in production, this would be in HSM)
-	kp, err := signatures.GenerateKeyPair(signatures.Ed25519)
-	if err != nil {
-		log.Fatalf("Failed to generate key: %v", err)
+	// An identity bound to an existing credential takes its certified key;
+	// otherwise a key pair is generated (in production, this would be in HSM)
+	var kp *signatures.KeyPair
+	var chain [][]byte
+	if *certificate != "" {
+		data, err := os.ReadFile(*certificate)
+		if err != nil {
+			log.Fatalf("Failed to read certificate chain: %v", err)
+		}
+		chain, err = pki.ParseChainPEM(data)
+		if err != nil {
+			log.Fatalf("Failed to parse certificate chain: %v", err)
+		}
+		publicKey, algo, err := pki.LeafPublicKey(chain)
+		if err != nil {
+			log.Fatalf("Failed to read credential key: %v", err)
+		}
+		kp = &signatures.KeyPair{PublicKey: publicKey, Algorithm: algo}
+	} else {
+		var err error
+		kp, err = signatures.GenerateKeyPair(signatures.Algorithm(*keyAlgo))
+		if err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
 	}
 
 	// Create identity
@@ -40,15 +65,16 @@ func main() {
 	validTo := now.AddDate(*validYears, 0, 0)
 
 	identity := &models.Identity{
-		OfficeID:     *officeID,
-		Jurisdiction: *jurisdiction,
-		PublicKey:    kp.PublicKey,
-		KeyVersion:   1,
-		ValidFrom:    now,
-		ValidTo:      validTo,
-		KeyAlgorithm: string(signatures.Ed25519),
-		Status:       models.StatusActive,
-		IdentityID:   fmt.Sprintf("%s-%s-v1", *officeID, *jurisdiction),
+		OfficeID:         *officeID,
+		Jurisdiction:     *jurisdiction,
+		PublicKey:        kp.PublicKey,
+		KeyVersion:       1,
+		ValidFrom:        now,
+		ValidTo:          validTo,
+		KeyAlgorithm:     string(kp.Algorithm),
+		Status:           models.StatusActive,
+		IdentityID:       fmt.Sprintf("%s-%s-v1", *officeID, *jurisdiction),
+		CertificateChain: chain,
 	}
 
 	fmt.Printf("\nIdentity created:\n")
@@ -60,9 +86,19 @@ func main() {
 	fmt.Printf("  Valid To: %s\n", identity.ValidTo.Format(time.RFC3339))
 	fmt.Printf("  Algorithm: %s\n", identity.KeyAlgorithm)
 	fmt.Printf("  Status: %s\n", identity.Status)
+	if len(chain) > 0 {
+		fmt.Printf("  Certificate Chain: %d certificates\n", len(chain))
+	}
 
 	if *output != "" {
-		fmt.Printf("\nIdentity data would be written to: %s\n", *output)
+		data, err := json.MarshalIndent(identity, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode identity: %v", err)
+		}
+		if err := os.WriteFile(*output, data, 0644); err != nil {
+			log.Fatalf("Failed to write identity: %v", err)
+		}
+		fmt.Printf("\nIdentity written to: %s\n", *output)
 	}
 
 	fmt.Println("\n⚠ Note: In production, keys would be generated in HSM")
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/keyfile"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/shamir"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+	"github.com/IAmSoThirsty/civic-attest/internal/identity/models"
+	"github.com/IAmSoThirsty/civic-attest/internal/signer/backend"
+)
+
+// trusteeFile returns the path of a trustee's file in dir
+func trusteeFile(dir, trustee, suffix string) string {
+	return filepath.Join(dir, filepath.Base(trustee)+suffix)
+}
+
+// backupKey splits the private key among the trustees, any threshold of whom
+// can restore it, and seals each share to its trustee's public key. It
+// returns the share commitments to publish in the ceremony record.
+func backupKey(privateKey []byte, algo signatures.Algorithm, trustees []string, threshold int, keyDir, outDir string) (map[string][]byte, error) {
+	der, err := signatures.MarshalPrivateKey(privateKey, algo)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(der)
+
+	shares, err := shamir.Split(der, threshold, len(trustees))
+	if err != nil {
+		return nil, fmt.Errorf("failed to split key: %w", err)
+	}
+
+	if err := os.MkdirAll(outDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	commitments := make(map[string][]byte, len(trustees))
+	for i, trustee := range trustees {
+		publicKey, err := readHexFile(trusteeFile(keyDir, trustee, ".pub"))
+		if err != nil {
+			return nil, fmt.Errorf("trustee %s: %w", trustee, err)
+		}
+		sealed, err := shamir.Seal(shares[i], publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("trustee %s: %w", trustee, err)
+		}
+		data, err := json.MarshalIndent(sealed, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode share: %w", err)
+		}
+		if err := os.WriteFile(trusteeFile(outDir, trustee, ".backup.json"), data, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write share: %w", err)
+		}
+
+		commitments[trustee] = shamir.Commit(shares[i])
+		clear(shares[i].Value)
+	}
+	return commitments, nil
+}
+
+// trusteeKey generates a trustee's key pair for receiving backup shares
+func trusteeKey(args []string) {
+	fs := flag.NewFlagSet("trustee-key", flag.ExitOnError)
+	trustee := fs.String("trustee", "", "Trustee ID")
+	outDir := fs.String("out", ".", "Directory to write <trustee>.key and <trustee>.pub to")
+	fs.Parse(args)
+
+	if *trustee == "" {
+		fs.Usage()
+		log.Fatal("trustee is required")
+	}
+
+	privateKey, publicKey, err := shamir.GenerateTrusteeKey()
+	if err != nil {
+		log.Fatalf("Failed to generate trustee key: %v", err)
+	}
+
+	keyPath := trusteeFile(*outDir, *trustee, ".key")
+	if err := writeNewFile(keyPath, []byte(hex.EncodeToString(privateKey))); err != nil {
+		log.Fatalf("Failed to write trustee key: %v", err)
+	}
+
+	pubPath := trusteeFile(*outDir, *trustee, ".pub")
+	if err := os.WriteFile(pubPath, []byte(hex.EncodeToString(publicKey)), 0644); err != nil {
+		log.Fatalf("Failed to write trustee public key: %v", err)
+	}
+
+	fmt.Printf("✓ Trustee key generated for %s\n", *trustee)
+	fmt.Printf("  Private key: %s (keep offline)\n", keyPath)
+	fmt.Printf("  Public key:  %s\n", pubPath)
+}
+
+// recoverKey rebuilds a ceremony key from a quorum of backup shares
+func recoverKey(args []string) {
+	fs := flag.NewFlagSet("recover", flag.ExitOnError)
+	recordPath := fs.String("record", "", "Ceremony record of the key")
+	backupDir := fs.String("backup-dir", "", "Directory of sealed backup shares")
+	keyDir := fs.String("trustee-keys", "", "Directory of the present trustees' private keys (<trustee>.key)")
+	keyURI := fs.String("key-uri", "", "Key file to restore the key to (file:PATH); only checked if empty")
+	identityID := fs.String("identity", "", "Identity ID to record in the restored key file")
+	keyVersion := fs.Int("key-version", 0, "Identity key version to record in the restored key file")
+	fs.Parse(args)
+
+	if *recordPath == "" || *backupDir == "" || *keyDir == "" {
+		fs.Usage()
+		log.Fatal("record, backup-dir and trustee-keys are required")
+	}
+
+	fmt.Println("=== Key Recovery ===")
+
+	data, err := os.ReadFile(*recordPath)
+	if err != nil {
+		log.Fatalf("Failed to read ceremony record: %v", err)
+	}
+	var ceremony models.KeyCeremonyRecord
+	if err := json.Unmarshal(data, &ceremony); err != nil {
+		log.Fatalf("Failed to parse ceremony record: %v", err)
+	}
+	if len(ceremony.ShareCommitments) == 0 {
+		log.Fatalf("Ceremony %s has no backup shares", ceremony.CeremonyID)
+	}
+	fmt.Printf("Ceremony: %s (quorum %d of %d)\n", ceremony.CeremonyID, ceremony.QuorumSize, ceremony.TotalTrustees)
+
+	// Each present trustee opens their share, which must match the recorded commitment
+	var shares []shamir.Share
+	for _, trustee := range ceremony.Trustees {
+		commitment, ok := ceremony.ShareCommitments[trustee]
+		if !ok {
+			continue
+		}
+		share, err := openShare(*backupDir, *keyDir, trustee)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			fmt.Printf("  ✗ %s: %v\n", trustee, err)
+			continue
+		}
+		if !bytes.Equal(shamir.Commit(share), commitment) {
+			fmt.Printf("  ✗ %s: share does not match the ceremony record\n", trustee)
+			continue
+		}
+		fmt.Printf("  ✓ %s\n", trustee)
+		shares = append(shares, share)
+	}
+
+	if len(shares) < ceremony.QuorumSize {
+		log.Fatalf("Only %d valid shares, quorum is %d", len(shares), ceremony.QuorumSize)
+	}
+
+	der, err := shamir.Combine(shares)
+	if err != nil {
+		log.Fatalf("Failed to combine shares: %v", err)
+	}
+	privateKey, algo, err := signatures.ParsePrivateKey(der)
+	if err != nil {
+		log.Fatalf("Recovered key is malformed: %v", err)
+	}
+	publicKey, err := signatures.PublicKeyFromPrivate(privateKey, algo)
+	if err != nil {
+		log.Fatalf("Recovered key is malformed: %v", err)
+	}
+
+	publicKeyHash := sha256.Sum256(publicKey)
+	if !bytes.Equal(publicKeyHash[:], ceremony.PublicKeyHash) {
+		log.Fatalf("Recovered key does not match the ceremony's public key hash")
+	}
+	fmt.Printf("✓ Key recovered (%s)\n", algo)
+	fmt.Printf("  Public Key: %s\n", hex.EncodeToString(publicKey))
+
+	if *keyURI != "" {
+		key := &keyfile.Key{PrivateKey: privateKey, Algorithm: algo, KeyVersion: *keyVersion, IdentityID: *identityID}
+		if _, err := backend.Import(*keyURI, key); err != nil {
+			log.Fatalf("Failed to restore key: %v", err)
+		}
+		fmt.Printf("  Restored to: %s\n", *keyURI)
+	}
+}
+
+// openShare decrypts a trustee's sealed share with their private key
+func openShare(backupDir, keyDir, trustee string) (shamir.Share, error) {
+	data, err := os.ReadFile(trusteeFile(backupDir, trustee, ".backup.json"))
+	if err != nil {
+		return shamir.Share{}, err
+	}
+	privateKey, err := readHexFile(trusteeFile(keyDir, trustee, ".key"))
+	if err != nil {
+		return shamir.Share{}, err
+	}
+
+	var sealed shamir.SealedShare
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return shamir.Share{}, fmt.Errorf("failed to parse sealed share: %w", err)
+	}
+	return shamir.Open(&sealed, privateKey)
+}
+
+// readHexFile reads a hex-encoded key file
+func readHexFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/frost"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+)
+
+// ThresholdScheme names the threshold scheme recorded in the ceremony record
+const ThresholdScheme = "FROST(Ed25519, SHA-512)"
+
+// runDKG runs the distributed key generation for every trustee. In a real
+// ceremony each trustee runs their participant on their own device and the
+// round 2 packages travel over confidential channels; here all trustees are
+// simulated on the ceremony workstation.
+func runDKG(trustees []string, threshold int) (map[frost.Identifier]*frost.KeyShare, error) {
+	participants := make(map[frost.Identifier]*frost.DKGParticipant, len(trustees))
+	round1 := make([]*frost.Round1Package, 0, len(trustees))
+	for i := range trustees {
+		id := frost.Identifier(i + 1)
+		p, pkg, err := frost.NewDKGParticipant(id, threshold, len(trustees))
+		if err != nil {
+			return nil, fmt.Errorf("failed to start DKG: %w", err)
+		}
+		participants[id] = p
+		round1 = append(round1, pkg)
+	}
+
+	inbox := make(map[frost.Identifier][]*frost.Round2Package, len(trustees))
+	for id, p := range participants {
+		out, err := p.Round2(round1)
+		if err != nil {
+			return nil, fmt.Errorf("DKG round 2 failed for trustee %s: %w", trustees[id-1], err)
+		}
+		for _, pkg := range out {
+			inbox[pkg.To] = append(inbox[pkg.To], pkg)
+		}
+	}
+
+	shares := make(map[frost.Identifier]*frost.KeyShare, len(trustees))
+	for id, p := range participants {
+		share, err := p.Finalize(inbox[id])
+		if err != nil {
+			return nil, fmt.Errorf("DKG failed for trustee %s: %w", trustees[id-1], err)
+		}
+		shares[id] = share
+	}
+	return shares, nil
+}
+
+// writeShares writes each trustee's key share to dir as <trustee>.share.json
+func writeShares(dir string, trustees []string, shares map[frost.Identifier]*frost.KeyShare) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create share directory: %w", err)
+	}
+	for i, trustee := range trustees {
+		data, err := json.MarshalIndent(shares[frost.Identifier(i+1)], "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode share: %w", err)
+		}
+		path := filepath.Join(dir, filepath.Base(trustee)+".share.json")
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return fmt.Errorf("failed to write share: %w", err)
+		}
+	}
+	return nil
+}
+
+// quorumSigner signs by running both FROST rounds with a quorum of key shares
+type quorumSigner struct {
+	shares []*frost.KeyShare
+}
+
+// PublicKey returns the group public key
+func (s *quorumSigner) PublicKey() []byte {
+	return s.shares[0].GroupPublicKey
+}
+
+// Algorithm returns Ed25519, as FROST signatures are plain Ed25519 signatures
+func (s *quorumSigner) Algorithm() signatures.Algorithm {
+	return signatures.Ed25519
+}
+
+// Sign collects commitments and signature shares from the quorum and aggregates them
+func (s *quorumSigner) Sign(_ context.Context, message []byte) ([]byte, error) {
+	nonces := make([]*frost.Nonces, len(s.shares))
+	commitments := make([]*frost.Commitment, len(s.shares))
+	for i, share := range s.shares {
+		n, c, err := frost.Commit(share)
+		if err != nil {
+			return nil, err
+		}
+		nonces[i], commitments[i] = n, c
+	}
+
+	sigShares := make([]*frost.SignatureShare, len(s.shares))
+	for i, share := range s.shares {
+		z, err := frost.Sign(share, nonces[i], message, commitments)
+		if err != nil {
+			return nil, fmt.Errorf("participant %d failed to sign: %w", share.ID, err)
+		}
+		sigShares[i] = z
+	}
+
+	return frost.Aggregate(&s.shares[0].PublicKeyPackage, message, commitments, sigShares)
+}
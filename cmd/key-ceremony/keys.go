@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/keyfile"
+	"github.com/IAmSoThirsty/civic-attest/internal/signer/backend"
+)
+
+// importKey encrypts a PKCS#8 PEM private key into a key file recording the
+// identity and key version it belongs to
+func importKey(args []string) {
+	fs := flag.NewFlagSet("import-key", flag.ExitOnError)
+	in := fs.String("in", "", "PKCS#8 PEM private key to import")
+	out := fs.String("out", "", "Key file to write")
+	identityID := fs.String("identity", "", "Identity ID the key belongs to")
+	keyVersion := fs.Int("key-version", 1, "Identity key version of the key")
+	recipient := fs.String("recipient", "", "Encrypt to this X25519 public key file (hex) instead of the passphrase in "+backend.PassphraseEnv)
+	kdf := fs.String("kdf", string(keyfile.KDFScrypt), "Passphrase key derivation function (scrypt or argon2id)")
+	fs.Parse(args)
+
+	if *in == "" || *out == "" || *identityID == "" {
+		fs.Usage()
+		log.Fatal("in, out and identity are required")
+	}
+
+	pemData, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatalf("Failed to read key: %v", err)
+	}
+	key, err := keyfile.ImportPEM(pemData)
+	if err != nil {
+		log.Fatalf("Failed to import key: %v", err)
+	}
+	key.IdentityID = *identityID
+	key.KeyVersion = *keyVersion
+
+	var data []byte
+	if *recipient != "" {
+		publicKey, err := readHexFile(*recipient)
+		if err != nil {
+			log.Fatalf("Failed to read recipient key: %v", err)
+		}
+		data, err = keyfile.EncryptTo(key, publicKey)
+		if err != nil {
+			log.Fatalf("Failed to encrypt key: %v", err)
+		}
+	} else {
+		passphrase := os.Getenv(backend.PassphraseEnv)
+		if passphrase == "" {
+			log.Fatalf("No passphrase given (set %s)", backend.PassphraseEnv)
+		}
+		data, err = keyfile.Encrypt(key, passphrase, keyfile.KDF(*kdf))
+		if err != nil {
+			log.Fatalf("Failed to encrypt key: %v", err)
+		}
+	}
+
+	if err := writeNewFile(*out, data); err != nil {
+		log.Fatalf("Failed to write key file: %v", err)
+	}
+	fmt.Printf("✓ %s key for %s (version %d) written to %s\n", key.Algorithm, key.IdentityID, key.KeyVersion, *out)
+}
+
+// exportKey decrypts a key file into unencrypted PKCS#8 PEM
+func exportKey(args []string) {
+	fs := flag.NewFlagSet("export-key", flag.ExitOnError)
+	in := fs.String("in", "", "Key file to export")
+	out := fs.String("out", "", "PKCS#8 PEM file to write")
+	fs.Parse(args)
+
+	if *in == "" || *out == "" {
+		fs.Usage()
+		log.Fatal("in and out are required")
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatalf("Failed to read key file: %v", err)
+	}
+
+	var key *keyfile.Key
+	if keyfile.IsRecipientEncrypted(data) {
+		identity, err := readHexFile(os.Getenv(backend.IdentityFileEnv))
+		if err != nil {
+			log.Fatalf("Failed to read identity key (set %s): %v", backend.IdentityFileEnv, err)
+		}
+		key, err = keyfile.DecryptWith(data, identity)
+		if err != nil {
+			log.Fatalf("Failed to decrypt key file: %v", err)
+		}
+	} else {
+		key, err = keyfile.Decrypt(data, os.Getenv(backend.PassphraseEnv))
+		if err != nil {
+			log.Fatalf("Failed to decrypt key file: %v", err)
+		}
+	}
+
+	pemData, err := keyfile.ExportPEM(key)
+	if err != nil {
+		log.Fatalf("Failed to export key: %v", err)
+	}
+	if err := writeNewFile(*out, pemData); err != nil {
+		log.Fatalf("Failed to write key: %v", err)
+	}
+	fmt.Printf("✓ %s key exported to %s (unencrypted; handle accordingly)\n", key.Algorithm, *out)
+}
+
+// writeNewFile writes secret data to a file that must not already exist
+func writeNewFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -10,16 +13,43 @@ import (
 	"strings"
 	"time"
 
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/frost"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/keyfile"
 	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
 	"github.com/IAmSoThirsty/civic-attest/internal/identity/models"
+	"github.com/IAmSoThirsty/civic-attest/internal/signer/backend"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "trustee-key":
+			trusteeKey(os.Args[2:])
+			return
+		case "recover":
+			recoverKey(os.Args[2:])
+			return
+		case "import-key":
+			importKey(os.Args[2:])
+			return
+		case "export-key":
+			exportKey(os.Args[2:])
+			return
+		}
+	}
+
 	var (
 		quorumSize    = flag.Int("quorum", 3, "Quorum size")
 		totalTrustees = flag.Int("trustees", 5, "Total trustees")
 		officeID      = flag.String("office", "", "Office ID for new key")
 		jurisdiction  = flag.String("jurisdiction", "", "Jurisdiction")
+		keyAlgo       = flag.String("algorithm", string(signatures.Ed25519), "Key algorithm (Ed25519, Ed448, ECDSA-P256 or ECDSA-P384)")
+		keyURI        = flag.String("key-uri", "", "Where to generate the key: file:PATH, pkcs11:... or kms:BASE-URL (simulated in memory if empty)")
+		threshold     = flag.Bool("frost", false, "Share the key among all trustees with FROST; any quorum can sign")
+		shareDir      = flag.String("share-dir", "", "Directory to write each trustee's FROST key share to")
+		backupDir     = flag.String("backup-dir", "", "Split a backup of the key among all trustees into this directory")
+		trusteeKeys   = flag.String("trustee-keys", "", "Directory of trustee public keys (<trustee>.pub) to seal backup shares to")
+		recordPath    = flag.String("record", "", "Write the ceremony record to this file")
 	)
 
 	flag.Parse()
@@ -28,6 +58,20 @@ func main() {
 		flag.Usage()
 		log.Fatal("office and jurisdiction are required")
 	}
+	if *threshold && (*keyURI != "" || *keyAlgo != string(signatures.Ed25519)) {
+		log.Fatal("-frost generates an Ed25519 key and cannot be combined with -key-uri")
+	}
+	if *backupDir != "" {
+		if *trusteeKeys == "" {
+			log.Fatal("-backup-dir requires -trustee-keys")
+		}
+		// Shares can only be made of a key that exists outside an HSM or KMS
+		if *threshold || (*keyURI != "" && !strings.HasPrefix(*keyURI, "file:")) {
+			log.Fatal("-backup-dir requires a key generated in memory or in a key file")
+		}
+	}
+
+	identityID := fmt.Sprintf("%s-%s-v1", *officeID, *jurisdiction)
 
 	fmt.Println("╔═══════════════════════════════════════════╗")
 	fmt.Println("║   CIVIC ATTEST KEY CEREMONY PROTOCOL      ║")
@@ -42,8 +86,13 @@ func main() {
 
 	// Step 1: Gather trustees
 	fmt.Println("=== Step 1: Trustee Assembly ===")
+	// A threshold key or backup gives every trustee a share, so all of them take part
+	assembled := *quorumSize
+	if *threshold || *backupDir != "" {
+		assembled = *totalTrustees
+	}
 	trustees := make([]string, 0)
-	for i := 0; i < *quorumSize; i++ {
+	for i := 0; i < assembled; i++ {
 		fmt.Printf("Enter trustee %d ID: ", i+1)
 		trusteeID, _ := reader.ReadString('\n')
 		trusteeID = strings.TrimSpace(trusteeID)
@@ -57,36 +106,140 @@ func main() {
 
 	// Step 2: Generate key
 	fmt.Println("\n=== Step 2: Key Generation ===")
-	fmt.Println("Generating key in HSM (simulated)...")
+	var signer signatures.Signer
+	var shares map[frost.Identifier]*frost.KeyShare
+	var generated *keyfile.Key
+	if *threshold {
+		fmt.Println("Running distributed key generation among trustees...")
+
+		var err error
+		shares, err = runDKG(trustees, *quorumSize)
+		if err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+
+		// The proof of possession needs only a quorum
+		quorum := &quorumSigner{}
+		for id := frost.Identifier(1); int(id) <= *quorumSize; id++ {
+			quorum.shares = append(quorum.shares, shares[id])
+		}
+		signer = quorum
+
+		if *shareDir != "" {
+			if err := writeShares(*shareDir, trustees, shares); err != nil {
+				log.Fatalf("Failed to distribute key shares: %v", err)
+			}
+			fmt.Printf("  Key shares written to %s\n", *shareDir)
+		}
+	} else if *backupDir != "" || strings.HasPrefix(*keyURI, "file:") {
+		// Key files record the identity the key belongs to
+		fmt.Println("Generating key...")
+
+		kp, err := signatures.GenerateKeyPair(signatures.Algorithm(*keyAlgo))
+		if err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+		generated = &keyfile.Key{PrivateKey: kp.PrivateKey, Algorithm: kp.Algorithm, KeyVersion: 1, IdentityID: identityID}
+		if *keyURI != "" {
+			signer, err = backend.Import(*keyURI, generated)
+			fmt.Printf("  Key URI: %s\n", *keyURI)
+		} else {
+			signer, err = signatures.NewKeySigner(generated.PrivateKey, generated.Algorithm)
+		}
+		if err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+	} else if *keyURI == "" {
+		fmt.Println("Generating key in HSM (simulated)...")
+
+		kp, err := signatures.GenerateKeyPair(signatures.Algorithm(*keyAlgo))
+		if err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+		if signer, err = signatures.NewKeySigner(kp.PrivateKey, kp.Algorithm); err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+	} else {
+		fmt.Println("Generating key in signer backend...")
 
-	kp, err := signatures.GenerateKeyPair(signatures.Ed25519)
+		var uri string
+		var err error
+		signer, uri, err = backend.Generate(context.Background(), *keyURI, signatures.Algorithm(*keyAlgo))
+		if err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+		fmt.Printf("  Key URI: %s\n", uri)
+	}
+
+	// Proof of possession: the new key must sign before it is bound to an identity
+	challenge := []byte(fmt.Sprintf("civic-attest key ceremony %s %s", *officeID, *jurisdiction))
+	sig, err := signer.Sign(context.Background(), challenge)
 	if err != nil {
-		log.Fatalf("Failed to generate key: %v", err)
+		log.Fatalf("Key failed to sign: %v", err)
+	}
+	if valid, err := signatures.Verify(signer.PublicKey(), challenge, sig, signer.Algorithm()); err != nil || !valid {
+		log.Fatalf("Key failed proof of possession")
 	}
 
-	pubKeyHex := hex.EncodeToString(kp.PublicKey)
+	publicKey := signer.PublicKey()
+	pubKeyHex := hex.EncodeToString(publicKey)
 	fmt.Printf("✓ Key generated\n")
 	fmt.Printf("  Public Key: %s\n", pubKeyHex[:32])
 	fmt.Printf("              %s...\n", pubKeyHex[32:64])
 
+	var shareCommitments map[string][]byte
+	if *backupDir != "" {
+		fmt.Println("Splitting key backup among trustees...")
+
+		shareCommitments, err = backupKey(generated.PrivateKey, generated.Algorithm, trustees, *quorumSize, *trusteeKeys, *backupDir)
+		if err != nil {
+			log.Fatalf("Failed to back up key: %v", err)
+		}
+		clear(generated.PrivateKey)
+		fmt.Printf("✓ Backup shares sealed to %d trustees in %s (any %d recover the key)\n", len(trustees), *backupDir, *quorumSize)
+	}
+
 	// Step 3: Record ceremony
 	fmt.Println("\n=== Step 3: Ceremony Recording ===")
 	fmt.Println("Recording ceremony (audio/video)...")
 
+	publicKeyHash := sha256.Sum256(publicKey)
 	ceremony := &models.KeyCeremonyRecord{
-		CeremonyID:    fmt.Sprintf("ceremony-%s-%d", *officeID, time.Now().Unix()),
-		Timestamp:     time.Now().UTC(),
-		Trustees:      trustees,
-		QuorumSize:    *quorumSize,
-		TotalTrustees: *totalTrustees,
-		RecordingHash: []byte("recording-hash-placeholder"),
-		PublicKeyHash: kp.PublicKey[:32], // First 32 bytes as hash
+		CeremonyID:       fmt.Sprintf("ceremony-%s-%d", *officeID, time.Now().Unix()),
+		Timestamp:        time.Now().UTC(),
+		Trustees:         trustees,
+		QuorumSize:       *quorumSize,
+		TotalTrustees:    *totalTrustees,
+		RecordingHash:    []byte("recording-hash-placeholder"),
+		PublicKeyHash:    publicKeyHash[:],
+		ShareCommitments: shareCommitments,
+	}
+	if *threshold {
+		ceremony.ThresholdScheme = ThresholdScheme
+		ceremony.VerifyingShares = make(map[string][]byte, len(trustees))
+		for i, t := range trustees {
+			ceremony.VerifyingShares[t] = shares[frost.Identifier(i+1)].VerifyingShares[frost.Identifier(i+1)]
+		}
 	}
 
 	fmt.Printf("✓ Ceremony recorded\n")
 	fmt.Printf("  Ceremony ID: %s\n", ceremony.CeremonyID)
+	if ceremony.ThresholdScheme != "" {
+		fmt.Printf("  Threshold: %s, %d of %d\n", ceremony.ThresholdScheme, ceremony.QuorumSize, ceremony.TotalTrustees)
+	}
 	fmt.Printf("  Recording Hash: %s\n", hex.EncodeToString(ceremony.RecordingHash))
 
+	if *recordPath != "" {
+		data, err := json.MarshalIndent(ceremony, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode ceremony record: %v", err)
+		}
+		if err := os.WriteFile(*recordPath, data, 0644); err != nil {
+			log.Fatalf("Failed to write ceremony record: %v", err)
+		}
+		fmt.Printf("  Record: %s\n", *recordPath)
+	}
+
 	// Step 4: Broadcast and ledger append
 	fmt.Println("\n=== Step 4: Public Broadcast ===")
 	fmt.Println("Broadcasting ceremony hash...")
@@ -102,13 +255,13 @@ func main() {
 	identity := &models.Identity{
 		OfficeID:     *officeID,
 		Jurisdiction: *jurisdiction,
-		PublicKey:    kp.PublicKey,
+		PublicKey:    publicKey,
 		KeyVersion:   1,
 		ValidFrom:    now,
 		ValidTo:      now.AddDate(1, 0, 0),
-		KeyAlgorithm: string(signatures.Ed25519),
+		KeyAlgorithm: string(signer.Algorithm()),
 		Status:       models.StatusActive,
-		IdentityID:   fmt.Sprintf("%s-%s-v1", *officeID, *jurisdiction),
+		IdentityID:   identityID,
 	}
 
 	fmt.Printf("✓ Identity created: %s\n", identity.IdentityID)
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/signer/backend"
+)
+
+// kms-server is a local stand-in for a remote KMS. Keys live in memory and are
+// lost on exit, so it is only meant for development and integration tests.
+func main() {
+	port := flag.String("port", "8200", "Port to listen on")
+	flag.Parse()
+
+	token := os.Getenv(backend.KMSTokenEnv)
+	if token == "" {
+		fmt.Printf("Warning: %s not set, requests are not authenticated\n", backend.KMSTokenEnv)
+	}
+
+	addr := fmt.Sprintf(":%s", *port)
+	fmt.Printf("Stand-in KMS starting on %s\n", addr)
+	fmt.Println("Endpoints:")
+	fmt.Println("  POST /v1/keys - Create key")
+	fmt.Println("  GET  /v1/keys/{id} - Get public key")
+	fmt.Println("  POST /v1/keys/{id}/sign - Sign digest")
+
+	if err := http.ListenAndServe(addr, backend.NewKMSServer(token)); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
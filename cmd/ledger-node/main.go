@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
 	"encoding/hex"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/keyfile"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+	"github.com/IAmSoThirsty/civic-attest/internal/ledger/tiles"
 	"github.com/IAmSoThirsty/civic-attest/internal/ledger/tree"
+	"github.com/IAmSoThirsty/civic-attest/internal/signer/backend"
 )
 
 // LedgerNode represents a ledger node server
@@ -18,15 +28,47 @@ type LedgerNode struct {
 	ledger *tree.LedgerTree
 	mu     sync.RWMutex
 	port   string
+	// tiles publishes the tree as static tiles; nil if tile storage is disabled
+	tiles     *tiles.Writer
+	origin    string
+	authority signatures.Signer
+	// published is the tree size covered by the tiles and checkpoint on disk
+	published int
 }
 
 func main() {
-	port := flag.String("port", "8080", "Port to listen on")
+	var (
+		port         = flag.String("port", "8080", "Port to listen on")
+		tilesDir     = flag.String("tiles", "", "Directory for tlog-tiles storage (disabled if empty)")
+		origin       = flag.String("origin", "civic-attest.ledger", "Log origin line for checkpoints")
+		authorityKey = flag.String("authority-key", "", "Ledger authority Ed25519 key: encrypted key file or signer URI (file:, pkcs11:, kms:), required with -tiles")
+	)
 	flag.Parse()
 
 	node := &LedgerNode{
 		ledger: tree.NewLedgerTree(hash.SHA256),
 		port:   *port,
+		origin: *origin,
+	}
+
+	if *tilesDir != "" {
+		authority, err := loadAuthority(*authorityKey)
+		if err != nil {
+			log.Fatalf("Failed to load ledger authority key: %v", err)
+		}
+
+		writer, err := tiles.NewWriter(*tilesDir)
+		if err != nil {
+			log.Fatalf("Failed to open tile storage: %v", err)
+		}
+
+		node.tiles = writer
+		node.authority = authority
+
+		// Tiles and the checkpoint are plain files, so any static server or CDN can mirror them
+		files := http.FileServer(tileFS{http.Dir(*tilesDir)})
+		http.HandleFunc("/tile/", tileHandler(files))
+		http.HandleFunc("/"+tiles.CheckpointPath, tileHandler(files))
 	}
 
 	// Setup HTTP handlers
@@ -34,7 +76,10 @@ func main() {
 	http.HandleFunc("/tree-head", node.treeHeadHandler)
 	http.HandleFunc("/append", node.appendHandler)
 	http.HandleFunc("/entry/", node.entryHandler)
+	http.HandleFunc("/root/", node.rootHandler)
 	http.HandleFunc("/inclusion-proof/", node.inclusionProofHandler)
+	http.HandleFunc("/consistency-proof/", node.consistencyProofHandler)
+	http.HandleFunc("/multi-inclusion-proof", node.multiInclusionProofHandler)
 
 	addr := fmt.Sprintf(":%s", *port)
 	fmt.Printf("Ledger Node starting on %s\n", addr)
@@ -43,7 +88,14 @@ func main() {
 	fmt.Println("  GET  /tree-head - Get signed tree head")
 	fmt.Println("  POST /append - Append new entry")
 	fmt.Println("  GET  /entry/{index} - Get entry by index")
-	fmt.Println("  GET  /inclusion-proof/{index} - Get inclusion proof")
+	fmt.Println("  GET  /root/{size} - Get root hash at an earlier tree size")
+	fmt.Println("  GET  /inclusion-proof/{index}[?tree_size=N] - Get inclusion proof, optionally at an earlier tree size")
+	fmt.Println("  GET  /consistency-proof/{old-size} - Get consistency proof to current size")
+	fmt.Println("  GET  /multi-inclusion-proof?indices=i,j,... - Get one inclusion proof for several entries")
+	if node.tiles != nil {
+		fmt.Println("  GET  /checkpoint - Get signed checkpoint")
+		fmt.Println("  GET  /tile/... - Get tlog-tiles and entry bundles")
+	}
 
 	if err := http.ListenAndServe(addr, nil); err != nil {
 		log.Fatalf("Server failed: %v", err)
@@ -75,9 +127,15 @@ func (ln *LedgerNode) appendHandler(w http.ResponseWriter, r *http.Request) {
 	ln.mu.Lock()
 	defer ln.mu.Unlock()
 
+	signatureHash, err := hash.Sum([]byte("test-signature"), hash.SHA256)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	entry := &tree.Entry{
 		SignerIdentityID: "test-identity",
-		SignatureHash:    []byte("test-hash"),
+		SignatureHash:    signatureHash,
 		EntryType:        "signature",
 		Timestamp:        time.Now().UTC(),
 	}
@@ -87,6 +145,13 @@ func (ln *LedgerNode) appendHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The entry stays in the ledger if publishing fails; the next append
+	// publishes it along with its own entry
+	if err := ln.publishTiles(); err != nil {
+		http.Error(w, fmt.Sprintf("Entry %d appended but not published: %v", entry.SequenceNumber, err), http.StatusInternalServerError)
+		return
+	}
+
 	fmt.Fprintf(w, "Entry appended: %d\n", entry.SequenceNumber)
 }
 
@@ -110,7 +175,27 @@ func (ln *LedgerNode) entryHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Sequence: %d\n", entry.SequenceNumber)
 	fmt.Fprintf(w, "Identity: %s\n", entry.SignerIdentityID)
 	fmt.Fprintf(w, "Timestamp: %s\n", entry.Timestamp.Format(time.RFC3339))
-	fmt.Fprintf(w, "Hash: %s\n", hex.EncodeToString(entry.EntryHash))
+	fmt.Fprintf(w, "Hash: %s\n", entry.EntryHash)
+}
+
+func (ln *LedgerNode) rootHandler(w http.ResponseWriter, r *http.Request) {
+	var size int
+	if _, err := fmt.Sscanf(r.URL.Path, "/root/%d", &size); err != nil {
+		http.Error(w, "Invalid size", http.StatusBadRequest)
+		return
+	}
+
+	ln.mu.RLock()
+	defer ln.mu.RUnlock()
+
+	root, err := ln.ledger.RootAt(size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	fmt.Fprintf(w, "Tree Size: %d\n", size)
+	fmt.Fprintf(w, "Root Hash: %s\n", hex.EncodeToString(root))
 }
 
 func (ln *LedgerNode) inclusionProofHandler(w http.ResponseWriter, r *http.Request) {
@@ -123,7 +208,17 @@ func (ln *LedgerNode) inclusionProofHandler(w http.ResponseWriter, r *http.Reque
 	ln.mu.RLock()
 	defer ln.mu.RUnlock()
 
-	proof, err := ln.ledger.GenerateInclusionProof(index)
+	// Default to the current tree size
+	size := ln.ledger.GetSize()
+	if param := r.URL.Query().Get("tree_size"); param != "" {
+		var err error
+		if size, err = strconv.Atoi(param); err != nil {
+			http.Error(w, "Invalid tree size", http.StatusBadRequest)
+			return
+		}
+	}
+
+	proof, err := ln.ledger.InclusionProofAt(index, size)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -132,4 +227,175 @@ func (ln *LedgerNode) inclusionProofHandler(w http.ResponseWriter, r *http.Reque
 	fmt.Fprintf(w, "Leaf Index: %d\n", proof.LeafIndex)
 	fmt.Fprintf(w, "Tree Size: %d\n", proof.TreeSize)
 	fmt.Fprintf(w, "Leaf Hash: %s\n", hex.EncodeToString(proof.LeafHash))
+	for i, h := range proof.Path {
+		fmt.Fprintf(w, "Path[%d]: %s\n", i, hex.EncodeToString(h))
+	}
+}
+
+func (ln *LedgerNode) consistencyProofHandler(w http.ResponseWriter, r *http.Request) {
+	var oldSize int
+	if _, err := fmt.Sscanf(r.URL.Path, "/consistency-proof/%d", &oldSize); err != nil {
+		http.Error(w, "Invalid size", http.StatusBadRequest)
+		return
+	}
+
+	ln.mu.RLock()
+	defer ln.mu.RUnlock()
+
+	proof, err := ln.ledger.GenerateConsistencyProof(oldSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fmt.Fprintf(w, "Old Size: %d\n", proof.OldSize)
+	fmt.Fprintf(w, "New Size: %d\n", proof.NewSize)
+	for i, h := range proof.Path {
+		fmt.Fprintf(w, "Path[%d]: %s\n", i, hex.EncodeToString(h))
+	}
+}
+
+func (ln *LedgerNode) multiInclusionProofHandler(w http.ResponseWriter, r *http.Request) {
+	indices := make([]int, 0)
+	for _, field := range strings.Split(r.URL.Query().Get("indices"), ",") {
+		index, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			http.Error(w, "Invalid index", http.StatusBadRequest)
+			return
+		}
+		indices = append(indices, index)
+	}
+
+	ln.mu.RLock()
+	defer ln.mu.RUnlock()
+
+	proof, err := ln.ledger.GenerateMultiInclusionProof(indices)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	fmt.Fprintf(w, "Tree Size: %d\n", proof.TreeSize)
+	for i, index := range proof.LeafIndices {
+		fmt.Fprintf(w, "Leaf[%d]: %s\n", index, hex.EncodeToString(proof.LeafHashes[i]))
+	}
+	for i, h := range proof.Path {
+		fmt.Fprintf(w, "Path[%d]: %s\n", i, hex.EncodeToString(h))
+	}
+}
+
+// publishTiles writes the tiles that changed since the last published size
+// and a new signed checkpoint
+func (ln *LedgerNode) publishTiles() error {
+	if ln.tiles == nil {
+		return nil
+	}
+
+	newSize := ln.ledger.GetSize()
+	if err := ln.tiles.Update(ln.ledger, ln.published, newSize); err != nil {
+		return fmt.Errorf("failed to write tiles: %w", err)
+	}
+
+	checkpoint := &tiles.Checkpoint{
+		Origin: ln.origin,
+		Size:   newSize,
+		Root:   ln.ledger.GetRootHash(),
+	}
+
+	note, err := tiles.SignNote(context.Background(), checkpoint.Marshal(), ln.origin, ln.authority)
+	if err != nil {
+		return fmt.Errorf("failed to sign checkpoint: %w", err)
+	}
+
+	if err := ln.tiles.WriteCheckpoint(note); err != nil {
+		return err
+	}
+	ln.published = newSize
+	return nil
+}
+
+// tileFS serves only the files of tile storage: directories are not listed,
+// and hidden files, such as the writer's .tmp-* files, are not served
+type tileFS struct {
+	fs http.FileSystem
+}
+
+// Open implements http.FileSystem
+func (t tileFS) Open(name string) (http.File, error) {
+	if strings.HasPrefix(path.Base(name), ".") {
+		return nil, fs.ErrNotExist
+	}
+
+	f, err := t.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, fs.ErrNotExist
+	}
+	return f, nil
+}
+
+// tileHandler serves tile storage with cache headers: tiles and entry bundles
+// never change once written, the checkpoint changes on every append
+func tileHandler(files http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if r.URL.Path == "/"+tiles.CheckpointPath {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Header().Set("Cache-Control", "no-cache")
+		} else {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+
+		files.ServeHTTP(w, r)
+	}
+}
+
+// loadAuthority opens the ledger authority key from a signer URI or an
+// encrypted key file, with the passphrase in CIVIC_ATTEST_KEY_PASSPHRASE
+func loadAuthority(spec string) (signatures.Signer, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("no key file given")
+	}
+	if backend.IsURI(spec) {
+		signer, err := backend.Open(context.Background(), spec)
+		if err != nil {
+			return nil, err
+		}
+		if signer.Algorithm() != signatures.Ed25519 {
+			return nil, fmt.Errorf("checkpoints require an Ed25519 key, got %s", signer.Algorithm())
+		}
+		return signer, nil
+	}
+
+	data, err := os.ReadFile(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	passphrase := os.Getenv(backend.PassphraseEnv)
+	if passphrase == "" {
+		return nil, fmt.Errorf("no passphrase given (set %s)", backend.PassphraseEnv)
+	}
+	key, err := keyfile.Decrypt(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if err := key.Check(signatures.Ed25519, "", 0); err != nil {
+		return nil, fmt.Errorf("checkpoints require an Ed25519 key: %w", err)
+	}
+
+	return signatures.NewKeySigner(key.PrivateKey, key.Algorithm)
 }
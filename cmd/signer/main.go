@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/hex"
+	"encoding/pem"
 	"flag"
 	"fmt"
 	"log"
@@ -10,35 +12,51 @@ import (
 
 	"github.com/IAmSoThirsty/civic-attest/internal/crypto/canonical"
 	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/keyfile"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/merkle"
 	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
 	"github.com/IAmSoThirsty/civic-attest/internal/crypto/timestamp"
 	"github.com/IAmSoThirsty/civic-attest/internal/ledger/tree"
+	"github.com/IAmSoThirsty/civic-attest/internal/signer/backend"
 	"github.com/IAmSoThirsty/civic-attest/internal/signer/bundle"
 )
 
 func main() {
 	var (
-		inputFile    = flag.String("input", "", "Input file to sign")
-		identityID   = flag.String("identity", "", "Signer identity ID")
-		keyFile      = flag.String("key", "", "Private key file (PEM format)")
-		outputFile   = flag.String("output", "", "Output signature bundle file")
-		canonFormat  = flag.String("canon", "CBOR", "Canonical format (CBOR or JSON)")
+		inputFile   = flag.String("input", "", "Input file to sign")
+		identityID  = flag.String("identity", "", "Signer identity ID")
+		keyFile     = flag.String("key", "", "Private key file (encrypted key file or PKCS#8 PEM) or signer URI (file:, pkcs11:, kms:)")
+		sigAlgo     = flag.String("algorithm", string(signatures.Ed25519), "Signature algorithm of a key file (Ed25519, Ed448, ECDSA-P256 or ECDSA-P384); signer URIs report their own")
+		pqKeyFile   = flag.String("pq-key", "", "Post-quantum private key file or signer URI for hybrid signatures")
+		pqAlgo      = flag.String("pq-algorithm", string(signatures.MLDSA65), "Post-quantum signature algorithm of a key file (ML-DSA-65 or ML-DSA-87)")
+		sigPolicy   = flag.String("signature-policy", string(signatures.PolicyClassicalOnly), "Hybrid signature policy, e.g. REQUIRE_CLASSICAL_AND_PQ")
+		outputFile  = flag.String("output", "", "Output signature bundle file")
+		canonFormat = flag.String("canon", "CBOR", "Canonical format (CBOR or JSON)")
+		contentType = flag.String("content-type", "", "Content type selecting the content canonicalizer, e.g. application/json, text/plain or application/xml (default: detected from the file extension)")
+		normForm    = flag.String("normalization", "", "Unicode normalization of document text (NFC or NFKC); opaque content is not normalized (default: the canonical format's, NFC)")
+		hashAlgo    = flag.String("hash", string(hash.SHA256), "Content hash algorithm, e.g. SHA-256, SHA-384, SHA-512/256, SHA-3-512 or BLAKE3")
+		hashPolicy  = flag.String("hash-policy", "", "Hash algorithm policy file (JSON) retiring or deprecating algorithms")
 	)
 
 	flag.Parse()
 
-	if *inputFile == "" || *identityID == "" || *keyFile == "" || *outputFile == "" {
+	policy, err := signatures.ParsePolicy(*sigPolicy)
+	if err != nil {
+		log.Fatalf("Invalid signature policy: %v", err)
+	}
+
+	if *inputFile == "" || *identityID == "" || *outputFile == "" ||
+		(policy.RequiresClassical() && *keyFile == "") || (policy.RequiresPostQuantum() && *pqKeyFile == "") {
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	// Step 1: Read master artifact
-	content, err := os.ReadFile(*inputFile)
+	// Step 1: Canonicalize with the rules of the current canonical format version
+	profile, err := canonical.LookupProfile(canonical.CurrentVersion)
 	if err != nil {
-		log.Fatalf("Failed to read input file: %v", err)
+		log.Fatalf("Failed to load canonical format: %v", err)
 	}
 
-	// Step 2: Canonicalize
 	var format canonical.Format
 	switch *canonFormat {
 	case "CBOR":
@@ -49,36 +67,156 @@ func main() {
 		log.Fatalf("Unsupported canonical format: %s", *canonFormat)
 	}
 
-	canonicalData, err := canonical.Encode(content, format)
+	if *contentType == "" {
+		*contentType = canonical.DetectContentType(*inputFile)
+	}
+	mediaType, err := canonical.ParseContentType(*contentType)
+	if err != nil {
+		log.Fatalf("Invalid content type: %v", err)
+	}
+	normalization := profile.Normalization
+	if *normForm != "" {
+		normalization, err = canonical.ParseNormalization(*normForm)
+		if err != nil {
+			log.Fatalf("Invalid normalization: %v", err)
+		}
+	}
+	if mediaType == canonical.ContentTypeOpaque {
+		normalization = ""
+	}
+	canonicalizer, err := profile.Canonicalizer(mediaType, normalization)
 	if err != nil {
-		log.Fatalf("Failed to canonicalize content: %v", err)
+		log.Fatalf("Unsupported content type: %v", err)
+	}
+	fmt.Printf("Content type: %s (%s canonicalization", mediaType, canonicalizer.Name())
+	if normalization != "" {
+		fmt.Printf(", %s", normalization)
 	}
+	fmt.Println(")")
 
-	// Step 3: Hash canonical artifact
-	contentHash, err := hash.Hash(canonicalData, hash.SHA256)
+	if *hashPolicy != "" {
+		policyData, err := os.ReadFile(*hashPolicy)
+		if err != nil {
+			log.Fatalf("Failed to read hash policy: %v", err)
+		}
+		if err := hash.DefaultRegistry.ApplyPolicy(policyData); err != nil {
+			log.Fatalf("Failed to apply hash policy: %v", err)
+		}
+	}
+
+	contentHashAlgo := hash.Algorithm(*hashAlgo)
+	if err := hash.CheckSign(contentHashAlgo); err != nil {
+		log.Fatalf("Refusing content hash algorithm: %v", err)
+	}
+
+	imprintAlgo, err := imprintAlgorithm(contentHashAlgo)
 	if err != nil {
-		log.Fatalf("Failed to hash content: %v", err)
+		log.Fatalf("Failed to select timestamp hash algorithm: %v", err)
 	}
 
-	fmt.Printf("Content hash: %s\n", hex.EncodeToString(contentHash))
+	// Step 2-3: Stream the master artifact through canonicalization and hashing,
+	// computing every digest allowed for new signatures in a single pass
+	algos := signableAlgorithms()
+	digests, err := hashFile(*inputFile, profile, format, mediaType, normalization, algos...)
+	if err != nil {
+		log.Fatalf("Failed to hash content: %v", err)
+	}
 
-	// Step 4-5: Sign with HSM (simulated with file key for demo)
-	// In production, this would interface with actual HSM
-	privateKey, err := loadPrivateKey(*keyFile)
+	contentHash, err := digests.Digest(contentHashAlgo)
 	if err != nil {
-		log.Fatalf("Failed to load private key: %v", err)
+		log.Fatalf("Failed to hash content: %v", err)
+	}
+
+	fmt.Printf("Content hash: %s\n", contentHash)
+	for _, algo := range algos {
+		d, _ := digests.Digest(algo)
+		fmt.Printf("  %s\n", d)
 	}
 
-	signature, err := signatures.Sign(privateKey, contentHash, signatures.Ed25519)
+	// The signatures cover the content hash multihash together with the
+	// content type, normalization and canonical format version it was computed
+	// under, so none of them can be swapped
+	bundleData := &bundle.SignatureBundle{
+		ContentHash:            contentHash,
+		CanonicalFormatVersion: profile.Version,
+		SignerIdentityID:       *identityID,
+		BundleVersion:          bundle.CurrentVersion,
+	}
+	if mediaType != canonical.ContentTypeOpaque {
+		bundleData.ContentType = mediaType
+		bundleData.UnicodeNormalization = string(normalization)
+	}
+	message, err := bundleData.SignedMessage()
 	if err != nil {
-		log.Fatalf("Failed to sign: %v", err)
+		log.Fatalf("Failed to build signed message: %v", err)
+	}
+
+	// Step 4-5: Sign through the signer backend (key file, HSM or KMS)
+	ctx := context.Background()
+
+	var (
+		algorithm  signatures.Algorithm
+		signature  []byte
+		keyVersion int
+	)
+	if policy.RequiresClassical() {
+		signer, err := loadSigner(ctx, *keyFile, signatures.Algorithm(*sigAlgo))
+		if err != nil {
+			log.Fatalf("Failed to load signing key: %v", err)
+		}
+		if keyVersion, err = keyVersionFor(signer, *identityID); err != nil {
+			log.Fatalf("Refusing signing key: %v", err)
+		}
+		algorithm = signer.Algorithm()
+		if signatures.IsPostQuantum(algorithm) {
+			log.Fatalf("Classical signature algorithm required, got %s", algorithm)
+		}
+
+		signature, err = signer.Sign(ctx, message)
+		if err != nil {
+			log.Fatalf("Failed to sign: %v", err)
+		}
+
+		fmt.Printf("Signature: %s\n", hex.EncodeToString(signature))
 	}
 
-	fmt.Printf("Signature: %s\n", hex.EncodeToString(signature))
+	// A post-quantum signature is added when the policy requires one, or when
+	// it is optional and a post-quantum key was given
+	var pqSignature *bundle.PostQuantumSignature
+	if policy.RequiresPostQuantum() || (policy == signatures.PolicyClassicalAndOptionalPQ && *pqKeyFile != "") {
+		pqSigner, err := loadSigner(ctx, *pqKeyFile, signatures.Algorithm(*pqAlgo))
+		if err != nil {
+			log.Fatalf("Failed to load post-quantum signing key: %v", err)
+		}
+		pqKeyVersion, err := keyVersionFor(pqSigner, *identityID)
+		if err != nil {
+			log.Fatalf("Refusing post-quantum signing key: %v", err)
+		}
+		if keyVersion == 0 {
+			keyVersion = pqKeyVersion
+		}
+		pqAlgorithm := pqSigner.Algorithm()
+		if !signatures.IsPostQuantum(pqAlgorithm) {
+			log.Fatalf("Post-quantum signature algorithm required, got %s", pqAlgorithm)
+		}
+
+		sig, err := pqSigner.Sign(ctx, message)
+		if err != nil {
+			log.Fatalf("Failed to sign with %s: %v", pqAlgorithm, err)
+		}
+
+		pqSignature = &bundle.PostQuantumSignature{Algorithm: string(pqAlgorithm), Signature: sig}
+		fmt.Printf("%s signature: %d bytes\n", pqAlgorithm, len(sig))
+	}
 
 	// Step 6: Request timestamp
+	imprint, err := digests.Digest(imprintAlgo)
+	if err != nil {
+		log.Fatalf("Failed to get timestamp imprint: %v", err)
+	}
+
 	tsaClient := timestamp.NewMockTSAClient()
-	tsToken, err := tsaClient.Request(contentHash, string(hash.SHA256))
+	tsToken, err := tsaClient.Request(imprint)
 	if err != nil {
 		log.Fatalf("Failed to get timestamp: %v", err)
 	}
@@ -107,27 +245,26 @@ func main() {
 		log.Fatalf("Failed to generate inclusion proof: %v", err)
 	}
 
-	// Step 9: Create signature bundle
-	bundleData := &bundle.SignatureBundle{
-		ContentHash:            contentHash,
-		ContentHashAlgorithm:   string(hash.SHA256),
-		CanonicalFormatVersion: "1.0",
-		SignerIdentityID:       *identityID,
-		KeyVersion:             1,
-		Signature:              signature,
-		TimestampToken:         tsData,
-		LedgerEntryHash:        entry.EntryHash,
-		MerkleInclusionProof: &bundle.InclusionProof{
-			LeafIndex: proof.LeafIndex,
-			LeafHash:  proof.LeafHash,
-			TreeSize:  proof.TreeSize,
-			Path:      proof.Path,
-		},
-		BundleVersion: "1.0",
+	// Step 9: Complete the signature bundle
+	bundleData.KeyVersion = keyVersion
+	bundleData.Signature = signature
+	bundleData.TimestampToken = tsData
+	bundleData.LedgerEntryHash = entry.EntryHash
+	bundleData.MerkleInclusionProof = &bundle.InclusionProof{
+		LeafIndex:      proof.LeafIndex,
+		LeafHash:       proof.LeafHash,
+		TreeSize:       proof.TreeSize,
+		Path:           proof.Path,
+		TreeHashScheme: int(merkle.DefaultHashScheme),
+	}
+	bundleData.SignatureAlgorithm = string(algorithm)
+	bundleData.PostQuantumSignature = pqSignature
+	if policy != signatures.PolicyClassicalOnly {
+		bundleData.SignaturePolicy = string(policy)
 	}
 
 	// Encode bundle
-	bundleBytes, err := canonical.Encode(bundleData, canonical.CBOR)
+	bundleBytes, err := profile.Encode(bundleData, canonical.CBOR)
 	if err != nil {
 		log.Fatalf("Failed to encode bundle: %v", err)
 	}
@@ -138,18 +275,132 @@ func main() {
 	}
 
 	fmt.Printf("Signature bundle written to: %s\n", *outputFile)
-	fmt.Printf("Ledger entry hash: %s\n", hex.EncodeToString(entry.EntryHash))
+	fmt.Printf("Ledger entry hash: %s\n", entry.EntryHash)
+	fmt.Printf("Ledger root hash: %s\n", hex.EncodeToString(ledger.GetRootHash()))
+}
+
+// signableAlgorithms returns the registered algorithms allowed for new signatures
+func signableAlgorithms() []hash.Algorithm {
+	algos := make([]hash.Algorithm, 0)
+	for _, algo := range hash.DefaultRegistry.Algorithms() {
+		if hash.CheckSign(algo) == nil {
+			algos = append(algos, algo)
+		}
+	}
+	return algos
+}
+
+// imprintAlgorithm picks the timestamp imprint algorithm. RFC 3161 TSAs only
+// accept algorithms with an OID, so content hashes without one (e.g. BLAKE3)
+// are timestamped with another allowed algorithm, preferring SHA-256.
+func imprintAlgorithm(contentHashAlgo hash.Algorithm) (hash.Algorithm, error) {
+	candidates := append([]hash.Algorithm{contentHashAlgo, hash.SHA256}, signableAlgorithms()...)
+	for _, algo := range candidates {
+		spec, err := hash.Lookup(algo)
+		if err == nil && spec.OID != nil && hash.CheckSign(algo) == nil {
+			return algo, nil
+		}
+	}
+	return "", fmt.Errorf("no allowed hash algorithm has an RFC 3161 object identifier")
+}
+
+// hashFile canonicalizes a file with the profile's canonicalizer for its
+// content type and hashes it. Opaque content is not read into memory.
+func hashFile(filename string, profile *canonical.Profile, format canonical.Format, contentType string, normalization canonical.Normalization, algos ...hash.Algorithm) (*hash.MultiHasher, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat input file: %w", err)
+	}
+
+	hasher, err := hash.NewMultiHasher(algos...)
+	if err != nil {
+		return nil, err
+	}
+	// Documents are canonicalized in memory first, so only opaque content
+	// is hashed in step with reading the file
+	total := info.Size()
+	if c, err := profile.Canonicalizer(contentType, normalization); err == nil && c != (canonical.Opaque{}) {
+		total = 0
+	}
+	hasher.OnProgress(printProgress(total))
+
+	if err := profile.EncodeContent(hasher, f, info.Size(), contentType, normalization, format); err != nil {
+		return nil, fmt.Errorf("failed to canonicalize content: %w", err)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	return hasher, nil
 }
 
-func loadPrivateKey(filename string) ([]byte, error) {
-	// For demo purposes, generate a new key if file doesn't exist
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		kp, err := signatures.GenerateKeyPair(signatures.Ed25519)
+// printProgress reports hashing progress on stderr whenever the percentage changes
+func printProgress(total int64) hash.ProgressFunc {
+	last := -1
+	return func(processed int64) {
+		percent := 100
+		if total > 0 && processed < total {
+			percent = int(processed * 100 / total)
+		}
+		if percent != last {
+			last = percent
+			fmt.Fprintf(os.Stderr, "\rHashing: %3d%%", percent)
+		}
+	}
+}
+
+// loadSigner opens a signer URI or a key file, either encrypted or PKCS#8
+// PEM. A missing key or a key of another algorithm is an error; a key is
+// never made up.
+func loadSigner(ctx context.Context, spec string, algo signatures.Algorithm) (signatures.Signer, error) {
+	if backend.IsURI(spec) {
+		return backend.Open(ctx, spec)
+	}
+
+	data, err := os.ReadFile(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	var signer signatures.Signer
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := keyfile.ImportPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		signer, err = signatures.NewKeySigner(key.PrivateKey, key.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		signer, err = backend.OpenFile(spec, os.Getenv(backend.PassphraseEnv))
 		if err != nil {
 			return nil, err
 		}
-		return kp.PrivateKey, nil
 	}
 
-	return os.ReadFile(filename)
+	if signer.Algorithm() != algo {
+		return nil, fmt.Errorf("key is an %s key, signing algorithm is %s", signer.Algorithm(), algo)
+	}
+	return signer, nil
+}
+
+// keyVersionFor refuses a key recorded for another identity and returns the
+// key version to put in the bundle. Keys that record no version are version 1.
+func keyVersionFor(signer signatures.Signer, identityID string) (int, error) {
+	id, ok := signer.(backend.Identified)
+	if !ok {
+		return 1, nil
+	}
+	if id.IdentityID() != "" && id.IdentityID() != identityID {
+		return 0, fmt.Errorf("key belongs to identity %s, not %s", id.IdentityID(), identityID)
+	}
+	if id.KeyVersion() == 0 {
+		return 1, nil
+	}
+	return id.KeyVersion(), nil
 }
@@ -1,15 +1,19 @@
 module github.com/IAmSoThirsty/civic-attest
 
-go 1.21
+go 1.22.0
 
 require (
+	filippo.io/edwards25519 v1.1.1
+	github.com/cloudflare/circl v1.5.0
 	github.com/fxamacker/cbor/v2 v2.5.0
+	github.com/miekg/pkcs11 v1.1.2
+	github.com/x448/float16 v0.8.4
 	github.com/zeebo/blake3 v0.2.3
 	golang.org/x/crypto v0.18.0
+	golang.org/x/text v0.14.0
 )
 
 require (
 	github.com/klauspost/cpuid/v2 v2.2.6 // indirect
-	github.com/x448/float16 v0.8.4 // indirect
 	golang.org/x/sys v0.16.0 // indirect
 )
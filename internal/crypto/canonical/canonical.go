@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"sort"
 
 	"github.com/fxamacker/cbor/v2"
 )
@@ -53,81 +52,47 @@ func encodeCBOR(data interface{}) ([]byte, error) {
 	return encMode.Marshal(data)
 }
 
+// decodeCBOR decodes data only if it is in canonical encoding and is exactly
+// the encoding of the value it decodes to, so that no two byte strings decode
+// to the same value
 func decodeCBOR(data []byte, dest interface{}) error {
-	decMode, err := cbor.DecOptions{}.DecMode()
+	violations, err := checkCBOR(data)
 	if err != nil {
-		return fmt.Errorf("failed to create CBOR decoder: %w", err)
+		return err
+	}
+	if len(violations) > 0 {
+		return &NonCanonicalError{Format: CBOR, Violations: violations}
 	}
 
-	return decMode.Unmarshal(data, dest)
+	if err := strictDecMode.Unmarshal(data, dest); err != nil {
+		return err
+	}
+	return checkDecoded(data, dest, canonicalEncMode)
 }
 
+// encodeJSON encodes data with the JSON Canonicalization Scheme (RFC 8785).
+// encoding/json maps Go values to JSON and refuses NaN and infinities; the
+// result is then canonicalized, which sorts members by UTF-16 code units,
+// serializes numbers as ECMAScript does, applies the minimal string escaping
+// and refuses duplicate member names.
 func encodeJSON(data interface{}) ([]byte, error) {
-	// Ensure deterministic JSON by sorting keys
 	var buf bytes.Buffer
 	encoder := json.NewEncoder(&buf)
 	encoder.SetEscapeHTML(false)
-	encoder.SetIndent("", "")
-
-	// Convert to map to sort keys if needed
-	normalized, err := normalizeForJSON(data)
-	if err != nil {
-		return nil, err
-	}
 
-	if err := encoder.Encode(normalized); err != nil {
+	if err := encoder.Encode(data); err != nil {
 		return nil, fmt.Errorf("failed to encode JSON: %w", err)
 	}
 
-	// Remove trailing newline
-	result := buf.Bytes()
-	if len(result) > 0 && result[len(result)-1] == '\n' {
-		result = result[:len(result)-1]
+	// Values are encoded exactly; an integer beyond double precision is
+	// refused rather than signed as a different number
+	result, err := JCS{}.canonicalize(buf.Bytes(), true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to canonicalize JSON: %w", err)
 	}
-
 	return result, nil
 }
 
 func decodeJSON(data []byte, dest interface{}) error {
 	return json.Unmarshal(data, dest)
 }
-
-// normalizeForJSON ensures deterministic JSON encoding by sorting map keys
-func normalizeForJSON(data interface{}) (interface{}, error) {
-	switch v := data.(type) {
-	case map[string]interface{}:
-		return normalizemap(v)
-	case []interface{}:
-		normalized := make([]interface{}, len(v))
-		for i, item := range v {
-			n, err := normalizeForJSON(item)
-			if err != nil {
-				return nil, err
-			}
-			normalized[i] = n
-		}
-		return normalized, nil
-	default:
-		return data, nil
-	}
-}
-
-func normalizemap(m map[string]interface{}) (map[string]interface{}, error) {
-	normalized := make(map[string]interface{})
-	keys := make([]string, 0, len(m))
-
-	for k := range m {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	for _, k := range keys {
-		n, err := normalizeForJSON(m[k])
-		if err != nil {
-			return nil, err
-		}
-		normalized[k] = n
-	}
-
-	return normalized, nil
-}
@@ -0,0 +1,236 @@
+package canonical
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Content types with built-in canonicalizers
+const (
+	// ContentTypeOpaque is hashed byte for byte; bundles without a content type use it
+	ContentTypeOpaque = "application/octet-stream"
+	// ContentTypeJSON is canonicalized with RFC 8785 (JCS)
+	ContentTypeJSON = "application/json"
+	// ContentTypeText is canonicalized with NFC and LF line endings
+	ContentTypeText = "text/plain"
+	// ContentTypeXML is canonicalized with Exclusive XML Canonicalization 1.0
+	ContentTypeXML = "application/xml"
+)
+
+// Canonicalizer rewrites a document of one content type into a canonical
+// form, so documents that differ only in ways the content type deems
+// insignificant (key order, whitespace, line endings) hash the same
+type Canonicalizer interface {
+	// Name names the canonicalization, e.g. "RFC 8785"
+	Name() string
+	// Canonicalize returns the canonical form of a document
+	Canonicalize(doc []byte) ([]byte, error)
+}
+
+// Opaque is the passthrough canonicalizer. Content is streamed rather than
+// read into memory, so it suits media of any size.
+type Opaque struct{}
+
+// Name returns "opaque"
+func (Opaque) Name() string { return "opaque" }
+
+// Canonicalize returns the document unchanged
+func (Opaque) Canonicalize(doc []byte) ([]byte, error) { return doc, nil }
+
+// ContentRegistry maps content types to canonicalizers
+type ContentRegistry struct {
+	mu             sync.RWMutex
+	canonicalizers map[string]Canonicalizer
+	frozen         bool
+}
+
+// NewContentRegistry creates an empty registry
+func NewContentRegistry() *ContentRegistry {
+	return &ContentRegistry{canonicalizers: make(map[string]Canonicalizer)}
+}
+
+// DefaultContentRegistry holds the built-in canonicalizers and is consulted by EncodeContent
+var DefaultContentRegistry = newDefaultContentRegistry()
+
+func newDefaultContentRegistry() *ContentRegistry {
+	r := NewContentRegistry()
+	for contentType, c := range map[string]Canonicalizer{
+		ContentTypeOpaque: Opaque{},
+		ContentTypeJSON:   JCS{},
+		ContentTypeText:   Text{},
+		"text/markdown":   Text{},
+		"text/csv":        Text{},
+		ContentTypeXML:    ExcC14N{},
+		"text/xml":        ExcC14N{},
+	} {
+		if err := r.Register(contentType, c); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// Register adds a canonicalizer for a content type
+func (r *ContentRegistry) Register(contentType string, c Canonicalizer) error {
+	name, err := ParseContentType(contentType)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return fmt.Errorf("no canonicalizer given for %s", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.frozen {
+		return fmt.Errorf("content registry is frozen: cannot register %s", name)
+	}
+	if _, ok := r.canonicalizers[name]; ok {
+		return fmt.Errorf("content type already registered: %s", name)
+	}
+	r.canonicalizers[name] = c
+	return nil
+}
+
+// frozenCopy returns a copy of the registry that refuses further registrations
+func (r *ContentRegistry) frozenCopy() *ContentRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c := &ContentRegistry{canonicalizers: make(map[string]Canonicalizer, len(r.canonicalizers)), frozen: true}
+	for name, canonicalizer := range r.canonicalizers {
+		c.canonicalizers[name] = canonicalizer
+	}
+	return c
+}
+
+// Lookup returns the canonicalizer of a content type. Types with a
+// structured syntax suffix (RFC 6839), such as application/ld+json, use the
+// canonicalizer of the suffix. An empty content type is opaque.
+func (r *ContentRegistry) Lookup(contentType string) (Canonicalizer, error) {
+	if contentType == "" {
+		contentType = ContentTypeOpaque
+	}
+	name, err := ParseContentType(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if c, ok := r.canonicalizers[name]; ok {
+		return c, nil
+	}
+	if i := strings.LastIndexByte(name, '+'); i >= 0 {
+		if c, ok := r.canonicalizers["application/"+name[i+1:]]; ok {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("no canonicalizer for content type: %s", name)
+}
+
+// LookupNormalized returns the canonicalizer of a content type that also
+// applies Unicode normalization n, which must be empty for opaque content
+func (r *ContentRegistry) LookupNormalized(contentType string, n Normalization) (Canonicalizer, error) {
+	c, err := r.Lookup(contentType)
+	if err != nil || n == "" {
+		return c, err
+	}
+	normalizer, ok := c.(Normalizer)
+	if !ok {
+		return nil, fmt.Errorf("%s canonicalization does not support Unicode normalization", c.Name())
+	}
+	return normalizer.WithNormalization(n), nil
+}
+
+// ContentTypes returns the registered content types in sorted order
+func (r *ContentRegistry) ContentTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.canonicalizers))
+	for name := range r.canonicalizers {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// ParseContentType returns the lowercase media type of a content type. A
+// charset parameter other than UTF-8 is refused, as every text canonicalizer
+// reads UTF-8; other parameters are dropped.
+func ParseContentType(contentType string) (string, error) {
+	name, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("invalid content type %q: %w", contentType, err)
+	}
+	if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+		return "", fmt.Errorf("unsupported charset for %s: %s", name, charset)
+	}
+	return name, nil
+}
+
+// extensionContentTypes maps file extensions to content types for
+// DetectContentType. The table is fixed rather than read from the system's
+// MIME database so that every signer picks the same type.
+var extensionContentTypes = map[string]string{
+	".json": ContentTypeJSON,
+	".txt":  ContentTypeText,
+	".md":   "text/markdown",
+	".csv":  "text/csv",
+	".xml":  ContentTypeXML,
+}
+
+// DetectContentType returns the content type of a file by its extension,
+// ContentTypeOpaque if it has no canonicalizer
+func DetectContentType(filename string) string {
+	if contentType, ok := extensionContentTypes[strings.ToLower(filepath.Ext(filename))]; ok {
+		return contentType
+	}
+	return ContentTypeOpaque
+}
+
+// EncodeContent canonicalizes a document of the given content type, with
+// its text Unicode normalized to n if not empty, and writes its canonical
+// encoding as a byte string in format, like EncodeStream. Opaque content is
+// streamed; other documents are read into memory to be canonicalized.
+func EncodeContent(w io.Writer, r io.Reader, size int64, contentType string, n Normalization, format Format) error {
+	return DefaultContentRegistry.EncodeContent(w, r, size, contentType, n, format)
+}
+
+// EncodeContent canonicalizes a document with the registry's canonicalizer for contentType
+func (reg *ContentRegistry) EncodeContent(w io.Writer, r io.Reader, size int64, contentType string, n Normalization, format Format) error {
+	c, err := reg.LookupNormalized(contentType, n)
+	if err != nil {
+		return err
+	}
+	return encodeContent(w, r, size, c, format)
+}
+
+// encodeContent canonicalizes a document with c and writes it as a byte string
+func encodeContent(w io.Writer, r io.Reader, size int64, c Canonicalizer, format Format) error {
+	if _, ok := c.(Opaque); ok {
+		return EncodeStream(w, r, size, format)
+	}
+
+	if size < 0 {
+		return fmt.Errorf("invalid content size: %d", size)
+	}
+	var doc bytes.Buffer
+	if err := copyExact(&doc, r, size); err != nil {
+		return err
+	}
+	canonicalDoc, err := c.Canonicalize(doc.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to canonicalize %s content: %w", c.Name(), err)
+	}
+	return EncodeStream(w, bytes.NewReader(canonicalDoc), int64(len(canonicalDoc)), format)
+}
@@ -0,0 +1,494 @@
+package canonical
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxJSONDepth bounds the nesting of JSON documents
+const maxJSONDepth = 1000
+
+// JCS canonicalizes JSON documents with the JSON Canonicalization Scheme
+// (RFC 8785): insignificant whitespace is removed, object members are sorted
+// by the UTF-16 code units of their names, strings use minimal escaping and
+// numbers are parsed to the nearest IEEE 754 double and serialized as
+// ECMAScript does, so 333333333.33333329 becomes 333333333.3333333. Documents
+// with duplicate member names, lone surrogates or numbers outside double
+// range are refused, as RFC 8785 requires I-JSON (RFC 7493) input.
+type JCS struct {
+	// Form normalizes strings and member names before they are sorted and
+	// checked for duplicates; RFC 8785 itself leaves them unnormalized
+	Form Normalization
+}
+
+// Name returns "RFC 8785"
+func (JCS) Name() string { return "RFC 8785" }
+
+// WithNormalization returns a JCS canonicalizer normalizing strings to n
+func (c JCS) WithNormalization(n Normalization) Canonicalizer {
+	c.Form = n
+	return c
+}
+
+// Canonicalize returns the canonical form of a JSON document. A leading
+// byte order mark is ignored.
+func (c JCS) Canonicalize(doc []byte) ([]byte, error) {
+	return c.canonicalize(doc, false)
+}
+
+// canonicalize returns the canonical form of a JSON document. If exact is
+// set, integers whose canonical form denotes a different integer are refused
+// rather than rounded.
+func (c JCS) canonicalize(doc []byte, exact bool) ([]byte, error) {
+	doc = bytes.TrimPrefix(doc, []byte("\ufeff"))
+	if !utf8.Valid(doc) {
+		return nil, fmt.Errorf("JSON document is not valid UTF-8")
+	}
+
+	p := &jsonParser{data: doc, form: c.Form, exact: exact}
+	p.skipSpace()
+	value, err := p.parseValue(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.data) {
+		return nil, p.errorf("trailing data after JSON value")
+	}
+
+	var buf bytes.Buffer
+	writeJCS(&buf, value)
+	return buf.Bytes(), nil
+}
+
+// jsonMember is a member of a parsed JSON object
+type jsonMember struct {
+	name  string
+	value interface{}
+}
+
+// jsonObject is a parsed JSON object in document order
+type jsonObject []jsonMember
+
+// jsonParser parses JSON text into nil, bool, float64, string, []interface{} and jsonObject values
+type jsonParser struct {
+	data  []byte
+	pos   int
+	form  Normalization
+	exact bool
+}
+
+func (p *jsonParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid JSON at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *jsonParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *jsonParser) parseValue(depth int) (interface{}, error) {
+	if depth > maxJSONDepth {
+		return nil, p.errorf("nesting deeper than %d", maxJSONDepth)
+	}
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of input")
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '{':
+		return p.parseObject(depth)
+	case c == '[':
+		return p.parseArray(depth)
+	case c == '"':
+		return p.parseString()
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	case p.consume("true"):
+		return true, nil
+	case p.consume("false"):
+		return false, nil
+	case p.consume("null"):
+		return nil, nil
+	default:
+		return nil, p.errorf("unexpected character %q", c)
+	}
+}
+
+// consume advances past literal if the input continues with it
+func (p *jsonParser) consume(literal string) bool {
+	if bytes.HasPrefix(p.data[p.pos:], []byte(literal)) {
+		p.pos += len(literal)
+		return true
+	}
+	return false
+}
+
+func (p *jsonParser) parseObject(depth int) (jsonObject, error) {
+	p.pos++ // '{'
+	object := jsonObject{}
+	seen := make(map[string]bool)
+
+	p.skipSpace()
+	if p.consume("}") {
+		return object, nil
+	}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) || p.data[p.pos] != '"' {
+			return nil, p.errorf("expected member name")
+		}
+		name, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			return nil, p.errorf("duplicate member name %q", name)
+		}
+		seen[name] = true
+
+		p.skipSpace()
+		if !p.consume(":") {
+			return nil, p.errorf("expected ':' after member name")
+		}
+		p.skipSpace()
+		value, err := p.parseValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		object = append(object, jsonMember{name: name, value: value})
+
+		p.skipSpace()
+		if p.consume("}") {
+			return object, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected ',' or '}' in object")
+		}
+	}
+}
+
+func (p *jsonParser) parseArray(depth int) ([]interface{}, error) {
+	p.pos++ // '['
+	array := []interface{}{}
+
+	p.skipSpace()
+	if p.consume("]") {
+		return array, nil
+	}
+	for {
+		p.skipSpace()
+		value, err := p.parseValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+
+		p.skipSpace()
+		if p.consume("]") {
+			return array, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+func (p *jsonParser) parseString() (string, error) {
+	p.pos++ // '"'
+	var sb strings.Builder
+	for {
+		if p.pos >= len(p.data) {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.data[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return p.form.ApplyString(sb.String()), nil
+		case c < 0x20:
+			return "", p.errorf("unescaped control character in string")
+		case c == '\\':
+			r, err := p.parseEscape()
+			if err != nil {
+				return "", err
+			}
+			sb.WriteRune(r)
+		default:
+			r, size := utf8.DecodeRune(p.data[p.pos:])
+			sb.WriteRune(r)
+			p.pos += size
+		}
+	}
+}
+
+// parseEscape decodes an escape sequence, joining surrogate pairs
+func (p *jsonParser) parseEscape() (rune, error) {
+	if p.pos+1 >= len(p.data) {
+		return 0, p.errorf("unterminated escape")
+	}
+	c := p.data[p.pos+1]
+	p.pos += 2
+	switch c {
+	case '"', '\\', '/':
+		return rune(c), nil
+	case 'b':
+		return '\b', nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'u':
+	default:
+		return 0, p.errorf("invalid escape '\\%c'", c)
+	}
+
+	r, err := p.parseHex4()
+	if err != nil {
+		return 0, err
+	}
+	if !utf16.IsSurrogate(r) {
+		return r, nil
+	}
+	if r < 0xdc00 && p.consume(`\u`) {
+		low, err := p.parseHex4()
+		if err != nil {
+			return 0, err
+		}
+		if pair := utf16.DecodeRune(r, low); pair != utf8.RuneError {
+			return pair, nil
+		}
+	}
+	return 0, p.errorf("lone surrogate in string")
+}
+
+func (p *jsonParser) parseHex4() (rune, error) {
+	if p.pos+4 > len(p.data) {
+		return 0, p.errorf("truncated \\u escape")
+	}
+	v, err := strconv.ParseUint(string(p.data[p.pos:p.pos+4]), 16, 16)
+	if err != nil {
+		return 0, p.errorf("invalid \\u escape")
+	}
+	p.pos += 4
+	return rune(v), nil
+}
+
+func (p *jsonParser) parseNumber() (float64, error) {
+	start := p.pos
+	p.consume("-")
+	switch {
+	case p.consume("0"):
+	case p.pos < len(p.data) && p.data[p.pos] >= '1' && p.data[p.pos] <= '9':
+		p.skipDigits()
+	default:
+		return 0, p.errorf("invalid number")
+	}
+	integer := true
+	if p.consume(".") {
+		integer = false
+		if p.skipDigits() == 0 {
+			return 0, p.errorf("invalid number fraction")
+		}
+	}
+	if p.consume("e") || p.consume("E") {
+		integer = false
+		if !p.consume("+") {
+			p.consume("-")
+		}
+		if p.skipDigits() == 0 {
+			return 0, p.errorf("invalid number exponent")
+		}
+	}
+
+	// The grammar is checked, so the only error is overflow to infinity
+	literal := string(p.data[start:p.pos])
+	f, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		return 0, p.errorf("number %s is outside IEEE 754 double range", literal)
+	}
+	if p.exact && integer && !sameDecimal(literal, formatJCSNumber(f)) {
+		return 0, p.errorf("number %s would be rounded to %s", literal, formatJCSNumber(f))
+	}
+	return f, nil
+}
+
+// sameDecimal reports whether two JSON number literals denote the same value
+func sameDecimal(a, b string) bool {
+	aNeg, aDigits, aExp, aOK := parseDecimal(a)
+	bNeg, bDigits, bExp, bOK := parseDecimal(b)
+	if !aOK || !bOK || aDigits != bDigits {
+		return false
+	}
+	// Zero has no sign and no exponent
+	return aDigits == "" || (aNeg == bNeg && aExp == bExp)
+}
+
+// parseDecimal splits a JSON number literal into its sign, its significant
+// digits without leading or trailing zeros and the power of ten of the last
+// of them, so that equal nonzero values have equal parts. Zero has no digits.
+// ok is false for nonzero numbers with an exponent too large to represent.
+func parseDecimal(literal string) (negative bool, digits string, exp int, ok bool) {
+	negative = strings.HasPrefix(literal, "-")
+	mantissa, exponent, hasExponent := strings.Cut(strings.TrimPrefix(literal, "-"), "e")
+	if !hasExponent {
+		mantissa, exponent, hasExponent = strings.Cut(mantissa, "E")
+	}
+
+	whole, fraction, _ := strings.Cut(mantissa, ".")
+	digits = strings.TrimLeft(whole+fraction, "0")
+	trimmed := strings.TrimRight(digits, "0")
+	if trimmed == "" {
+		return negative, "", 0, true
+	}
+
+	if hasExponent {
+		e, err := strconv.Atoi(exponent)
+		if err != nil {
+			return false, "", 0, false
+		}
+		exp = e
+	}
+	exp += len(digits) - len(trimmed) - len(fraction)
+	return negative, trimmed, exp, true
+}
+
+// skipDigits advances past decimal digits and returns how many there were
+func (p *jsonParser) skipDigits() int {
+	start := p.pos
+	for p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+		p.pos++
+	}
+	return p.pos - start
+}
+
+// writeJCS serializes a parsed value in canonical form
+func writeJCS(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case float64:
+		buf.WriteString(formatJCSNumber(v))
+	case string:
+		writeJCSString(buf, v)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJCS(buf, item)
+		}
+		buf.WriteByte(']')
+	case jsonObject:
+		members := append(jsonObject(nil), v...)
+		sort.Slice(members, func(i, j int) bool {
+			return lessUTF16(members[i].name, members[j].name)
+		})
+		buf.WriteByte('{')
+		for i, m := range members {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJCSString(buf, m.name)
+			buf.WriteByte(':')
+			writeJCS(buf, m.value)
+		}
+		buf.WriteByte('}')
+	}
+}
+
+// formatJCSNumber serializes a finite number as ECMAScript Number.prototype.toString does
+func formatJCSNumber(f float64) string {
+	if f == 0 {
+		return "0" // including -0
+	}
+
+	// The shortest digits that round-trip, as ECMAScript requires
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	sign := ""
+	if s[0] == '-' {
+		sign, s = "-", s[1:]
+	}
+	mantissa, exponent, _ := strings.Cut(s, "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	exp, _ := strconv.Atoi(exponent)
+
+	// n is the position of the decimal point relative to the digits
+	n, k := exp+1, len(digits)
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits
+	}
+
+	result := sign + digits[:1]
+	if k > 1 {
+		result += "." + digits[1:]
+	}
+	if n-1 >= 0 {
+		return result + "e+" + strconv.Itoa(n-1)
+	}
+	return result + "e-" + strconv.Itoa(1-n)
+}
+
+// writeJCSString writes a string with the minimal escaping of RFC 8785
+func writeJCSString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// lessUTF16 orders strings by their UTF-16 code units, as RFC 8785 sorts member names
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
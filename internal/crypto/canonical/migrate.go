@@ -0,0 +1,67 @@
+package canonical
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
+)
+
+// ContentRules are the bundle fields that select how signed content is
+// canonicalized: the canonical format version, the content type and the
+// Unicode normalization
+type ContentRules struct {
+	Version       string
+	ContentType   string
+	Normalization Normalization
+}
+
+// Migration reports the content hash of a document under the rules it was
+// signed with and under the rules of another canonical format version
+type Migration struct {
+	From     ContentRules
+	To       ContentRules
+	FromHash hash.Digest
+	ToHash   hash.Digest
+}
+
+// HashChanged reports whether the content hash differs under the new rules,
+// in which case the content must be signed again
+func (m *Migration) HashChanged() bool {
+	return !m.FromHash.Equal(m.ToHash)
+}
+
+// MigrateContent re-canonicalizes content of the given size from one set of
+// rules to another and hashes both encodings with algo. The content is read
+// twice, seeking back to its start in between.
+func MigrateContent(r io.ReadSeeker, size int64, from, to ContentRules, format Format, algo hash.Algorithm) (*Migration, error) {
+	fromHash, err := hashContent(r, size, from, format, algo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash content under canonical format %s: %w", from.Version, err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind content: %w", err)
+	}
+	toHash, err := hashContent(r, size, to, format, algo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash content under canonical format %s: %w", to.Version, err)
+	}
+
+	return &Migration{From: from, To: to, FromHash: fromHash, ToHash: toHash}, nil
+}
+
+// hashContent hashes the canonical encoding of content under rules
+func hashContent(r io.Reader, size int64, rules ContentRules, format Format, algo hash.Algorithm) (hash.Digest, error) {
+	profile, err := LookupProfile(rules.Version)
+	if err != nil {
+		return hash.Digest{}, err
+	}
+	h, err := hash.New(algo)
+	if err != nil {
+		return hash.Digest{}, err
+	}
+	if err := profile.EncodeContent(h, r, size, rules.ContentType, rules.Normalization, format); err != nil {
+		return hash.Digest{}, err
+	}
+	return hash.NewDigest(algo, h.Sum(nil))
+}
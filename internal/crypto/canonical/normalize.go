@@ -0,0 +1,63 @@
+package canonical
+
+import (
+	"fmt"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization is a Unicode normalization form applied to the text of
+// documents before they are hashed
+type Normalization string
+
+const (
+	// NFC composes canonically equivalent sequences, so precomposed and
+	// decomposed accents compare equal
+	NFC Normalization = "NFC"
+	// NFKC also folds compatibility characters such as ligatures, full-width
+	// forms and non-breaking spaces into their plain equivalents
+	NFKC Normalization = "NFKC"
+)
+
+// ParseNormalization parses a normalization form; an empty string is no
+// normalization beyond what the canonicalizer itself applies
+func ParseNormalization(s string) (Normalization, error) {
+	switch n := Normalization(s); n {
+	case "", NFC, NFKC:
+		return n, nil
+	default:
+		return "", fmt.Errorf("unsupported Unicode normalization: %s", s)
+	}
+}
+
+// ApplyString normalizes s; the empty Normalization returns s unchanged
+func (n Normalization) ApplyString(s string) string {
+	switch n {
+	case NFC:
+		return norm.NFC.String(s)
+	case NFKC:
+		return norm.NFKC.String(s)
+	default:
+		return s
+	}
+}
+
+// Apply normalizes b; the empty Normalization returns b unchanged
+func (n Normalization) Apply(b []byte) []byte {
+	switch n {
+	case NFC:
+		return norm.NFC.Bytes(b)
+	case NFKC:
+		return norm.NFKC.Bytes(b)
+	default:
+		return b
+	}
+}
+
+// Normalizer is a Canonicalizer that can Unicode normalize the text of the
+// documents it canonicalizes
+type Normalizer interface {
+	Canonicalizer
+	// WithNormalization returns a canonicalizer that also normalizes text to n
+	WithNormalization(n Normalization) Canonicalizer
+}
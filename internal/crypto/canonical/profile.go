@@ -0,0 +1,269 @@
+package canonical
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Canonical format versions recorded in bundles
+const (
+	// Version1 encodes JSON with sorted keys. Its bundles hash content as
+	// opaque bytes unless they name a content type, which signers recorded
+	// under 1.0 before canonical formats were versioned. Verifiers of 1.0
+	// accepted non-canonical CBOR, which DecodeLegacy still does on request.
+	Version1 = "1.0"
+	// Version2 canonicalizes content by type and encodes JSON with RFC 8785
+	Version2 = "2.0"
+	// CurrentVersion is the version new signatures are created under
+	CurrentVersion = Version2
+)
+
+// JSONRules is a rule set for canonical JSON
+type JSONRules string
+
+const (
+	// JSONSortedKeys is encoding/json output with sorted object keys and
+	// without HTML escaping, as encoded by version 1.0
+	JSONSortedKeys JSONRules = "sorted-keys"
+	// JSONRFC8785 is the JSON Canonicalization Scheme
+	JSONRFC8785 JSONRules = "RFC 8785"
+)
+
+// Profile is a canonical format version bound to the exact rules content and
+// structures are encoded with. A bundle records the version it was signed
+// under, so verifiers reproduce its content hash with the same rules even
+// after the current version has moved on.
+type Profile struct {
+	// Version is the canonical format version, e.g. "2.0"
+	Version string
+	// CBOREncoding are the options canonical CBOR is encoded with
+	CBOREncoding cbor.EncOptions
+	// CBORDecoding are the options CBOR is decoded with
+	CBORDecoding cbor.DecOptions
+	// LegacyCBOR lets DecodeLegacy accept CBOR that is not in canonical form,
+	// as verifiers of the version did
+	LegacyCBOR bool
+	// JSON is the canonical JSON rule set
+	JSON JSONRules
+	// Contents holds the content canonicalizers; with none, all content is
+	// opaque. Register replaces it with a frozen copy, so content types
+	// registered later never change how the version hashes content.
+	Contents *ContentRegistry
+	// Normalization is the Unicode normalization signers apply to document text
+	Normalization Normalization
+
+	encMode cbor.EncMode
+	decMode cbor.DecMode
+}
+
+// Encode encodes data in a canonical format with the rules of the profile
+func (p *Profile) Encode(data interface{}, format Format) ([]byte, error) {
+	switch format {
+	case CBOR:
+		return p.encMode.Marshal(data)
+	case JSON:
+		if p.JSON == JSONSortedKeys {
+			return encodeSortedJSON(data)
+		}
+		return encodeJSON(data)
+	default:
+		return nil, fmt.Errorf("unsupported canonical format: %s", format)
+	}
+}
+
+// Decode decodes data encoded with the rules of the profile. CBOR that is
+// not in canonical form, or is not exactly the encoding of the value it
+// decodes to, is refused.
+func (p *Profile) Decode(data []byte, format Format, dest interface{}) error {
+	switch format {
+	case CBOR:
+		violations, err := checkCBOR(data)
+		if err != nil {
+			return err
+		}
+		if len(violations) > 0 {
+			return &NonCanonicalError{Format: CBOR, Violations: violations}
+		}
+		if err := p.decMode.Unmarshal(data, dest); err != nil {
+			return err
+		}
+		return checkDecoded(data, dest, p.encMode)
+	case JSON:
+		return decodeJSON(data, dest)
+	default:
+		return fmt.Errorf("unsupported canonical format: %s", format)
+	}
+}
+
+// DecodeLegacy decodes data like Decode, but if the profile allows legacy
+// CBOR it accepts any well-formed CBOR without duplicate map keys, as the
+// verifiers of its version did. Several encodings then decode to the same
+// value, so callers must opt in to it explicitly.
+func (p *Profile) DecodeLegacy(data []byte, format Format, dest interface{}) error {
+	if format == CBOR && p.LegacyCBOR {
+		return lenientDecMode.Unmarshal(data, dest)
+	}
+	return p.Decode(data, format, dest)
+}
+
+// Canonicalizer returns the canonicalizer of a content type that also
+// applies Unicode normalization n. Profiles without content canonicalizers
+// accept only opaque content without normalization.
+func (p *Profile) Canonicalizer(contentType string, n Normalization) (Canonicalizer, error) {
+	if p.Contents != nil {
+		return p.Contents.LookupNormalized(contentType, n)
+	}
+
+	if contentType != "" {
+		name, err := ParseContentType(contentType)
+		if err != nil {
+			return nil, err
+		}
+		if name != ContentTypeOpaque {
+			return nil, fmt.Errorf("canonical format %s does not canonicalize content of type %s", p.Version, name)
+		}
+	}
+	if n != "" {
+		return nil, fmt.Errorf("canonical format %s does not support Unicode normalization", p.Version)
+	}
+	return Opaque{}, nil
+}
+
+// EncodeContent canonicalizes a document with the rules of the profile, like
+// the package-level EncodeContent
+func (p *Profile) EncodeContent(w io.Writer, r io.Reader, size int64, contentType string, n Normalization, format Format) error {
+	c, err := p.Canonicalizer(contentType, n)
+	if err != nil {
+		return err
+	}
+	return encodeContent(w, r, size, c, format)
+}
+
+// encodeSortedJSON encodes data as version 1.0 did: encoding/json output,
+// which sorts map keys, without HTML escaping or the trailing newline
+func encodeSortedJSON(data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(data); err != nil {
+		return nil, fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// ProfileRegistry maps canonical format versions to profiles
+type ProfileRegistry struct {
+	mu       sync.RWMutex
+	profiles map[string]*Profile
+}
+
+// NewProfileRegistry creates an empty registry
+func NewProfileRegistry() *ProfileRegistry {
+	return &ProfileRegistry{profiles: make(map[string]*Profile)}
+}
+
+// DefaultProfileRegistry holds the built-in canonical format versions
+var DefaultProfileRegistry = newDefaultProfileRegistry()
+
+func newDefaultProfileRegistry() *ProfileRegistry {
+	r := NewProfileRegistry()
+	for _, p := range []*Profile{
+		{
+			Version:      Version1,
+			CBOREncoding: cbor.CanonicalEncOptions(),
+			CBORDecoding: strictDecOptions,
+			LegacyCBOR:   true,
+			JSON:         JSONSortedKeys,
+			Contents:     DefaultContentRegistry,
+		},
+		{
+			Version:       Version2,
+			CBOREncoding:  cbor.CanonicalEncOptions(),
+			CBORDecoding:  strictDecOptions,
+			JSON:          JSONRFC8785,
+			Contents:      DefaultContentRegistry,
+			Normalization: NFC,
+		},
+	} {
+		if err := r.Register(p); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// Register adds a profile. Its CBOR options are checked and compiled here,
+// so a registered profile never fails to build an encoder, and its content
+// registry is copied and frozen, so the content hash rules of a version stay
+// fixed once it is registered.
+func (r *ProfileRegistry) Register(p *Profile) error {
+	if p == nil || p.Version == "" {
+		return fmt.Errorf("profile has no version")
+	}
+	switch p.JSON {
+	case JSONSortedKeys, JSONRFC8785:
+	default:
+		return fmt.Errorf("unsupported JSON rules for canonical format %s: %s", p.Version, p.JSON)
+	}
+	if _, err := ParseNormalization(string(p.Normalization)); err != nil {
+		return err
+	}
+
+	encMode, err := p.CBOREncoding.EncMode()
+	if err != nil {
+		return fmt.Errorf("invalid CBOR encoding options for canonical format %s: %w", p.Version, err)
+	}
+	decMode, err := p.CBORDecoding.DecMode()
+	if err != nil {
+		return fmt.Errorf("invalid CBOR decoding options for canonical format %s: %w", p.Version, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.profiles[p.Version]; ok {
+		return fmt.Errorf("canonical format already registered: %s", p.Version)
+	}
+	p.encMode, p.decMode = encMode, decMode
+	if p.Contents != nil {
+		p.Contents = p.Contents.frozenCopy()
+	}
+	r.profiles[p.Version] = p
+	return nil
+}
+
+// Lookup returns the profile of a canonical format version
+func (r *ProfileRegistry) Lookup(version string) (*Profile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if p, ok := r.profiles[version]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown canonical format version: %q", version)
+}
+
+// Versions returns the registered versions in sorted order
+func (r *ProfileRegistry) Versions() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := make([]string, 0, len(r.profiles))
+	for version := range r.profiles {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// LookupProfile returns the profile of a canonical format version from the default registry
+func LookupProfile(version string) (*Profile, error) {
+	return DefaultProfileRegistry.Lookup(version)
+}
@@ -0,0 +1,86 @@
+package canonical
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// cborByteStringMajor is the CBOR major type 2 (byte string) initial byte
+const cborByteStringMajor = 0x40
+
+// EncodeStream writes the canonical encoding of a byte string of the given
+// size read from r. The output is identical to Encode applied to the same
+// bytes, but the content is never held in memory, so multi-gigabyte media can
+// be canonicalized and hashed in one pass.
+func EncodeStream(w io.Writer, r io.Reader, size int64, format Format) error {
+	if size < 0 {
+		return fmt.Errorf("invalid content size: %d", size)
+	}
+
+	switch format {
+	case CBOR:
+		return encodeCBORStream(w, r, size)
+	case JSON:
+		return encodeJSONStream(w, r, size)
+	default:
+		return fmt.Errorf("unsupported canonical format: %s", format)
+	}
+}
+
+// encodeCBORStream writes a definite-length byte string with the shortest length header
+func encodeCBORStream(w io.Writer, r io.Reader, size int64) error {
+	var header []byte
+	switch {
+	case size < 24:
+		header = []byte{cborByteStringMajor | byte(size)}
+	case size <= 0xff:
+		header = []byte{cborByteStringMajor | 24, byte(size)}
+	case size <= 0xffff:
+		header = binary.BigEndian.AppendUint16([]byte{cborByteStringMajor | 25}, uint16(size))
+	case size <= 0xffffffff:
+		header = binary.BigEndian.AppendUint32([]byte{cborByteStringMajor | 26}, uint32(size))
+	default:
+		header = binary.BigEndian.AppendUint64([]byte{cborByteStringMajor | 27}, uint64(size))
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("failed to write CBOR header: %w", err)
+	}
+	return copyExact(w, r, size)
+}
+
+// encodeJSONStream writes the content as a quoted standard base64 string, as encoding/json does for []byte
+func encodeJSONStream(w io.Writer, r io.Reader, size int64) error {
+	if _, err := io.WriteString(w, `"`); err != nil {
+		return fmt.Errorf("failed to write JSON string: %w", err)
+	}
+
+	enc := base64.NewEncoder(base64.StdEncoding, w)
+	if err := copyExact(enc, r, size); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to write JSON string: %w", err)
+	}
+
+	if _, err := io.WriteString(w, `"`); err != nil {
+		return fmt.Errorf("failed to write JSON string: %w", err)
+	}
+	return nil
+}
+
+// copyExact copies exactly size bytes, failing if r is shorter or longer
+func copyExact(w io.Writer, r io.Reader, size int64) error {
+	n, err := io.CopyN(w, r, size)
+	if err != nil {
+		return fmt.Errorf("failed to copy content after %d of %d bytes: %w", n, size, err)
+	}
+
+	var probe [1]byte
+	if m, _ := io.ReadFull(r, probe[:]); m != 0 {
+		return fmt.Errorf("content is longer than %d bytes", size)
+	}
+	return nil
+}
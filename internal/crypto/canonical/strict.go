@@ -0,0 +1,465 @@
+package canonical
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/x448/float16"
+)
+
+// maxCBORDepth bounds the nesting of CBOR items
+const maxCBORDepth = 1000
+
+// Violation is a departure from canonical encoding at a byte offset
+type Violation struct {
+	// Offset is the position of the offending item in the input
+	Offset int
+	// Reason explains what canonical encoding requires there
+	Reason string
+}
+
+// String returns the offset and reason
+func (v Violation) String() string {
+	return fmt.Sprintf("offset %d: %s", v.Offset, v.Reason)
+}
+
+// NonCanonicalError is returned when decoding data that is not in canonical encoding
+type NonCanonicalError struct {
+	Format     Format
+	Violations []Violation
+}
+
+// Error returns the first violation and how many others there are
+func (e *NonCanonicalError) Error() string {
+	msg := fmt.Sprintf("non-canonical %s: %s", e.Format, e.Violations[0])
+	if len(e.Violations) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e.Violations)-1)
+	}
+	return msg
+}
+
+// CheckCanonical reports every way data departs from the canonical encoding
+// of format; no violations means data is canonical. CBOR is checked item by
+// item for the deterministic encoding rules, then decoded and re-encoded to
+// confirm the round trip reproduces it; JSON is compared with its RFC 8785
+// form. Data too malformed to parse returns an error.
+func CheckCanonical(data []byte, format Format) ([]Violation, error) {
+	switch format {
+	case CBOR:
+		return checkCBORRoundTrip(data)
+	case JSON:
+		return checkJSON(data)
+	default:
+		return nil, fmt.Errorf("unsupported canonical format: %s", format)
+	}
+}
+
+// Recanonicalize returns the canonical encoding of the value data decodes to,
+// so encodings can be compared by the data they carry rather than their bytes.
+// Non-canonical input is accepted; duplicate map keys and member names are
+// refused, as the value they carry is ambiguous.
+func Recanonicalize(data []byte, format Format) ([]byte, error) {
+	switch format {
+	case CBOR:
+		var value interface{}
+		if err := lenientDecMode.Unmarshal(data, &value); err != nil {
+			return nil, fmt.Errorf("failed to decode CBOR: %w", err)
+		}
+		return encodeCBOR(value)
+	case JSON:
+		return JCS{}.Canonicalize(data)
+	default:
+		return nil, fmt.Errorf("unsupported canonical format: %s", format)
+	}
+}
+
+// checkCBORRoundTrip checks the encoding rules, then that re-encoding
+// reproduces data byte for byte
+func checkCBORRoundTrip(data []byte) ([]Violation, error) {
+	violations, err := checkCBOR(data)
+	if err != nil || len(violations) > 0 {
+		return violations, err
+	}
+
+	var value interface{}
+	if err := strictDecMode.Unmarshal(data, &value); err != nil {
+		return []Violation{{Offset: 0, Reason: fmt.Sprintf("does not decode: %v", err)}}, nil
+	}
+	encoded, err := encodeCBOR(value)
+	if err != nil {
+		return []Violation{{Offset: 0, Reason: fmt.Sprintf("does not re-encode: %v", err)}}, nil
+	}
+	if !bytes.Equal(encoded, data) {
+		return []Violation{{Offset: firstDifference(encoded, data), Reason: "re-encoding the decoded value gives different bytes"}}, nil
+	}
+	return nil, nil
+}
+
+// checkDecoded refuses data unless the value decoded from it into dest
+// re-encodes to the same bytes. The item-by-item check cannot see what dest
+// makes of the data, such as an omitempty field encoded with its zero value.
+func checkDecoded(data []byte, dest interface{}, encMode cbor.EncMode) error {
+	encoded, err := encMode.Marshal(dest)
+	if err != nil {
+		return fmt.Errorf("failed to re-encode decoded CBOR: %w", err)
+	}
+	if !bytes.Equal(encoded, data) {
+		return &NonCanonicalError{Format: CBOR, Violations: []Violation{
+			{Offset: firstDifference(encoded, data), Reason: "re-encoding the decoded value gives different bytes"},
+		}}
+	}
+	return nil
+}
+
+// checkJSON compares a JSON document with its RFC 8785 form
+func checkJSON(data []byte) ([]Violation, error) {
+	canonicalData, err := JCS{}.Canonicalize(data)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(canonicalData, data) {
+		return nil, nil
+	}
+
+	offset := firstDifference(canonicalData, data)
+	reason := "differs from the RFC 8785 form"
+	switch {
+	case offset < len(data) && bytes.IndexByte([]byte(" \t\r\n"), data[offset]) >= 0:
+		reason = "insignificant whitespace"
+	case offset < len(data) && offset < len(canonicalData):
+		reason = fmt.Sprintf("found %q where the RFC 8785 form has %q (member order, number form or string escaping)",
+			snippet(data, offset), snippet(canonicalData, offset))
+	}
+	return []Violation{{Offset: offset, Reason: reason}}, nil
+}
+
+// firstDifference returns the first offset at which a and b differ
+func firstDifference(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// snippet returns up to 16 bytes of data from offset
+func snippet(data []byte, offset int) string {
+	end := offset + 16
+	if end > len(data) {
+		end = len(data)
+	}
+	return string(data[offset:end])
+}
+
+// strictDecOptions refuse the duplicate map keys, indefinite-length items
+// and map keys without a struct field that the default decoder accepts;
+// checkCBOR and checkDecoded catch the remaining departures
+var strictDecOptions = cbor.DecOptions{
+	DupMapKey:         cbor.DupMapKeyEnforcedAPF,
+	IndefLength:       cbor.IndefLengthForbidden,
+	ExtraReturnErrors: cbor.ExtraDecErrorUnknownField,
+}
+
+// canonicalEncMode encodes with the options of encodeCBOR
+var canonicalEncMode = mustEncMode(cbor.CanonicalEncOptions())
+
+var strictDecMode = mustDecMode(strictDecOptions)
+
+// lenientDecMode accepts any well-formed CBOR without duplicate map keys
+var lenientDecMode = mustDecMode(cbor.DecOptions{DupMapKey: cbor.DupMapKeyEnforcedAPF})
+
+func mustDecMode(opts cbor.DecOptions) cbor.DecMode {
+	decMode, err := opts.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return decMode
+}
+
+func mustEncMode(opts cbor.EncOptions) cbor.EncMode {
+	encMode, err := opts.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return encMode
+}
+
+// checkCBOR checks that data is a single CBOR item in the deterministic
+// encoding encodeCBOR produces (RFC 8949 section 4.2 with the length-first
+// map key order of RFC 7049 section 3.9)
+func checkCBOR(data []byte) ([]Violation, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("malformed CBOR: no data")
+	}
+	c := &cborChecker{data: data}
+	end, err := c.item(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if end != len(data) {
+		c.violate(end, "%d bytes of trailing data after the CBOR item", len(data)-end)
+	}
+	return c.violations, nil
+}
+
+// cborChecker walks CBOR items and collects violations
+type cborChecker struct {
+	data       []byte
+	violations []Violation
+}
+
+func (c *cborChecker) violate(offset int, format string, args ...interface{}) {
+	c.violations = append(c.violations, Violation{Offset: offset, Reason: fmt.Sprintf(format, args...)})
+}
+
+func malformed(offset int, format string, args ...interface{}) error {
+	return fmt.Errorf("malformed CBOR at offset %d: %s", offset, fmt.Sprintf(format, args...))
+}
+
+// cborMajorNames names the major types in violations
+var cborMajorNames = [8]string{"unsigned integer", "negative integer", "byte string", "text string", "array", "map", "tag", "simple value"}
+
+// head reads the initial byte and argument of the item at pos. indefinite
+// reports additional information 31; next is the offset after the head.
+func (c *cborChecker) head(pos int) (major byte, arg uint64, indefinite bool, next int, err error) {
+	if pos >= len(c.data) {
+		return 0, 0, false, 0, malformed(pos, "unexpected end of data")
+	}
+	ib := c.data[pos]
+	major, ai := ib>>5, ib&0x1f
+
+	var size int
+	switch {
+	case ai < 24:
+		return major, uint64(ai), false, pos + 1, nil
+	case ai == 24:
+		size = 1
+	case ai == 25:
+		size = 2
+	case ai == 26:
+		size = 4
+	case ai == 27:
+		size = 8
+	case ai == 31:
+		return major, 0, true, pos + 1, nil
+	default:
+		return 0, 0, false, 0, malformed(pos, "reserved additional information %d", ai)
+	}
+
+	if pos+1+size > len(c.data) {
+		return 0, 0, false, 0, malformed(pos, "unexpected end of data")
+	}
+	buf := make([]byte, 8)
+	copy(buf[8-size:], c.data[pos+1:pos+1+size])
+	arg = binary.BigEndian.Uint64(buf)
+
+	// Floating point values are checked for their shortest form separately
+	if major != 7 {
+		var shortest int
+		switch {
+		case arg < 24:
+			shortest = 0
+		case arg <= math.MaxUint8:
+			shortest = 1
+		case arg <= math.MaxUint16:
+			shortest = 2
+		case arg <= math.MaxUint32:
+			shortest = 4
+		default:
+			shortest = 8
+		}
+		if size != shortest {
+			c.violate(pos, "%s argument %d is encoded in %d bytes; the shortest form uses %d", cborMajorNames[major], arg, 1+size, 1+shortest)
+		}
+	}
+	return major, arg, false, pos + 1 + size, nil
+}
+
+// item checks the item at pos and returns the offset after it
+func (c *cborChecker) item(pos, depth int) (int, error) {
+	if depth > maxCBORDepth {
+		return 0, malformed(pos, "nesting deeper than %d", maxCBORDepth)
+	}
+	major, arg, indefinite, next, err := c.head(pos)
+	if err != nil {
+		return 0, err
+	}
+
+	if indefinite {
+		switch major {
+		case 2, 3, 4, 5:
+			c.violate(pos, "indefinite-length %s; canonical encoding uses definite lengths", cborMajorNames[major])
+			return c.indefinite(pos, major, next, depth)
+		case 7:
+			return 0, malformed(pos, "unexpected break")
+		default:
+			return 0, malformed(pos, "indefinite length on %s", cborMajorNames[major])
+		}
+	}
+
+	switch major {
+	case 0, 1:
+		return next, nil
+
+	case 2, 3:
+		if arg > uint64(len(c.data)-next) {
+			return 0, malformed(pos, "%s of %d bytes exceeds the data", cborMajorNames[major], arg)
+		}
+		end := next + int(arg)
+		if major == 3 && !utf8.Valid(c.data[next:end]) {
+			c.violate(pos, "text string is not valid UTF-8")
+		}
+		return end, nil
+
+	case 4:
+		if arg > uint64(len(c.data)-next) {
+			return 0, malformed(pos, "array of %d items exceeds the data", arg)
+		}
+		for i := uint64(0); i < arg; i++ {
+			if next, err = c.item(next, depth+1); err != nil {
+				return 0, err
+			}
+		}
+		return next, nil
+
+	case 5:
+		if arg > uint64(len(c.data)-next)/2 {
+			return 0, malformed(pos, "map of %d pairs exceeds the data", arg)
+		}
+		return c.mapPairs(next, arg, depth)
+
+	case 6:
+		return c.item(next, depth+1)
+
+	default:
+		return c.simple(pos, next)
+	}
+}
+
+// mapPairs checks n key-value pairs from pos, and that the keys are unique
+// and sorted by encoded length, then bytewise
+func (c *cborChecker) mapPairs(pos int, n uint64, depth int) (int, error) {
+	var prevKey []byte
+	prevPos := 0
+	for i := uint64(0); i < n; i++ {
+		keyEnd, err := c.item(pos, depth+1)
+		if err != nil {
+			return 0, err
+		}
+		key := c.data[pos:keyEnd]
+		if prevKey != nil {
+			switch cmp := compareCBORKeys(prevKey, key); {
+			case cmp == 0:
+				c.violate(pos, "duplicate map key (first at offset %d)", prevPos)
+			case cmp > 0:
+				c.violate(pos, "map key sorts before the key at offset %d; keys are ordered by encoded length, then bytewise", prevPos)
+			}
+		}
+		prevKey, prevPos = key, pos
+
+		if pos, err = c.item(keyEnd, depth+1); err != nil {
+			return 0, err
+		}
+	}
+	return pos, nil
+}
+
+// compareCBORKeys orders encoded map keys as cbor.SortCanonical does
+func compareCBORKeys(a, b []byte) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return bytes.Compare(a, b)
+}
+
+// indefinite skips the chunks or items of an indefinite-length item up to its break
+func (c *cborChecker) indefinite(pos int, major byte, next, depth int) (int, error) {
+	for {
+		if next >= len(c.data) {
+			return 0, malformed(pos, "indefinite-length %s without break", cborMajorNames[major])
+		}
+		if c.data[next] == 0xff {
+			return next + 1, nil
+		}
+
+		var err error
+		switch major {
+		case 2, 3:
+			if chunkMajor := c.data[next] >> 5; chunkMajor != major || c.data[next]&0x1f == 31 {
+				return 0, malformed(next, "invalid chunk in indefinite-length %s", cborMajorNames[major])
+			}
+			next, err = c.item(next, depth+1)
+		case 4:
+			next, err = c.item(next, depth+1)
+		case 5:
+			if next, err = c.item(next, depth+1); err == nil {
+				next, err = c.item(next, depth+1)
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// simple checks a major type 7 item: false, true, null or a float in its shortest form
+func (c *cborChecker) simple(pos, next int) (int, error) {
+	ai := c.data[pos] & 0x1f
+	switch {
+	case ai >= 20 && ai <= 22:
+		return next, nil
+	case ai < 24:
+		c.violate(pos, "simple value %d is not allowed; only false, true and null are", ai)
+		return next, nil
+	case ai == 24:
+		c.violate(pos, "simple value %d is not allowed; only false, true and null are", c.data[pos+1])
+		return next, nil
+	}
+
+	// Floats are encoded as cbor.ShortestFloat16 does, with NaN as 0xf97e00
+	// and infinities as half precision
+	raw := c.data[pos+1 : next]
+	var f float64
+	switch ai {
+	case 25:
+		f = float64(float16.Frombits(binary.BigEndian.Uint16(raw)).Float32())
+	case 26:
+		f = float64(math.Float32frombits(binary.BigEndian.Uint32(raw)))
+	case 27:
+		f = math.Float64frombits(binary.BigEndian.Uint64(raw))
+	}
+
+	switch {
+	case math.IsNaN(f):
+		if !bytes.Equal(c.data[pos:next], []byte{0xf9, 0x7e, 0x00}) {
+			c.violate(pos, "NaN must be encoded as 0xf97e00")
+		}
+	case ai == 25:
+	case math.IsInf(f, 0):
+		c.violate(pos, "infinity must be encoded in half precision")
+	case ai == 27 && float64(float32(f)) == f:
+		c.violate(pos, "float %v is encoded in double precision; the shortest exact form is shorter", f)
+	case ai == 26 && float16Exact(float32(f)):
+		c.violate(pos, "float %v is encoded in single precision; half precision is exact", f)
+	}
+	return next, nil
+}
+
+// float16Exact reports whether a half precision float holds f32 exactly
+func float16Exact(f32 float32) bool {
+	switch float16.PrecisionFromfloat32(f32) {
+	case float16.PrecisionExact:
+		return true
+	case float16.PrecisionUnknown:
+		return float16.Fromfloat32(f32).Float32() == f32
+	default:
+		return false
+	}
+}
@@ -0,0 +1,44 @@
+package canonical
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Text canonicalizes plain text documents: the byte order mark is dropped,
+// CRLF and CR line endings become LF, a final line ending is added if
+// missing, and the text is Unicode normalized. The same text saved by
+// editors on different platforms therefore hashes the same.
+type Text struct {
+	// Form is the normalization form, NFC if empty
+	Form Normalization
+}
+
+// Name returns "text"
+func (Text) Name() string { return "text" }
+
+// WithNormalization returns a Text canonicalizer normalizing to n
+func (t Text) WithNormalization(n Normalization) Canonicalizer {
+	t.Form = n
+	return t
+}
+
+// Canonicalize returns the canonical form of a UTF-8 text document
+func (t Text) Canonicalize(doc []byte) ([]byte, error) {
+	doc = bytes.TrimPrefix(doc, []byte("\ufeff"))
+	if !utf8.Valid(doc) {
+		return nil, fmt.Errorf("text document is not valid UTF-8")
+	}
+
+	text := bytes.ReplaceAll(doc, []byte("\r\n"), []byte("\n"))
+	text = bytes.ReplaceAll(text, []byte("\r"), []byte("\n"))
+	if len(text) > 0 && text[len(text)-1] != '\n' {
+		text = append(text, '\n')
+	}
+	form := t.Form
+	if form == "" {
+		form = NFC
+	}
+	return form.Apply(text), nil
+}
@@ -0,0 +1,164 @@
+package canonical
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+//go:embed vectors.json
+var vectorsJSON []byte
+
+// Vector is a conformance test vector: an encoding of some value and the
+// canonical encoding of that value, or the reason the input has none
+type Vector struct {
+	// Name identifies the vector
+	Name string `json:"name"`
+	// Format is the encoding format, CBOR or JSON
+	Format Format `json:"format"`
+	// Input is the encoding to canonicalize, in hex for CBOR
+	Input string `json:"input"`
+	// Canonical is the canonical encoding of the input, in hex for CBOR
+	Canonical string `json:"canonical,omitempty"`
+	// Refused explains why the input must be refused, if it has no canonical encoding
+	Refused string `json:"refused,omitempty"`
+}
+
+// InputBytes returns the input encoding
+func (v Vector) InputBytes() ([]byte, error) {
+	return v.decode(v.Input)
+}
+
+// CanonicalBytes returns the expected canonical encoding
+func (v Vector) CanonicalBytes() ([]byte, error) {
+	return v.decode(v.Canonical)
+}
+
+func (v Vector) decode(s string) ([]byte, error) {
+	if v.Format == CBOR {
+		return hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	}
+	return []byte(s), nil
+}
+
+// display formats an encoding of the vector's format for messages
+func (v Vector) display(data []byte) string {
+	if v.Format == CBOR {
+		return hex.EncodeToString(data)
+	}
+	return string(data)
+}
+
+// Check runs the vector through this package: the input must canonicalize to
+// the expected encoding (or be refused), the expected encoding must pass
+// CheckCanonical and strict decoding, and a non-canonical input must not
+func (v Vector) Check() error {
+	input, err := v.InputBytes()
+	if err != nil {
+		return fmt.Errorf("invalid input: %w", err)
+	}
+	if v.Refused != "" {
+		if _, err := Recanonicalize(input, v.Format); err == nil {
+			return fmt.Errorf("input was accepted; it must be refused: %s", v.Refused)
+		}
+		return nil
+	}
+
+	expected, err := v.CanonicalBytes()
+	if err != nil {
+		return fmt.Errorf("invalid canonical encoding: %w", err)
+	}
+	got, err := Recanonicalize(input, v.Format)
+	if err != nil {
+		return fmt.Errorf("failed to canonicalize input: %w", err)
+	}
+	if !bytes.Equal(got, expected) {
+		return fmt.Errorf("input canonicalizes to %s, expected %s", v.display(got), v.display(expected))
+	}
+
+	if violations, err := CheckCanonical(expected, v.Format); err != nil || len(violations) > 0 {
+		return fmt.Errorf("canonical encoding fails CheckCanonical: %v (err=%v)", violations, err)
+	}
+	violations, err := CheckCanonical(input, v.Format)
+	if err != nil {
+		return fmt.Errorf("CheckCanonical of input failed: %w", err)
+	}
+	canonicalInput := bytes.Equal(input, expected)
+	if canonicalInput != (len(violations) == 0) {
+		return fmt.Errorf("CheckCanonical of input reports %v; input is canonical: %t", violations, canonicalInput)
+	}
+
+	if v.Format == CBOR {
+		var value interface{}
+		if err := Decode(expected, CBOR, &value); err != nil {
+			return fmt.Errorf("canonical encoding fails strict decoding: %w", err)
+		}
+		var nonCanonical *NonCanonicalError
+		if err := Decode(input, CBOR, &value); !canonicalInput && !errors.As(err, &nonCanonical) {
+			return fmt.Errorf("strict decoding accepted the non-canonical input")
+		}
+	}
+	return nil
+}
+
+// CheckEncoding checks an encoding of the vector's input produced by another
+// encoder against the expected canonical encoding
+func (v Vector) CheckEncoding(data []byte) error {
+	if v.Refused != "" {
+		return fmt.Errorf("vector has no canonical encoding: %s", v.Refused)
+	}
+	expected, err := v.CanonicalBytes()
+	if err != nil {
+		return fmt.Errorf("invalid canonical encoding: %w", err)
+	}
+	if bytes.Equal(data, expected) {
+		return nil
+	}
+
+	if violations, err := CheckCanonical(data, v.Format); err == nil && len(violations) > 0 {
+		return &NonCanonicalError{Format: v.Format, Violations: violations}
+	}
+	return fmt.Errorf("encoding is %s, expected %s", v.display(data), v.display(expected))
+}
+
+// LoadVectors parses a JSON array of vectors. Names are slash-separated
+// relative paths, such as cbor/unsorted-map.
+func LoadVectors(data []byte) ([]Vector, error) {
+	var vectors []Vector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		return nil, fmt.Errorf("failed to parse vectors: %w", err)
+	}
+
+	names := make(map[string]bool, len(vectors))
+	for i, v := range vectors {
+		switch {
+		case v.Name == "":
+			return nil, fmt.Errorf("vector %d has no name", i)
+		case !fs.ValidPath(v.Name):
+			// Names double as file paths for the encodings of other encoders
+			return nil, fmt.Errorf("vector %d: invalid name: %q", i, v.Name)
+		case names[v.Name]:
+			return nil, fmt.Errorf("duplicate vector name: %s", v.Name)
+		case v.Format != CBOR && v.Format != JSON:
+			return nil, fmt.Errorf("vector %s: unsupported canonical format: %s", v.Name, v.Format)
+		case (v.Canonical == "") == (v.Refused == ""):
+			return nil, fmt.Errorf("vector %s: exactly one of canonical and refused is required", v.Name)
+		}
+		names[v.Name] = true
+	}
+	return vectors, nil
+}
+
+// ConformanceVectors returns the bundled conformance test vectors
+func ConformanceVectors() []Vector {
+	vectors, err := LoadVectors(vectorsJSON)
+	if err != nil {
+		panic(err)
+	}
+	return vectors
+}
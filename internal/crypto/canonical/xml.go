@@ -0,0 +1,273 @@
+package canonical
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// xmlNamespace is the namespace bound to the reserved xml prefix
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// ExcC14N canonicalizes XML documents with Exclusive XML Canonicalization 1.0
+// without comments (https://www.w3.org/TR/xml-exc-c14n/): the XML
+// declaration, DTD and comments are removed, empty elements are written as
+// start-end tag pairs, attributes are sorted and double quoted, and only the
+// namespace declarations an element visibly uses are kept. Whitespace in
+// attribute values is not normalized, as literal whitespace cannot be told
+// apart from character references after parsing; entities declared in a DTD
+// are refused.
+type ExcC14N struct {
+	// Form normalizes character data and attribute values
+	Form Normalization
+}
+
+// Name returns "exc-c14n"
+func (ExcC14N) Name() string { return "exc-c14n" }
+
+// WithNormalization returns an ExcC14N canonicalizer normalizing text to n
+func (c ExcC14N) WithNormalization(n Normalization) Canonicalizer {
+	c.Form = n
+	return c
+}
+
+// xmlFrame is an open element with the namespaces in scope in the input and
+// those rendered in the output at that element
+type xmlFrame struct {
+	name     xml.Name
+	inScope  map[string]string
+	rendered map[string]string
+}
+
+// Canonicalize returns the canonical form of an XML document
+func (c ExcC14N) Canonicalize(doc []byte) ([]byte, error) {
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	d.Strict = true
+
+	var buf bytes.Buffer
+	root := &xmlFrame{
+		inScope:  map[string]string{"xml": xmlNamespace},
+		rendered: map[string]string{},
+	}
+	stack := []*xmlFrame{root}
+	seenRoot := false
+
+	// Adjacent text, CDATA sections and text around dropped comments form
+	// one text node, normalized as a whole
+	var text bytes.Buffer
+	flushText := func() {
+		if text.Len() > 0 {
+			writeXMLEscaped(&buf, c.Form.ApplyString(text.String()), false)
+			text.Reset()
+		}
+	}
+
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML: %w", err)
+		}
+		top := stack[len(stack)-1]
+		outside := len(stack) == 1
+		switch tok.(type) {
+		case xml.CharData, xml.Comment, xml.Directive:
+		default:
+			flushText()
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if outside && seenRoot {
+				return nil, fmt.Errorf("invalid XML: more than one document element")
+			}
+			seenRoot = true
+			frame, err := writeXMLStart(&buf, t, top, c.Form)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, frame)
+
+		case xml.EndElement:
+			if outside || t.Name != top.name {
+				return nil, fmt.Errorf("invalid XML: unexpected end element %s", xmlQName(t.Name))
+			}
+			buf.WriteString("</" + xmlQName(t.Name) + ">")
+			stack = stack[:len(stack)-1]
+
+		case xml.CharData:
+			if outside {
+				if len(bytes.TrimSpace(t)) != 0 {
+					return nil, fmt.Errorf("invalid XML: text outside the document element")
+				}
+				continue
+			}
+			text.Write(t)
+
+		case xml.ProcInst:
+			if t.Target == "xml" {
+				continue // the XML declaration
+			}
+			if outside && seenRoot {
+				buf.WriteByte('\n')
+			}
+			buf.WriteString("<?" + t.Target)
+			if len(t.Inst) > 0 {
+				buf.WriteString(" " + string(t.Inst))
+			}
+			buf.WriteString("?>")
+			if outside && !seenRoot {
+				buf.WriteByte('\n')
+			}
+
+		case xml.Comment, xml.Directive:
+			// Comments and the DTD are not part of the canonical form
+		}
+	}
+
+	if !seenRoot || len(stack) != 1 {
+		return nil, fmt.Errorf("invalid XML: missing or unclosed document element")
+	}
+	return buf.Bytes(), nil
+}
+
+// writeXMLStart writes a start tag with the namespace declarations it
+// visibly uses that are not already rendered, and returns its frame
+func writeXMLStart(buf *bytes.Buffer, t xml.StartElement, parent *xmlFrame, form Normalization) (*xmlFrame, error) {
+	frame := &xmlFrame{name: t.Name, inScope: parent.inScope, rendered: parent.rendered}
+
+	// Namespace declarations change the scope of this element and its content
+	var attrs []xml.Attr
+	declared := false
+	for _, a := range t.Attr {
+		prefix, isDecl := xmlDeclaredPrefix(a.Name)
+		if !isDecl {
+			attrs = append(attrs, a)
+			continue
+		}
+		if !declared {
+			frame.inScope = copyScope(parent.inScope)
+			declared = true
+		}
+		frame.inScope[prefix] = a.Value
+	}
+
+	// The element's prefix, or the default namespace, and attribute prefixes are visibly used
+	used := map[string]bool{t.Name.Space: true}
+	for _, a := range attrs {
+		if a.Name.Space != "" {
+			used[a.Name.Space] = true
+		}
+	}
+
+	var decls []string
+	for prefix := range used {
+		if prefix == "xml" {
+			continue
+		}
+		uri, ok := frame.inScope[prefix]
+		if !ok && prefix != "" {
+			return nil, fmt.Errorf("invalid XML: undeclared namespace prefix %q", prefix)
+		}
+		if frame.rendered[prefix] == uri {
+			continue // already in effect, or an unset default namespace
+		}
+		if len(decls) == 0 {
+			frame.rendered = copyScope(parent.rendered)
+		}
+		frame.rendered[prefix] = uri
+		decls = append(decls, prefix)
+	}
+	sort.Strings(decls)
+
+	// Attributes are sorted by namespace URI, then local name
+	type sortedAttr struct {
+		uri string
+		xml.Attr
+	}
+	sorted := make([]sortedAttr, len(attrs))
+	for i, a := range attrs {
+		sorted[i] = sortedAttr{Attr: a}
+		if a.Name.Space != "" {
+			uri, ok := frame.inScope[a.Name.Space]
+			if !ok {
+				return nil, fmt.Errorf("invalid XML: undeclared namespace prefix %q", a.Name.Space)
+			}
+			sorted[i].uri = uri
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].uri != sorted[j].uri {
+			return sorted[i].uri < sorted[j].uri
+		}
+		return sorted[i].Name.Local < sorted[j].Name.Local
+	})
+
+	buf.WriteString("<" + xmlQName(t.Name))
+	for _, prefix := range decls {
+		if prefix == "" {
+			buf.WriteString(` xmlns="`)
+		} else {
+			buf.WriteString(` xmlns:` + prefix + `="`)
+		}
+		writeXMLEscaped(buf, frame.rendered[prefix], true)
+		buf.WriteByte('"')
+	}
+	for _, a := range sorted {
+		buf.WriteString(" " + xmlQName(a.Name) + `="`)
+		writeXMLEscaped(buf, form.ApplyString(a.Value), true)
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('>')
+	return frame, nil
+}
+
+// xmlDeclaredPrefix reports whether an attribute is a namespace declaration
+// and the prefix it declares, "" for the default namespace
+func xmlDeclaredPrefix(name xml.Name) (string, bool) {
+	switch {
+	case name.Space == "xmlns":
+		return name.Local, true
+	case name.Space == "" && name.Local == "xmlns":
+		return "", true
+	default:
+		return "", false
+	}
+}
+
+func copyScope(scope map[string]string) map[string]string {
+	c := make(map[string]string, len(scope)+1)
+	for k, v := range scope {
+		c[k] = v
+	}
+	return c
+}
+
+// xmlQName returns the prefixed name as written in the document
+func xmlQName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// writeXMLEscaped escapes text or an attribute value as C14N requires
+func writeXMLEscaped(buf *bytes.Buffer, s string, attr bool) {
+	var r *strings.Replacer
+	if attr {
+		r = xmlAttrEscaper
+	} else {
+		r = xmlTextEscaper
+	}
+	r.WriteString(buf, s)
+}
+
+var (
+	xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
@@ -0,0 +1,252 @@
+package frost
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"filippo.io/edwards25519"
+)
+
+// Round1Package is broadcast to all participants in the first DKG round
+type Round1Package struct {
+	// ID is the sender
+	ID Identifier `json:"id"`
+	// Commitment commits to the coefficients of the sender's secret polynomial
+	Commitment [][]byte `json:"commitment"`
+	// ProofR and ProofZ prove knowledge of the polynomial's constant term
+	ProofR []byte `json:"proof_r"`
+	ProofZ []byte `json:"proof_z"`
+}
+
+// Round2Package carries a secret share from one participant to another. It
+// must be sent over a confidential, authenticated channel.
+type Round2Package struct {
+	// From is the sender
+	From Identifier `json:"from"`
+	// To is the recipient
+	To Identifier `json:"to"`
+	// Share is the sender's polynomial evaluated at the recipient's identifier
+	Share []byte `json:"share"`
+}
+
+// DKGParticipant runs one participant's side of the Pedersen distributed key
+// generation from the FROST paper. No participant, or any coalition smaller
+// than the threshold, ever learns the group private key.
+type DKGParticipant struct {
+	id           Identifier
+	threshold    int
+	maxSigners   int
+	coefficients []*edwards25519.Scalar
+	commitments  map[Identifier][]*edwards25519.Point
+}
+
+// NewDKGParticipant starts key generation for participant id of maxSigners,
+// any threshold of whom can sign. The returned package is broadcast to all.
+func NewDKGParticipant(id Identifier, threshold, maxSigners int) (*DKGParticipant, *Round1Package, error) {
+	if id == 0 {
+		return nil, nil, fmt.Errorf("participant identifier must be non-zero")
+	}
+	if threshold < 2 || threshold > maxSigners || maxSigners > 0xffff {
+		return nil, nil, fmt.Errorf("invalid threshold %d of %d", threshold, maxSigners)
+	}
+
+	p := &DKGParticipant{
+		id:           id,
+		threshold:    threshold,
+		maxSigners:   maxSigners,
+		coefficients: make([]*edwards25519.Scalar, threshold),
+	}
+
+	pkg := &Round1Package{ID: id, Commitment: make([][]byte, threshold)}
+	for i := range p.coefficients {
+		c, err := randomScalar()
+		if err != nil {
+			return nil, nil, err
+		}
+		p.coefficients[i] = c
+		pkg.Commitment[i] = edwards25519.NewGeneratorPoint().ScalarBaseMult(c).Bytes()
+	}
+
+	// Schnorr proof of knowledge of the constant term, preventing rogue-key attacks
+	k, err := randomScalar()
+	if err != nil {
+		return nil, nil, err
+	}
+	r := edwards25519.NewGeneratorPoint().ScalarBaseMult(k)
+	c := dkgChallenge(id, pkg.Commitment[0], r.Bytes())
+	z := edwards25519.NewScalar().MultiplyAdd(p.coefficients[0], c, k)
+
+	pkg.ProofR = r.Bytes()
+	pkg.ProofZ = z.Bytes()
+	return p, pkg, nil
+}
+
+// Round2 checks the round 1 packages of all participants, including this
+// one's, and returns the secret share to send to each other participant
+func (p *DKGParticipant) Round2(round1 []*Round1Package) ([]*Round2Package, error) {
+	if len(round1) != p.maxSigners {
+		return nil, fmt.Errorf("expected %d round 1 packages, got %d", p.maxSigners, len(round1))
+	}
+
+	commitments := make(map[Identifier][]*edwards25519.Point, len(round1))
+	for _, pkg := range round1 {
+		if pkg.ID == 0 {
+			return nil, fmt.Errorf("round 1 package has a zero identifier")
+		}
+		if _, dup := commitments[pkg.ID]; dup {
+			return nil, fmt.Errorf("duplicate round 1 package from participant %d", pkg.ID)
+		}
+		points, err := verifyRound1(pkg, p.threshold)
+		if err != nil {
+			return nil, fmt.Errorf("participant %d: %w", pkg.ID, err)
+		}
+		commitments[pkg.ID] = points
+	}
+	if _, ok := commitments[p.id]; !ok {
+		return nil, fmt.Errorf("round 1 packages do not include participant %d", p.id)
+	}
+	p.commitments = commitments
+
+	out := make([]*Round2Package, 0, len(round1)-1)
+	for _, id := range sortedIdentifiers(commitments) {
+		if id == p.id {
+			continue
+		}
+		out = append(out, &Round2Package{From: p.id, To: id, Share: p.evaluate(id).Bytes()})
+	}
+	return out, nil
+}
+
+// Finalize checks the shares received from every other participant against
+// their commitments and derives this participant's key share
+func (p *DKGParticipant) Finalize(round2 []*Round2Package) (*KeyShare, error) {
+	if p.commitments == nil {
+		return nil, fmt.Errorf("Round2 has not been run")
+	}
+	if len(round2) != p.maxSigners-1 {
+		return nil, fmt.Errorf("expected %d round 2 packages, got %d", p.maxSigners-1, len(round2))
+	}
+
+	signingShare := p.evaluate(p.id)
+	seen := make(map[Identifier]bool, len(round2))
+	for _, pkg := range round2 {
+		if pkg.To != p.id {
+			return nil, fmt.Errorf("round 2 package from %d is addressed to %d", pkg.From, pkg.To)
+		}
+		commitment, ok := p.commitments[pkg.From]
+		if !ok || pkg.From == p.id || seen[pkg.From] {
+			return nil, fmt.Errorf("unexpected round 2 package from participant %d", pkg.From)
+		}
+		seen[pkg.From] = true
+
+		share, err := parseScalar(pkg.Share)
+		if err != nil {
+			return nil, fmt.Errorf("participant %d sent a malformed share", pkg.From)
+		}
+		expected := evaluateCommitment(commitment, p.id)
+		if edwards25519.NewGeneratorPoint().ScalarBaseMult(share).Equal(expected) != 1 {
+			return nil, fmt.Errorf("participant %d sent a share that does not match its commitment", pkg.From)
+		}
+		signingShare.Add(signingShare, share)
+	}
+
+	// The group key is the sum of the constant term commitments, and each
+	// verifying share is the sum of all commitments evaluated at its identifier
+	groupKey := edwards25519.NewIdentityPoint()
+	for _, commitment := range p.commitments {
+		groupKey.Add(groupKey, commitment[0])
+	}
+
+	verifyingShares := make(map[Identifier][]byte, len(p.commitments))
+	for id := range p.commitments {
+		share := edwards25519.NewIdentityPoint()
+		for _, commitment := range p.commitments {
+			share.Add(share, evaluateCommitment(commitment, id))
+		}
+		verifyingShares[id] = share.Bytes()
+	}
+
+	// Clear the secret polynomial, it must not be reused
+	for _, c := range p.coefficients {
+		c.Set(edwards25519.NewScalar())
+	}
+
+	return &KeyShare{
+		ID:           p.id,
+		SigningShare: signingShare.Bytes(),
+		PublicKeyPackage: PublicKeyPackage{
+			Threshold:       p.threshold,
+			GroupPublicKey:  groupKey.Bytes(),
+			VerifyingShares: verifyingShares,
+		},
+	}, nil
+}
+
+// evaluate evaluates the secret polynomial at an identifier
+func (p *DKGParticipant) evaluate(id Identifier) *edwards25519.Scalar {
+	x := id.scalar()
+	result := edwards25519.NewScalar()
+	for i := len(p.coefficients) - 1; i >= 0; i-- {
+		result.MultiplyAdd(result, x, p.coefficients[i])
+	}
+	return result
+}
+
+// verifyRound1 decodes a round 1 package and checks its proof of knowledge
+func verifyRound1(pkg *Round1Package, threshold int) ([]*edwards25519.Point, error) {
+	if len(pkg.Commitment) != threshold {
+		return nil, fmt.Errorf("commitment has %d coefficients, expected %d", len(pkg.Commitment), threshold)
+	}
+
+	points := make([]*edwards25519.Point, len(pkg.Commitment))
+	for i, b := range pkg.Commitment {
+		pt, err := parseElement(b)
+		if err != nil {
+			return nil, fmt.Errorf("commitment %d: %w", i, err)
+		}
+		points[i] = pt
+	}
+
+	r, err := parseElement(pkg.ProofR)
+	if err != nil {
+		return nil, fmt.Errorf("proof of knowledge: %w", err)
+	}
+	z, err := parseScalar(pkg.ProofZ)
+	if err != nil {
+		return nil, fmt.Errorf("proof of knowledge: %w", err)
+	}
+
+	// z*G == R + c*C0
+	c := dkgChallenge(pkg.ID, pkg.Commitment[0], pkg.ProofR)
+	rhs := edwards25519.NewIdentityPoint().ScalarMult(c, points[0])
+	rhs.Add(rhs, r)
+	if edwards25519.NewGeneratorPoint().ScalarBaseMult(z).Equal(rhs) != 1 {
+		return nil, fmt.Errorf("invalid proof of knowledge")
+	}
+	return points, nil
+}
+
+// evaluateCommitment evaluates a polynomial commitment at an identifier
+func evaluateCommitment(commitment []*edwards25519.Point, id Identifier) *edwards25519.Point {
+	x := id.scalar()
+	result := edwards25519.NewIdentityPoint()
+	for i := len(commitment) - 1; i >= 0; i-- {
+		result.ScalarMult(x, result)
+		result.Add(result, commitment[i])
+	}
+	return result
+}
+
+// dkgChallenge is the challenge of the proof of knowledge in round 1
+func dkgChallenge(id Identifier, c0, r []byte) *edwards25519.Scalar {
+	return hashToScalar("dkg", id.scalar().Bytes(), c0, r)
+}
+
+// randomScalar returns a uniformly random scalar
+func randomScalar() (*edwards25519.Scalar, error) {
+	var b [64]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, fmt.Errorf("failed to read randomness: %w", err)
+	}
+	return edwards25519.NewScalar().SetUniformBytes(b[:])
+}
@@ -0,0 +1,160 @@
+// Package frost implements FROST(Ed25519, SHA-512) threshold signing (RFC 9591)
+// with a Pedersen distributed key generation. Any threshold-sized subset of
+// the participants can produce a signature that is a standard Ed25519
+// signature under the group public key, so verifiers cannot tell it apart
+// from a single-key signature and need no FROST support.
+package frost
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"filippo.io/edwards25519"
+)
+
+// ContextString is the RFC 9591 ciphersuite context string
+const ContextString = "FROST-ED25519-SHA512-v1"
+
+// Identifier identifies a participant; it must be non-zero
+type Identifier uint16
+
+// PublicKeyPackage holds the public outputs of key generation, shared by all participants
+type PublicKeyPackage struct {
+	// Threshold is the minimum number of participants needed to sign
+	Threshold int `json:"threshold"`
+	// GroupPublicKey is the Ed25519 public key signatures verify under
+	GroupPublicKey []byte `json:"group_public_key"`
+	// VerifyingShares are the public keys of each participant's signing share
+	VerifyingShares map[Identifier][]byte `json:"verifying_shares"`
+}
+
+// KeyShare is one participant's secret signing share and the public key package
+type KeyShare struct {
+	// ID is the participant identifier
+	ID Identifier `json:"id"`
+	// SigningShare is the participant's secret share of the group key
+	SigningShare []byte `json:"signing_share"`
+	PublicKeyPackage
+}
+
+// scalar returns the identifier as a scalar
+func (id Identifier) scalar() *edwards25519.Scalar {
+	var b [32]byte
+	binary.LittleEndian.PutUint16(b[:], uint16(id))
+	s, _ := edwards25519.NewScalar().SetCanonicalBytes(b[:])
+	return s
+}
+
+// hashToScalar reduces SHA-512(ContextString || tag || parts...) modulo the group order
+func hashToScalar(tag string, parts ...[]byte) *edwards25519.Scalar {
+	h := sha512.New()
+	h.Write([]byte(ContextString + tag))
+	for _, p := range parts {
+		h.Write(p)
+	}
+	s, _ := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+	return s
+}
+
+// hashBytes returns SHA-512(ContextString || tag || m)
+func hashBytes(tag string, m []byte) []byte {
+	h := sha512.New()
+	h.Write([]byte(ContextString + tag))
+	h.Write(m)
+	return h.Sum(nil)
+}
+
+// h1 derives binding factors
+func h1(m []byte) *edwards25519.Scalar { return hashToScalar("rho", m) }
+
+// h2 derives the challenge. It has no context string so that the result is
+// the Ed25519 challenge SHA-512(R || A || M).
+func h2(parts ...[]byte) *edwards25519.Scalar {
+	h := sha512.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	s, _ := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+	return s
+}
+
+// h3 derives nonces
+func h3(parts ...[]byte) *edwards25519.Scalar { return hashToScalar("nonce", parts...) }
+
+// h4 hashes the message
+func h4(m []byte) []byte { return hashBytes("msg", m) }
+
+// h5 hashes the encoded commitment list
+func h5(m []byte) []byte { return hashBytes("com", m) }
+
+// parseScalar decodes a canonical scalar
+func parseScalar(b []byte) (*edwards25519.Scalar, error) {
+	s, err := edwards25519.NewScalar().SetCanonicalBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scalar encoding")
+	}
+	return s, nil
+}
+
+// orderMinusOne is the group order L minus one
+var orderMinusOne = edwards25519.NewScalar().Subtract(edwards25519.NewScalar(), oneScalar())
+
+// parseElement decodes a group element, rejecting the identity and points
+// outside the prime-order subgroup as RFC 9591 requires
+func parseElement(b []byte) (*edwards25519.Point, error) {
+	p, err := edwards25519.NewIdentityPoint().SetBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid element encoding")
+	}
+	if p.Equal(edwards25519.NewIdentityPoint()) == 1 {
+		return nil, fmt.Errorf("element is the identity")
+	}
+
+	// [L]P = [L-1]P + P is the identity only for points in the prime-order subgroup
+	check := edwards25519.NewIdentityPoint().ScalarMult(orderMinusOne, p)
+	check.Add(check, p)
+	if check.Equal(edwards25519.NewIdentityPoint()) != 1 {
+		return nil, fmt.Errorf("element is not in the prime-order subgroup")
+	}
+	return p, nil
+}
+
+// deriveInterpolatingValue computes the Lagrange coefficient of x at zero over participants
+func deriveInterpolatingValue(participants []Identifier, x Identifier) (*edwards25519.Scalar, error) {
+	numerator := oneScalar()
+	denominator := oneScalar()
+
+	found := false
+	for _, j := range participants {
+		if j == x {
+			found = true
+			continue
+		}
+		numerator.Multiply(numerator, j.scalar())
+		diff := edwards25519.NewScalar().Subtract(j.scalar(), x.scalar())
+		denominator.Multiply(denominator, diff)
+	}
+	if !found {
+		return nil, fmt.Errorf("participant %d is not in the signing set", x)
+	}
+
+	inv := edwards25519.NewScalar().Invert(denominator)
+	return numerator.Multiply(numerator, inv), nil
+}
+
+func oneScalar() *edwards25519.Scalar {
+	s, _ := edwards25519.NewScalar().SetCanonicalBytes(append([]byte{1}, make([]byte, 31)...))
+	return s
+}
+
+// sortedIdentifiers returns the keys of a map in ascending order
+func sortedIdentifiers[V any](m map[Identifier]V) []Identifier {
+	ids := make([]Identifier, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,145 @@
+package frost
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+
+	"filippo.io/edwards25519"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Invalid hex %q: %v", s, err)
+	}
+	return b
+}
+
+// TestRFC9591Vectors checks the FROST(Ed25519, SHA-512) test vectors of
+// RFC 9591 Appendix E.1. Nonce randomness is fixed there, so this test needs
+// commitWithRandomness.
+func TestRFC9591Vectors(t *testing.T) {
+	groupSecret := mustHex(t, "7b1c33d3f5291d85de664833beb1ad469f7fb6025a0ec78b3a790c6e13a98304")
+	groupKey := mustHex(t, "15d21ccd7ee42959562fc8aa63224c8851fb3ec85a3faf66040d380fb9738673")
+	message := mustHex(t, "74657374")
+
+	sk, err := parseScalar(groupSecret)
+	if err != nil {
+		t.Fatalf("Invalid group secret: %v", err)
+	}
+	if got := edwards25519.NewGeneratorPoint().ScalarBaseMult(sk).Bytes(); !bytes.Equal(got, groupKey) {
+		t.Fatalf("Group public key = %x", got)
+	}
+
+	signingShares := map[Identifier][]byte{
+		1: mustHex(t, "929dcc590407aae7d388761cddb0c0db6f5627aea8e217f4a033f2ec83d93509"),
+		2: mustHex(t, "a91e66e012e4364ac9aaa405fcafd370402d9859f7b6685c07eed76bf409e80d"),
+		3: mustHex(t, "d3cb090a075eb154e82fdb4b3cb507f110040905468bb9c46da8bdea643a9a02"),
+	}
+
+	// Shares lie on the polynomial f(x) = secret + a1*x
+	a1, err := parseScalar(mustHex(t, "178199860edd8c62f5212ee91eff1295d0d670ab4ed4506866bae57e7030b204"))
+	if err != nil {
+		t.Fatalf("Invalid coefficient: %v", err)
+	}
+	pkg := PublicKeyPackage{Threshold: 2, GroupPublicKey: groupKey, VerifyingShares: map[Identifier][]byte{}}
+	for id, share := range signingShares {
+		want := edwards25519.NewScalar().MultiplyAdd(a1, id.scalar(), sk)
+		if !bytes.Equal(want.Bytes(), share) {
+			t.Fatalf("Participant %d share does not match the polynomial", id)
+		}
+		pkg.VerifyingShares[id] = edwards25519.NewGeneratorPoint().ScalarBaseMult(want).Bytes()
+	}
+
+	participants := []struct {
+		id                              Identifier
+		hidingRandom, bindingRandom     string
+		hidingNonce, bindingNonce       string
+		hidingCommitment, bindingCommit string
+		bindingFactor                   string
+		sigShare                        string
+	}{
+		{
+			id:               1,
+			hidingRandom:     "0fd2e39e111cdc266f6c0f4d0fd45c947761f1f5d3cb583dfcb9bbaf8d4c9fec",
+			bindingRandom:    "69cd85f631d5f7f2721ed5e40519b1366f340a87c2f6856363dbdcda348a7501",
+			hidingNonce:      "812d6104142944d5a55924de6d49940956206909f2acaeedecda2b726e630407",
+			bindingNonce:     "b1110165fc2334149750b28dd813a39244f315cff14d4e89e6142f262ed83301",
+			hidingCommitment: "b5aa8ab305882a6fc69cbee9327e5a45e54c08af61ae77cb8207be3d2ce13de3",
+			bindingCommit:    "67e98ab55aa310c3120418e5050c9cf76cf387cb20ac9e4b6fdb6f82a469f932",
+			bindingFactor:    "f2cb9d7dd9beff688da6fcc83fa89046b3479417f47f55600b106760eb3b5603",
+			sigShare:         "001719ab5a53ee1a12095cd088fd149702c0720ce5fd2f29dbecf24b7281b603",
+		},
+		{
+			id:               3,
+			hidingRandom:     "86d64a260059e495d0fb4fcc17ea3da7452391baa494d4b00321098ed2a0062f",
+			bindingRandom:    "13e6b25afb2eba51716a9a7d44130c0dbae0004a9ef8d7b5550c8a0e07c61775",
+			hidingNonce:      "c256de65476204095ebdc01bd11dc10e57b36bc96284595b8215222374f99c0e",
+			bindingNonce:     "243d71944d929063bc51205714ae3c2218bd3451d0214dfb5aeec2a90c35180d",
+			hidingCommitment: "cfbdb165bd8aad6eb79deb8d287bcc0ab6658ae57fdcc98ed12c0669e90aec91",
+			bindingCommit:    "7487bc41a6e712eea2f2af24681b58b1cf1da278ea11fe4e8b78398965f13552",
+			bindingFactor:    "b087686bf35a13f3dc78e780a34b0fe8a77fef1b9938c563f5573d71d8d7890f",
+			sigShare:         "bd86125de990acc5e1f13781d8e32c03a9bbd4c53539bbc106058bfd14326007",
+		},
+	}
+
+	keyShares := make(map[Identifier]*KeyShare)
+	nonces := make(map[Identifier]*Nonces)
+	var commitments []*Commitment
+	for _, p := range participants {
+		keyShares[p.id] = &KeyShare{ID: p.id, SigningShare: signingShares[p.id], PublicKeyPackage: pkg}
+		n, c, err := commitWithRandomness(keyShares[p.id], mustHex(t, p.hidingRandom), mustHex(t, p.bindingRandom))
+		if err != nil {
+			t.Fatalf("Participant %d failed to commit: %v", p.id, err)
+		}
+		if got := hex.EncodeToString(n.hiding.Bytes()); got != p.hidingNonce {
+			t.Errorf("Participant %d hiding nonce = %s", p.id, got)
+		}
+		if got := hex.EncodeToString(n.binding.Bytes()); got != p.bindingNonce {
+			t.Errorf("Participant %d binding nonce = %s", p.id, got)
+		}
+		if got := hex.EncodeToString(c.Hiding); got != p.hidingCommitment {
+			t.Errorf("Participant %d hiding commitment = %s", p.id, got)
+		}
+		if got := hex.EncodeToString(c.Binding); got != p.bindingCommit {
+			t.Errorf("Participant %d binding commitment = %s", p.id, got)
+		}
+		nonces[p.id] = n
+		commitments = append(commitments, c)
+	}
+
+	s, err := newSession(&pkg, message, commitments)
+	if err != nil {
+		t.Fatalf("Failed to start session: %v", err)
+	}
+	var sigShares []*SignatureShare
+	for _, p := range participants {
+		if got := hex.EncodeToString(s.bindingFactors[p.id].Bytes()); got != p.bindingFactor {
+			t.Errorf("Participant %d binding factor = %s", p.id, got)
+		}
+		share, err := Sign(keyShares[p.id], nonces[p.id], message, commitments)
+		if err != nil {
+			t.Fatalf("Participant %d failed to sign: %v", p.id, err)
+		}
+		if got := hex.EncodeToString(share.Share); got != p.sigShare {
+			t.Errorf("Participant %d signature share = %s", p.id, got)
+		}
+		sigShares = append(sigShares, share)
+	}
+
+	signature, err := Aggregate(&pkg, message, commitments, sigShares)
+	if err != nil {
+		t.Fatalf("Failed to aggregate: %v", err)
+	}
+	want := "36282629c383bb820a88b71cae937d41f2f2adfcc3d02e55507e2fb9e2dd3cbe" +
+		"bd9d2b0844e49ae0f3fa935161e1419aab7b47d21a37ebeae1f17d4987b3160b"
+	if got := hex.EncodeToString(signature); got != want {
+		t.Errorf("Signature = %s", got)
+	}
+	if !ed25519.Verify(groupKey, message, signature) {
+		t.Error("Signature does not verify under the group public key")
+	}
+}
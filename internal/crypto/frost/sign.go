@@ -0,0 +1,278 @@
+package frost
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"strings"
+
+	"filippo.io/edwards25519"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+)
+
+// Commitment is a participant's public nonce commitment for one signing session
+type Commitment struct {
+	// ID is the participant
+	ID Identifier `json:"id"`
+	// Hiding is the hiding nonce commitment
+	Hiding []byte `json:"hiding"`
+	// Binding is the binding nonce commitment
+	Binding []byte `json:"binding"`
+}
+
+// Nonces are a participant's secret nonces for one signing session. They are
+// erased when used, so a participant cannot sign twice with the same nonces.
+type Nonces struct {
+	hiding     *edwards25519.Scalar
+	binding    *edwards25519.Scalar
+	commitment Commitment
+	used       bool
+}
+
+// SignatureShare is one participant's share of a signature
+type SignatureShare struct {
+	// ID is the participant
+	ID Identifier `json:"id"`
+	// Share is the signature share scalar
+	Share []byte `json:"share"`
+}
+
+// Commit generates nonces for one signing session (round one). The
+// commitment is sent to the coordinator; the nonces stay with the participant.
+func Commit(share *KeyShare) (*Nonces, *Commitment, error) {
+	var hidingRandom, bindingRandom [32]byte
+	if _, err := rand.Read(hidingRandom[:]); err != nil {
+		return nil, nil, fmt.Errorf("failed to read randomness: %w", err)
+	}
+	if _, err := rand.Read(bindingRandom[:]); err != nil {
+		return nil, nil, fmt.Errorf("failed to read randomness: %w", err)
+	}
+	return commitWithRandomness(share, hidingRandom[:], bindingRandom[:])
+}
+
+// commitWithRandomness derives nonces from the signing share and fresh
+// randomness, so a weak random source alone does not leak the share
+func commitWithRandomness(share *KeyShare, hidingRandom, bindingRandom []byte) (*Nonces, *Commitment, error) {
+	if _, err := parseScalar(share.SigningShare); err != nil {
+		return nil, nil, fmt.Errorf("invalid signing share: %w", err)
+	}
+
+	n := &Nonces{
+		hiding:  h3(hidingRandom, share.SigningShare),
+		binding: h3(bindingRandom, share.SigningShare),
+	}
+	n.commitment = Commitment{
+		ID:      share.ID,
+		Hiding:  edwards25519.NewGeneratorPoint().ScalarBaseMult(n.hiding).Bytes(),
+		Binding: edwards25519.NewGeneratorPoint().ScalarBaseMult(n.binding).Bytes(),
+	}
+
+	commitment := n.commitment
+	return n, &commitment, nil
+}
+
+// Sign produces this participant's signature share over message (round two).
+// commitments are the commitments of every participant in the session.
+func Sign(share *KeyShare, nonces *Nonces, message []byte, commitments []*Commitment) (*SignatureShare, error) {
+	if nonces.used {
+		return nil, fmt.Errorf("nonces have already been used")
+	}
+
+	secret, err := parseScalar(share.SigningShare)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing share: %w", err)
+	}
+
+	session, err := newSession(&share.PublicKeyPackage, message, commitments)
+	if err != nil {
+		return nil, err
+	}
+
+	own, ok := session.commitments[share.ID]
+	if !ok || !bytes.Equal(own.Hiding, nonces.commitment.Hiding) || !bytes.Equal(own.Binding, nonces.commitment.Binding) {
+		return nil, fmt.Errorf("commitment list does not contain this participant's commitment")
+	}
+
+	lambda, err := deriveInterpolatingValue(session.participants, share.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// z = hiding + binding * rho + lambda * share * c
+	z := edwards25519.NewScalar().Multiply(lambda, secret)
+	z.Multiply(z, session.challenge)
+	z.MultiplyAdd(nonces.binding, session.bindingFactors[share.ID], z)
+	z.Add(z, nonces.hiding)
+
+	nonces.hiding.Set(edwards25519.NewScalar())
+	nonces.binding.Set(edwards25519.NewScalar())
+	nonces.used = true
+
+	return &SignatureShare{ID: share.ID, Share: z.Bytes()}, nil
+}
+
+// Aggregate combines signature shares into an Ed25519 signature under the
+// group public key. If the result does not verify, every share is checked
+// against its verifying share and the misbehaving participants are named.
+func Aggregate(pkg *PublicKeyPackage, message []byte, commitments []*Commitment, shares []*SignatureShare) ([]byte, error) {
+	session, err := newSession(pkg, message, commitments)
+	if err != nil {
+		return nil, err
+	}
+	if len(shares) != len(session.participants) {
+		return nil, fmt.Errorf("expected %d signature shares, got %d", len(session.participants), len(shares))
+	}
+
+	parsed := make(map[Identifier]*edwards25519.Scalar, len(shares))
+	for _, s := range shares {
+		if _, ok := session.commitments[s.ID]; !ok {
+			return nil, fmt.Errorf("signature share from participant %d, who did not commit", s.ID)
+		}
+		if _, dup := parsed[s.ID]; dup {
+			return nil, fmt.Errorf("duplicate signature share from participant %d", s.ID)
+		}
+		z, err := parseScalar(s.Share)
+		if err != nil {
+			return nil, fmt.Errorf("participant %d sent a malformed signature share", s.ID)
+		}
+		parsed[s.ID] = z
+	}
+
+	z := edwards25519.NewScalar()
+	for _, share := range parsed {
+		z.Add(z, share)
+	}
+	signature := append(session.groupCommitment.Bytes(), z.Bytes()...)
+
+	valid, err := signatures.Verify(pkg.GroupPublicKey, message, signature, signatures.Ed25519)
+	if err == nil && valid {
+		return signature, nil
+	}
+
+	var culprits []string
+	for _, id := range session.participants {
+		if !session.verifyShare(pkg, id, parsed[id]) {
+			culprits = append(culprits, fmt.Sprint(id))
+		}
+	}
+	if len(culprits) > 0 {
+		return nil, fmt.Errorf("invalid signature shares from participants %s", strings.Join(culprits, ", "))
+	}
+	return nil, fmt.Errorf("aggregate signature does not verify")
+}
+
+// VerifyShare checks one participant's signature share, letting a
+// coordinator identify misbehaving participants
+func VerifyShare(pkg *PublicKeyPackage, message []byte, commitments []*Commitment, share *SignatureShare) (bool, error) {
+	session, err := newSession(pkg, message, commitments)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := session.commitments[share.ID]; !ok {
+		return false, fmt.Errorf("participant %d did not commit", share.ID)
+	}
+	z, err := parseScalar(share.Share)
+	if err != nil {
+		return false, fmt.Errorf("malformed signature share")
+	}
+	return session.verifyShare(pkg, share.ID, z), nil
+}
+
+// session holds the values shared by all participants of one signing session
+type session struct {
+	participants    []Identifier
+	commitments     map[Identifier]*Commitment
+	hiding, binding map[Identifier]*edwards25519.Point
+	bindingFactors  map[Identifier]*edwards25519.Scalar
+	groupCommitment *edwards25519.Point
+	challenge       *edwards25519.Scalar
+}
+
+// newSession validates the commitment list and derives the binding factors,
+// group commitment and challenge (RFC 9591 section 4)
+func newSession(pkg *PublicKeyPackage, message []byte, commitments []*Commitment) (*session, error) {
+	groupKey, err := parseElement(pkg.GroupPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid group public key: %w", err)
+	}
+	if len(commitments) < pkg.Threshold || pkg.Threshold < 2 {
+		return nil, fmt.Errorf("%d commitments, threshold is %d", len(commitments), pkg.Threshold)
+	}
+
+	sorted := append([]*Commitment(nil), commitments...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	s := &session{
+		participants:   make([]Identifier, 0, len(sorted)),
+		commitments:    make(map[Identifier]*Commitment, len(sorted)),
+		hiding:         make(map[Identifier]*edwards25519.Point, len(sorted)),
+		binding:        make(map[Identifier]*edwards25519.Point, len(sorted)),
+		bindingFactors: make(map[Identifier]*edwards25519.Scalar, len(sorted)),
+	}
+
+	var encoded []byte
+	for _, c := range sorted {
+		if _, dup := s.commitments[c.ID]; dup || c.ID == 0 {
+			return nil, fmt.Errorf("invalid or duplicate commitment from participant %d", c.ID)
+		}
+		if _, ok := pkg.VerifyingShares[c.ID]; !ok {
+			return nil, fmt.Errorf("participant %d is not a key holder", c.ID)
+		}
+		hiding, err := parseElement(c.Hiding)
+		if err != nil {
+			return nil, fmt.Errorf("participant %d hiding commitment: %w", c.ID, err)
+		}
+		binding, err := parseElement(c.Binding)
+		if err != nil {
+			return nil, fmt.Errorf("participant %d binding commitment: %w", c.ID, err)
+		}
+
+		s.participants = append(s.participants, c.ID)
+		s.commitments[c.ID] = c
+		s.hiding[c.ID] = hiding
+		s.binding[c.ID] = binding
+		encoded = append(encoded, c.ID.scalar().Bytes()...)
+		encoded = append(encoded, c.Hiding...)
+		encoded = append(encoded, c.Binding...)
+	}
+
+	// rho_i = H1(PK || H4(msg) || H5(commitments) || i)
+	prefix := append(append(groupKey.Bytes(), h4(message)...), h5(encoded)...)
+	for _, id := range s.participants {
+		s.bindingFactors[id] = h1(append(append([]byte(nil), prefix...), id.scalar().Bytes()...))
+	}
+
+	// R = sum(D_i + rho_i * E_i)
+	s.groupCommitment = edwards25519.NewIdentityPoint()
+	for _, id := range s.participants {
+		term := edwards25519.NewIdentityPoint().ScalarMult(s.bindingFactors[id], s.binding[id])
+		term.Add(term, s.hiding[id])
+		s.groupCommitment.Add(s.groupCommitment, term)
+	}
+
+	s.challenge = h2(s.groupCommitment.Bytes(), groupKey.Bytes(), message)
+	return s, nil
+}
+
+// verifyShare checks z_i*G == D_i + rho_i*E_i + (c*lambda_i)*Y_i
+func (s *session) verifyShare(pkg *PublicKeyPackage, id Identifier, z *edwards25519.Scalar) bool {
+	if z == nil {
+		return false
+	}
+	verifyingShare, err := parseElement(pkg.VerifyingShares[id])
+	if err != nil {
+		return false
+	}
+	lambda, err := deriveInterpolatingValue(s.participants, id)
+	if err != nil {
+		return false
+	}
+
+	rhs := edwards25519.NewIdentityPoint().ScalarMult(s.bindingFactors[id], s.binding[id])
+	rhs.Add(rhs, s.hiding[id])
+	rhs.Add(rhs, edwards25519.NewIdentityPoint().ScalarMult(edwards25519.NewScalar().Multiply(s.challenge, lambda), verifyingShare))
+
+	return edwards25519.NewGeneratorPoint().ScalarBaseMult(z).Equal(rhs) == 1
+}
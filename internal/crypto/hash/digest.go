@@ -0,0 +1,180 @@
+package hash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Digest binds a hash value to the algorithm that produced it. It encodes as a
+// multihash: varint algorithm code, varint digest length, digest bytes. CBOR
+// carries the multihash as a byte string and JSON as a lowercase hex string;
+// an unset digest encodes as null in both.
+type Digest struct {
+	// Algorithm is the hash algorithm
+	Algorithm Algorithm
+	// Value is the raw digest
+	Value []byte
+}
+
+// NewDigest creates a digest, checking that the value length matches the algorithm
+func NewDigest(algo Algorithm, value []byte) (Digest, error) {
+	spec, err := DefaultRegistry.Lookup(algo)
+	if err != nil {
+		return Digest{}, err
+	}
+	if len(value) != spec.Size {
+		return Digest{}, fmt.Errorf("invalid %s digest length: %d, expected %d", algo, len(value), spec.Size)
+	}
+	return Digest{Algorithm: algo, Value: append([]byte(nil), value...)}, nil
+}
+
+// Sum hashes data and returns the tagged digest
+func Sum(data []byte, algo Algorithm) (Digest, error) {
+	h, err := Hash(data, algo)
+	if err != nil {
+		return Digest{}, err
+	}
+	return Digest{Algorithm: algo, Value: h}, nil
+}
+
+// ParseDigest decodes a multihash. Unknown codes, non-minimal varints,
+// lengths that do not match the algorithm and trailing bytes are rejected.
+func ParseDigest(data []byte) (Digest, error) {
+	code, rest, err := readUvarint(data)
+	if err != nil {
+		return Digest{}, fmt.Errorf("malformed digest algorithm code: %w", err)
+	}
+	length, rest, err := readUvarint(rest)
+	if err != nil {
+		return Digest{}, fmt.Errorf("malformed digest length: %w", err)
+	}
+
+	spec, err := DefaultRegistry.LookupCode(code)
+	if err != nil {
+		return Digest{}, err
+	}
+	if length != uint64(spec.Size) {
+		return Digest{}, fmt.Errorf("invalid %s digest length: %d, expected %d", spec.Algorithm, length, spec.Size)
+	}
+	if uint64(len(rest)) != length {
+		return Digest{}, fmt.Errorf("digest has %d bytes, header declares %d", len(rest), length)
+	}
+
+	return Digest{Algorithm: spec.Algorithm, Value: append([]byte(nil), rest...)}, nil
+}
+
+// Bytes returns the multihash encoding of the digest
+func (d Digest) Bytes() []byte {
+	spec, _ := DefaultRegistry.Lookup(d.Algorithm)
+	out := binary.AppendUvarint(nil, spec.Code)
+	out = binary.AppendUvarint(out, uint64(len(d.Value)))
+	return append(out, d.Value...)
+}
+
+// IsZero reports whether the digest is unset
+func (d Digest) IsZero() bool {
+	return d.Algorithm == "" && len(d.Value) == 0
+}
+
+// Equal reports whether two digests have the same algorithm and value
+func (d Digest) Equal(other Digest) bool {
+	return d.Algorithm == other.Algorithm && bytes.Equal(d.Value, other.Value)
+}
+
+// Validate checks that the algorithm is supported and the value has its length
+func (d Digest) Validate() error {
+	_, err := NewDigest(d.Algorithm, d.Value)
+	return err
+}
+
+// String returns the algorithm and hex value, e.g. "SHA-256:2cf2..."
+func (d Digest) String() string {
+	return string(d.Algorithm) + ":" + hex.EncodeToString(d.Value)
+}
+
+// MarshalCBOR encodes the digest as a CBOR byte string holding the multihash
+func (d Digest) MarshalCBOR() ([]byte, error) {
+	if d.IsZero() {
+		return cborNull, nil
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(d.Bytes())
+}
+
+// UnmarshalCBOR decodes a CBOR byte string holding a multihash
+func (d *Digest) UnmarshalCBOR(data []byte) error {
+	if bytes.Equal(data, cborNull) {
+		*d = Digest{}
+		return nil
+	}
+
+	var raw []byte
+	if err := cbor.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("failed to decode digest: %w", err)
+	}
+
+	parsed, err := ParseDigest(raw)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// MarshalJSON encodes the digest as a lowercase hex multihash string
+func (d Digest) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(hex.EncodeToString(d.Bytes()))
+}
+
+// UnmarshalJSON decodes a lowercase hex multihash string
+func (d *Digest) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = Digest{}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("failed to decode digest: %w", err)
+	}
+
+	raw, err := hex.DecodeString(s)
+	if err != nil || hex.EncodeToString(raw) != s {
+		return fmt.Errorf("digest is not lowercase hex: %q", s)
+	}
+
+	parsed, err := ParseDigest(raw)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// cborNull is the CBOR encoding of null
+var cborNull = []byte{0xf6}
+
+// readUvarint reads a minimally encoded unsigned varint
+func readUvarint(data []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, fmt.Errorf("truncated or overflowing varint")
+	}
+	if n != len(binary.AppendUvarint(nil, v)) {
+		return 0, nil, fmt.Errorf("non-minimal varint")
+	}
+	return v, data[n:], nil
+}
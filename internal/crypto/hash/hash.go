@@ -1,12 +1,7 @@
 package hash
 
 import (
-	"crypto/sha256"
 	"encoding/hex"
-	"fmt"
-
-	"github.com/zeebo/blake3"
-	"golang.org/x/crypto/sha3"
 )
 
 // Algorithm represents a cryptographic hash algorithm
@@ -19,23 +14,20 @@ const (
 	SHA3_512 Algorithm = "SHA-3-512"
 	// BLAKE3 is the high-throughput mode
 	BLAKE3 Algorithm = "BLAKE3"
+	// SHA384 is SHA-384, for profiles that require a NIST hash longer than 256 bits
+	SHA384 Algorithm = "SHA-384"
+	// SHA512_256 is SHA-512/256, a 256-bit digest resistant to length extension
+	SHA512_256 Algorithm = "SHA-512/256"
 )
 
 // Hash computes a cryptographic hash of the input data using the specified algorithm
 func Hash(data []byte, algo Algorithm) ([]byte, error) {
-	switch algo {
-	case SHA256:
-		h := sha256.Sum256(data)
-		return h[:], nil
-	case SHA3_512:
-		h := sha3.Sum512(data)
-		return h[:], nil
-	case BLAKE3:
-		h := blake3.Sum256(data)
-		return h[:], nil
-	default:
-		return nil, fmt.Errorf("unsupported hash algorithm: %s", algo)
+	h, err := New(algo)
+	if err != nil {
+		return nil, err
 	}
+	h.Write(data)
+	return h.Sum(nil), nil
 }
 
 // HashString returns the hex-encoded hash
@@ -3,6 +3,7 @@ package hash
 import (
 	"encoding/hex"
 	"testing"
+	"time"
 )
 
 func TestHash(t *testing.T) {
@@ -24,6 +25,18 @@ func TestHash(t *testing.T) {
 			algo:     SHA256,
 			expected: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
 		},
+		{
+			name:     "SHA384 abc",
+			data:     []byte("abc"),
+			algo:     SHA384,
+			expected: "cb00753f45a35e8bb5a03d699ac65007272c32ab0eded1631a8b605a43ff5bed8086072ba1e7cc2358baeca134c825a7",
+		},
+		{
+			name:     "SHA512/256 abc",
+			data:     []byte("abc"),
+			algo:     SHA512_256,
+			expected: "53048e2681941ef99b2e29b76b4c7dabe4c2d0c634fc6d46e0e2f13107e7af23",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -68,3 +81,81 @@ func TestVerify(t *testing.T) {
 		t.Error("Verification should fail with wrong data")
 	}
 }
+
+func TestRegistryLookups(t *testing.T) {
+	r := newDefaultRegistry()
+
+	for _, algo := range r.Algorithms() {
+		spec, err := r.Lookup(algo)
+		if err != nil {
+			t.Fatalf("Lookup(%s) failed: %v", algo, err)
+		}
+		if byCode, err := r.LookupCode(spec.Code); err != nil || byCode.Algorithm != algo {
+			t.Errorf("LookupCode(0x%x) did not return %s (err=%v)", spec.Code, algo, err)
+		}
+		if spec.OID != nil {
+			if byOID, err := r.LookupOID(spec.OID); err != nil || byOID.Algorithm != algo {
+				t.Errorf("LookupOID(%s) did not return %s (err=%v)", spec.OID, algo, err)
+			}
+		}
+		if got := len(spec.New().Sum(nil)); got != spec.Size {
+			t.Errorf("%s: digest has %d bytes, spec says %d", algo, got, spec.Size)
+		}
+	}
+
+	sha256Spec, _ := r.Lookup(SHA256)
+	if err := r.Register(Spec{Algorithm: "SHA-256-copy", Code: sha256Spec.Code, Size: 32, New: sha256Spec.New}); err == nil {
+		t.Error("Expected error registering a duplicate multihash code")
+	}
+}
+
+func TestRegistryPolicy(t *testing.T) {
+	r := newDefaultRegistry()
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	policy := []byte(`{
+		"SHA-256": {"status": "deprecated"},
+		"SHA-384": {"status": "verify-only", "verify_until": "2031-01-01T00:00:00Z"},
+		"BLAKE3": {"status": "forbidden"}
+	}`)
+	if err := r.ApplyPolicy(policy); err != nil {
+		t.Fatalf("ApplyPolicy failed: %v", err)
+	}
+
+	testCases := []struct {
+		algo            Algorithm
+		sign, verify    bool
+		verifyAfterDate bool
+	}{
+		{SHA3_512, true, true, true},
+		{SHA256, false, true, true},
+		{SHA384, false, true, false},
+		{BLAKE3, false, false, false},
+	}
+
+	for _, tc := range testCases {
+		if got := r.CheckSign(tc.algo) == nil; got != tc.sign {
+			t.Errorf("%s: CheckSign allowed=%v, expected %v", tc.algo, got, tc.sign)
+		}
+		if got := r.CheckVerify(tc.algo, now) == nil; got != tc.verify {
+			t.Errorf("%s: CheckVerify allowed=%v, expected %v", tc.algo, got, tc.verify)
+		}
+		if got := r.CheckVerify(tc.algo, now.AddDate(2, 0, 0)) == nil; got != tc.verifyAfterDate {
+			t.Errorf("%s: CheckVerify after sunset allowed=%v, expected %v", tc.algo, got, tc.verifyAfterDate)
+		}
+	}
+
+	// Invalid policies are rejected without changing any status
+	for _, invalid := range []string{
+		`{"SHA-3-512": {"status": "forbidden"}, "MD5": {"status": "forbidden"}}`,
+		`{"SHA-3-512": {"status": "retired"}}`,
+		`{"SHA-3-512": {"status": "verify-only"}}`,
+	} {
+		if err := r.ApplyPolicy([]byte(invalid)); err == nil {
+			t.Errorf("Expected error for policy %s", invalid)
+		}
+	}
+	if err := r.CheckSign(SHA3_512); err != nil {
+		t.Errorf("Rejected policy changed SHA-3-512 status: %v", err)
+	}
+}
@@ -0,0 +1,290 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	stdhash "hash"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zeebo/blake3"
+	"golang.org/x/crypto/sha3"
+)
+
+// Status is the policy status of a hash algorithm
+type Status int
+
+const (
+	// StatusAllowed algorithms may be used for new signatures and verification
+	StatusAllowed Status = iota
+	// StatusDeprecated algorithms are refused for new signatures but still verify
+	StatusDeprecated
+	// StatusVerifyOnly algorithms are refused for new signatures and verify only until a sunset date
+	StatusVerifyOnly
+	// StatusForbidden algorithms are refused everywhere
+	StatusForbidden
+)
+
+// String returns the policy name of the status
+func (s Status) String() string {
+	switch s {
+	case StatusAllowed:
+		return "allowed"
+	case StatusDeprecated:
+		return "deprecated"
+	case StatusVerifyOnly:
+		return "verify-only"
+	case StatusForbidden:
+		return "forbidden"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+// ParseStatus parses a policy status name
+func ParseStatus(s string) (Status, error) {
+	for _, status := range []Status{StatusAllowed, StatusDeprecated, StatusVerifyOnly, StatusForbidden} {
+		if status.String() == s {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown hash algorithm status: %q", s)
+}
+
+// Spec describes a registered hash algorithm
+type Spec struct {
+	// Algorithm is the algorithm name
+	Algorithm Algorithm
+	// Code is the multihash code
+	Code uint64
+	// Size is the digest length in bytes
+	Size int
+	// OID is the ASN.1 object identifier, nil if none is registered
+	OID asn1.ObjectIdentifier
+	// New creates a streaming hash
+	New func() stdhash.Hash
+	// Status is the policy status
+	Status Status
+	// VerifyUntil is the sunset date of a verify-only algorithm
+	VerifyUntil time.Time
+}
+
+// Registry maps algorithm names, multihash codes and OIDs to algorithm specs
+// and records the policy status of each algorithm
+type Registry struct {
+	mu    sync.RWMutex
+	specs map[Algorithm]*Spec
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{specs: make(map[Algorithm]*Spec)}
+}
+
+// DefaultRegistry holds the built-in algorithms and is consulted by Hash, New and Digest
+var DefaultRegistry = newDefaultRegistry()
+
+func newDefaultRegistry() *Registry {
+	r := NewRegistry()
+	for _, spec := range []Spec{
+		{Algorithm: SHA256, Code: 0x12, Size: 32, OID: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}, New: sha256.New},
+		{Algorithm: SHA384, Code: 0x20, Size: 48, OID: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}, New: sha512.New384},
+		{Algorithm: SHA512_256, Code: 0x1015, Size: 32, OID: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 6}, New: sha512.New512_256},
+		{Algorithm: SHA3_512, Code: 0x14, Size: 64, OID: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 10}, New: sha3.New512},
+		{Algorithm: BLAKE3, Code: 0x1e, Size: 32, New: func() stdhash.Hash { return blake3.New() }},
+	} {
+		if err := r.Register(spec); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// Register adds an algorithm. Names, multihash codes and OIDs must be unique.
+func (r *Registry) Register(spec Spec) error {
+	if spec.Algorithm == "" || spec.Size <= 0 || spec.New == nil {
+		return fmt.Errorf("incomplete hash algorithm spec: %q", spec.Algorithm)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.specs {
+		switch {
+		case existing.Algorithm == spec.Algorithm:
+			return fmt.Errorf("hash algorithm already registered: %s", spec.Algorithm)
+		case existing.Code == spec.Code:
+			return fmt.Errorf("multihash code 0x%x already registered for %s", spec.Code, existing.Algorithm)
+		case spec.OID != nil && existing.OID.Equal(spec.OID):
+			return fmt.Errorf("OID %s already registered for %s", spec.OID, existing.Algorithm)
+		}
+	}
+
+	s := spec
+	r.specs[spec.Algorithm] = &s
+	return nil
+}
+
+// Lookup returns the spec of an algorithm
+func (r *Registry) Lookup(algo Algorithm) (Spec, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	spec, ok := r.specs[algo]
+	if !ok {
+		return Spec{}, fmt.Errorf("unsupported hash algorithm: %s", algo)
+	}
+	return *spec, nil
+}
+
+// LookupCode returns the spec of the algorithm with a multihash code
+func (r *Registry) LookupCode(code uint64) (Spec, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, spec := range r.specs {
+		if spec.Code == code {
+			return *spec, nil
+		}
+	}
+	return Spec{}, fmt.Errorf("unsupported digest algorithm code: 0x%x", code)
+}
+
+// LookupOID returns the spec of the algorithm with an object identifier
+func (r *Registry) LookupOID(oid asn1.ObjectIdentifier) (Spec, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, spec := range r.specs {
+		if spec.OID != nil && spec.OID.Equal(oid) {
+			return *spec, nil
+		}
+	}
+	return Spec{}, fmt.Errorf("unsupported hash algorithm OID: %s", oid)
+}
+
+// Algorithms returns the registered algorithm names in sorted order
+func (r *Registry) Algorithms() []Algorithm {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	algos := make([]Algorithm, 0, len(r.specs))
+	for algo := range r.specs {
+		algos = append(algos, algo)
+	}
+	sort.Slice(algos, func(i, j int) bool { return algos[i] < algos[j] })
+	return algos
+}
+
+// SetStatus changes the policy status of an algorithm. verifyUntil is required
+// for StatusVerifyOnly and ignored otherwise.
+func (r *Registry) SetStatus(algo Algorithm, status Status, verifyUntil time.Time) error {
+	if status < StatusAllowed || status > StatusForbidden {
+		return fmt.Errorf("invalid hash algorithm status: %d", status)
+	}
+	if status == StatusVerifyOnly && verifyUntil.IsZero() {
+		return fmt.Errorf("verify-only status for %s requires a sunset date", algo)
+	}
+	if status != StatusVerifyOnly {
+		verifyUntil = time.Time{}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	spec, ok := r.specs[algo]
+	if !ok {
+		return fmt.Errorf("unsupported hash algorithm: %s", algo)
+	}
+	spec.Status = status
+	spec.VerifyUntil = verifyUntil
+	return nil
+}
+
+// CheckSign returns an error unless the algorithm may be used for new signatures
+func (r *Registry) CheckSign(algo Algorithm) error {
+	spec, err := r.Lookup(algo)
+	if err != nil {
+		return err
+	}
+	if spec.Status != StatusAllowed {
+		return fmt.Errorf("hash algorithm %s is %s and may not be used for new signatures", algo, spec.Status)
+	}
+	return nil
+}
+
+// CheckVerify returns an error unless digests of the algorithm may be verified at time now
+func (r *Registry) CheckVerify(algo Algorithm, now time.Time) error {
+	spec, err := r.Lookup(algo)
+	if err != nil {
+		return err
+	}
+	switch spec.Status {
+	case StatusForbidden:
+		return fmt.Errorf("hash algorithm %s is forbidden", algo)
+	case StatusVerifyOnly:
+		if !now.Before(spec.VerifyUntil) {
+			return fmt.Errorf("hash algorithm %s was retired on %s", algo, spec.VerifyUntil.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// PolicyEntry sets the status of one algorithm in a policy file
+type PolicyEntry struct {
+	// Status is the policy status name, e.g. "verify-only"
+	Status string `json:"status"`
+	// VerifyUntil is the sunset date of a verify-only algorithm
+	VerifyUntil time.Time `json:"verify_until,omitempty"`
+}
+
+// ApplyPolicy applies a JSON policy mapping algorithm names to statuses, e.g.
+// {"SHA-256": {"status": "verify-only", "verify_until": "2035-01-01T00:00:00Z"}}.
+// The policy is validated completely before any status changes.
+func (r *Registry) ApplyPolicy(data []byte) error {
+	var policy map[Algorithm]PolicyEntry
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return fmt.Errorf("failed to parse hash algorithm policy: %w", err)
+	}
+
+	statuses := make(map[Algorithm]Status, len(policy))
+	for algo, entry := range policy {
+		if _, err := r.Lookup(algo); err != nil {
+			return err
+		}
+		status, err := ParseStatus(entry.Status)
+		if err != nil {
+			return err
+		}
+		if status == StatusVerifyOnly && entry.VerifyUntil.IsZero() {
+			return fmt.Errorf("verify-only status for %s requires a sunset date", algo)
+		}
+		statuses[algo] = status
+	}
+
+	for algo, status := range statuses {
+		if err := r.SetStatus(algo, status, policy[algo].VerifyUntil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lookup returns the spec of an algorithm in the default registry
+func Lookup(algo Algorithm) (Spec, error) {
+	return DefaultRegistry.Lookup(algo)
+}
+
+// CheckSign checks the default registry's policy for new signatures
+func CheckSign(algo Algorithm) error {
+	return DefaultRegistry.CheckSign(algo)
+}
+
+// CheckVerify checks the default registry's policy for verification
+func CheckVerify(algo Algorithm, now time.Time) error {
+	return DefaultRegistry.CheckVerify(algo, now)
+}
@@ -0,0 +1,129 @@
+package hash
+
+import (
+	"fmt"
+	stdhash "hash"
+	"io"
+)
+
+// ProgressFunc is called as data is hashed with the total number of bytes processed so far
+type ProgressFunc func(processed int64)
+
+// New returns a streaming hash.Hash for an algorithm in the default registry
+func New(algo Algorithm) (stdhash.Hash, error) {
+	spec, err := DefaultRegistry.Lookup(algo)
+	if err != nil {
+		return nil, err
+	}
+	return spec.New(), nil
+}
+
+// MultiHasher computes several digests of the same data in a single pass. It
+// implements io.Writer, so large inputs can be hashed without being held in memory.
+type MultiHasher struct {
+	algos    []Algorithm
+	hashes   []stdhash.Hash
+	written  int64
+	progress ProgressFunc
+}
+
+// NewMultiHasher creates a hasher for the given algorithms
+func NewMultiHasher(algos ...Algorithm) (*MultiHasher, error) {
+	if len(algos) == 0 {
+		return nil, fmt.Errorf("no hash algorithms specified")
+	}
+
+	m := &MultiHasher{
+		algos:  make([]Algorithm, 0, len(algos)),
+		hashes: make([]stdhash.Hash, 0, len(algos)),
+	}
+	for _, algo := range algos {
+		if m.index(algo) >= 0 {
+			return nil, fmt.Errorf("duplicate hash algorithm: %s", algo)
+		}
+		h, err := New(algo)
+		if err != nil {
+			return nil, err
+		}
+		m.algos = append(m.algos, algo)
+		m.hashes = append(m.hashes, h)
+	}
+
+	return m, nil
+}
+
+// OnProgress registers a callback invoked after every write
+func (m *MultiHasher) OnProgress(fn ProgressFunc) {
+	m.progress = fn
+}
+
+// Write feeds data to every digest
+func (m *MultiHasher) Write(p []byte) (int, error) {
+	for _, h := range m.hashes {
+		// hash.Hash.Write never returns an error
+		h.Write(p)
+	}
+
+	m.written += int64(len(p))
+	if m.progress != nil {
+		m.progress(m.written)
+	}
+
+	return len(p), nil
+}
+
+// Written returns the number of bytes hashed so far
+func (m *MultiHasher) Written() int64 {
+	return m.written
+}
+
+// Sum returns the digest for one of the hasher's algorithms
+func (m *MultiHasher) Sum(algo Algorithm) ([]byte, error) {
+	i := m.index(algo)
+	if i < 0 {
+		return nil, fmt.Errorf("hash algorithm not computed: %s", algo)
+	}
+	return m.hashes[i].Sum(nil), nil
+}
+
+// Digest returns the tagged digest for one of the hasher's algorithms
+func (m *MultiHasher) Digest(algo Algorithm) (Digest, error) {
+	value, err := m.Sum(algo)
+	if err != nil {
+		return Digest{}, err
+	}
+	return Digest{Algorithm: algo, Value: value}, nil
+}
+
+// Sums returns the digests for all of the hasher's algorithms
+func (m *MultiHasher) Sums() map[Algorithm][]byte {
+	sums := make(map[Algorithm][]byte, len(m.algos))
+	for i, algo := range m.algos {
+		sums[algo] = m.hashes[i].Sum(nil)
+	}
+	return sums
+}
+
+func (m *MultiHasher) index(algo Algorithm) int {
+	for i, a := range m.algos {
+		if a == algo {
+			return i
+		}
+	}
+	return -1
+}
+
+// HashReader hashes everything read from r with each algorithm in a single pass
+func HashReader(r io.Reader, progress ProgressFunc, algos ...Algorithm) (map[Algorithm][]byte, error) {
+	m, err := NewMultiHasher(algos...)
+	if err != nil {
+		return nil, err
+	}
+	m.OnProgress(progress)
+
+	if _, err := io.Copy(m, r); err != nil {
+		return nil, fmt.Errorf("failed to read data: %w", err)
+	}
+
+	return m.Sums(), nil
+}
@@ -0,0 +1,411 @@
+// Package keyfile defines the encrypted private key file format. A key file
+// is a JSON envelope recording the key's algorithm, key version and owning
+// identity, with the private key sealed by ChaCha20-Poly1305 under a key
+// derived from a passphrase (scrypt or Argon2id) or from an X25519 exchange
+// with a recipient key. The metadata is authenticated, so a key cannot be
+// relabelled as another algorithm, identity or version.
+package keyfile
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+)
+
+// Key file versions
+const (
+	// Version is written by this package. It adds the key version, identity
+	// ID, Argon2id and recipient encryption.
+	Version = 2
+	// versionPassphraseOnly files hold only the algorithm and public key
+	versionPassphraseOnly = 1
+)
+
+// KDF names a passphrase key derivation function
+type KDF string
+
+// Supported key derivation functions
+const (
+	KDFScrypt   KDF = "scrypt"
+	KDFArgon2id KDF = "argon2id"
+)
+
+// Cost parameters for new key files, and bounds on the costs accepted from a
+// key file so a crafted file cannot exhaust memory or time
+const (
+	scryptN    = 1 << 15
+	scryptR    = 8
+	scryptP    = 1
+	scryptMaxN = 1 << 20
+
+	argon2Time       = 3
+	argon2Memory     = 64 * 1024 // KiB
+	argon2Threads    = 4
+	argon2MaxTime    = 16
+	argon2MaxMemory  = 1 << 21 // KiB
+	argon2MaxThreads = 64
+)
+
+// recipientInfo is the HKDF info string for recipient-encrypted key files
+const recipientInfo = "civic-attest key file v2 x25519"
+
+// Key is a private key and the metadata recorded with it
+type Key struct {
+	PrivateKey []byte
+	Algorithm  signatures.Algorithm
+	// KeyVersion is the identity key version the key belongs to; 0 if unrecorded
+	KeyVersion int
+	// IdentityID is the identity the key belongs to; empty if unrecorded
+	IdentityID string
+}
+
+// Metadata is the unencrypted, authenticated part of a key file
+type Metadata struct {
+	Version    int                  `json:"version"`
+	Algorithm  signatures.Algorithm `json:"algorithm"`
+	KeyVersion int                  `json:"key_version,omitempty"`
+	IdentityID string               `json:"identity_id,omitempty"`
+	PublicKey  []byte               `json:"public_key"`
+}
+
+// file is the JSON envelope of a key file. Exactly one of KDF (passphrase)
+// and Recipient (X25519) is set.
+type file struct {
+	Metadata
+	KDF        *kdfParams       `json:"kdf,omitempty"`
+	Recipient  *recipientParams `json:"recipient,omitempty"`
+	Cipher     string           `json:"cipher"`
+	Nonce      []byte           `json:"nonce"`
+	Ciphertext []byte           `json:"ciphertext"`
+}
+
+// kdfParams are the passphrase key derivation parameters of a key file
+type kdfParams struct {
+	Name KDF    `json:"name"`
+	Salt []byte `json:"salt"`
+	// scrypt
+	N int `json:"n,omitempty"`
+	R int `json:"r,omitempty"`
+	P int `json:"p,omitempty"`
+	// Argon2id
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+}
+
+// recipientParams are the X25519 parameters of a recipient-encrypted key file
+type recipientParams struct {
+	Name      string `json:"name"`
+	PublicKey []byte `json:"public_key"`
+	Ephemeral []byte `json:"ephemeral"`
+}
+
+// Encrypt seals a key under a passphrase
+func Encrypt(key *Key, passphrase string, kdf KDF) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase is empty")
+	}
+
+	params := &kdfParams{Name: kdf, Salt: make([]byte, 16)}
+	switch kdf {
+	case KDFScrypt:
+		params.N, params.R, params.P = scryptN, scryptR, scryptP
+	case KDFArgon2id:
+		params.Time, params.Memory, params.Threads = argon2Time, argon2Memory, argon2Threads
+	default:
+		return nil, fmt.Errorf("unsupported key derivation function: %q", kdf)
+	}
+	if _, err := rand.Read(params.Salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	f, err := newFile(key)
+	if err != nil {
+		return nil, err
+	}
+	f.KDF = params
+
+	secret, err := params.derive(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return f.seal(secret, key.PrivateKey)
+}
+
+// EncryptTo seals a key to a recipient's X25519 public key, for handing a key
+// to an operator or escrow without sharing a passphrase
+func EncryptTo(key *Key, recipient []byte) ([]byte, error) {
+	recipientKey, err := ecdh.X25519().NewPublicKey(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient key: %w", err)
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	shared, err := ephemeral.ECDH(recipientKey)
+	if err != nil {
+		return nil, fmt.Errorf("key exchange failed: %w", err)
+	}
+
+	f, err := newFile(key)
+	if err != nil {
+		return nil, err
+	}
+	f.Recipient = &recipientParams{
+		Name:      "x25519",
+		PublicKey: recipientKey.Bytes(),
+		Ephemeral: ephemeral.PublicKey().Bytes(),
+	}
+
+	secret, err := f.Recipient.derive(shared)
+	if err != nil {
+		return nil, err
+	}
+	return f.seal(secret, key.PrivateKey)
+}
+
+// Decrypt opens a passphrase-encrypted key file
+func Decrypt(data []byte, passphrase string) (*Key, error) {
+	f, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if f.KDF == nil {
+		return nil, fmt.Errorf("key file is encrypted to a recipient key, not a passphrase")
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase is empty")
+	}
+
+	secret, err := f.KDF.derive(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return f.open(secret, "wrong passphrase or corrupted file")
+}
+
+// DecryptWith opens a recipient-encrypted key file with the recipient's X25519 private key
+func DecryptWith(data []byte, identity []byte) (*Key, error) {
+	f, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if f.Recipient == nil {
+		return nil, fmt.Errorf("key file is passphrase-encrypted, not encrypted to a recipient key")
+	}
+	if f.Recipient.Name != "x25519" {
+		return nil, fmt.Errorf("unsupported recipient key type: %q", f.Recipient.Name)
+	}
+
+	key, err := ecdh.X25519().NewPrivateKey(identity)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity key: %w", err)
+	}
+	if !bytes.Equal(key.PublicKey().Bytes(), f.Recipient.PublicKey) {
+		return nil, fmt.Errorf("key file is encrypted to a different recipient")
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(f.Recipient.Ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+	shared, err := key.ECDH(ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("key exchange failed: %w", err)
+	}
+
+	secret, err := f.Recipient.derive(shared)
+	if err != nil {
+		return nil, err
+	}
+	return f.open(secret, "corrupted file")
+}
+
+// ReadMetadata returns a key file's metadata without decrypting it
+func ReadMetadata(data []byte) (*Metadata, error) {
+	f, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return &f.Metadata, nil
+}
+
+// IsRecipientEncrypted reports whether a key file is encrypted to a recipient key
+func IsRecipientEncrypted(data []byte) bool {
+	f, err := parse(data)
+	return err == nil && f.Recipient != nil
+}
+
+// Check refuses a key that does not match the algorithm, identity or key
+// version it is about to be used as. Empty expectations and unrecorded
+// metadata are not compared.
+func (k *Key) Check(algo signatures.Algorithm, identityID string, keyVersion int) error {
+	if algo != "" && k.Algorithm != algo {
+		return fmt.Errorf("key is an %s key, expected %s", k.Algorithm, algo)
+	}
+	if identityID != "" && k.IdentityID != "" && k.IdentityID != identityID {
+		return fmt.Errorf("key belongs to identity %s, not %s", k.IdentityID, identityID)
+	}
+	if keyVersion != 0 && k.KeyVersion != 0 && k.KeyVersion != keyVersion {
+		return fmt.Errorf("key is version %d, expected version %d", k.KeyVersion, keyVersion)
+	}
+	return nil
+}
+
+// newFile builds the metadata of a new key file
+func newFile(key *Key) (*file, error) {
+	if key.KeyVersion < 0 {
+		return nil, fmt.Errorf("invalid key version: %d", key.KeyVersion)
+	}
+	publicKey, err := signatures.PublicKeyFromPrivate(key.PrivateKey, key.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s private key: %w", key.Algorithm, err)
+	}
+	return &file{
+		Metadata: Metadata{
+			Version:    Version,
+			Algorithm:  key.Algorithm,
+			KeyVersion: key.KeyVersion,
+			IdentityID: key.IdentityID,
+			PublicKey:  publicKey,
+		},
+		Cipher: "chacha20-poly1305",
+	}, nil
+}
+
+// parse decodes and checks a key file envelope
+func parse(data []byte) (*file, error) {
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %w", err)
+	}
+	switch f.Version {
+	case Version:
+		if (f.KDF == nil) == (f.Recipient == nil) {
+			return nil, fmt.Errorf("key file must be encrypted with exactly one of a passphrase or a recipient key")
+		}
+	case versionPassphraseOnly:
+		if f.KDF == nil || f.Recipient != nil || f.KeyVersion != 0 || f.IdentityID != "" {
+			return nil, fmt.Errorf("malformed version 1 key file")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key file version: %d", f.Version)
+	}
+	if f.Cipher != "chacha20-poly1305" {
+		return nil, fmt.Errorf("unsupported key file cipher: %q", f.Cipher)
+	}
+	if len(f.Nonce) != chacha20poly1305.NonceSize {
+		return nil, fmt.Errorf("invalid key file nonce length: %d", len(f.Nonce))
+	}
+	return &f, nil
+}
+
+// seal encrypts the private key under the derived secret
+func (f *file) seal(secret, privateKey []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(secret)
+	if err != nil {
+		return nil, err
+	}
+	f.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	f.Ciphertext = aead.Seal(nil, f.Nonce, privateKey, f.associatedData())
+
+	return json.MarshalIndent(f, "", "  ")
+}
+
+// open decrypts the private key and checks it against the recorded public key
+func (f *file) open(secret []byte, failure string) (*Key, error) {
+	aead, err := chacha20poly1305.New(secret)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := aead.Open(nil, f.Nonce, f.Ciphertext, f.associatedData())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key file: %s", failure)
+	}
+
+	publicKey, err := signatures.PublicKeyFromPrivate(privateKey, f.Algorithm)
+	if err != nil || !bytes.Equal(publicKey, f.PublicKey) {
+		return nil, fmt.Errorf("key file private key does not match its public key")
+	}
+
+	return &Key{
+		PrivateKey: privateKey,
+		Algorithm:  f.Algorithm,
+		KeyVersion: f.KeyVersion,
+		IdentityID: f.IdentityID,
+	}, nil
+}
+
+// associatedData binds the metadata to the ciphertext. Version 1 files bind
+// only the algorithm and public key.
+func (f *file) associatedData() []byte {
+	if f.Version == versionPassphraseOnly {
+		ad := append([]byte(f.Algorithm), 0)
+		return append(ad, f.PublicKey...)
+	}
+
+	var ad []byte
+	for _, field := range [][]byte{
+		[]byte(fmt.Sprintf("civic-attest key file v%d", f.Version)),
+		[]byte(f.Algorithm),
+		binary.BigEndian.AppendUint32(nil, uint32(f.KeyVersion)),
+		[]byte(f.IdentityID),
+		f.PublicKey,
+	} {
+		ad = binary.BigEndian.AppendUint32(ad, uint32(len(field)))
+		ad = append(ad, field...)
+	}
+	return ad
+}
+
+// derive derives the file key from the passphrase
+func (p *kdfParams) derive(passphrase string) ([]byte, error) {
+	if len(p.Salt) < 16 {
+		return nil, fmt.Errorf("key file salt too short: %d bytes", len(p.Salt))
+	}
+
+	switch p.Name {
+	case KDFScrypt:
+		if p.N < 2 || p.N > scryptMaxN || p.N&(p.N-1) != 0 || p.R < 1 || p.R > 32 || p.P < 1 || p.P > 16 {
+			return nil, fmt.Errorf("invalid scrypt parameters: N=%d r=%d p=%d", p.N, p.R, p.P)
+		}
+		key, err := scrypt.Key([]byte(passphrase), p.Salt, p.N, p.R, p.P, chacha20poly1305.KeySize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key: %w", err)
+		}
+		return key, nil
+	case KDFArgon2id:
+		if p.Time < 1 || p.Time > argon2MaxTime || p.Memory < 8*uint32(p.Threads) || p.Memory > argon2MaxMemory ||
+			p.Threads < 1 || p.Threads > argon2MaxThreads {
+			return nil, fmt.Errorf("invalid argon2id parameters: time=%d memory=%d threads=%d", p.Time, p.Memory, p.Threads)
+		}
+		return argon2.IDKey([]byte(passphrase), p.Salt, p.Time, p.Memory, p.Threads, chacha20poly1305.KeySize), nil
+	default:
+		return nil, fmt.Errorf("unsupported key derivation function: %q", p.Name)
+	}
+}
+
+// derive derives the file key from the X25519 shared secret, bound to both public keys
+func (p *recipientParams) derive(shared []byte) ([]byte, error) {
+	salt := append(append([]byte(nil), p.Ephemeral...), p.PublicKey...)
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(recipientInfo)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,59 @@
+package keyfile
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+)
+
+// pemType is the PEM block type of an unencrypted PKCS#8 private key
+const pemType = "PRIVATE KEY"
+
+// ecPEMType is the PEM block type of an unencrypted SEC 1 EC private key, as
+// written by openssl ecparam
+const ecPEMType = "EC PRIVATE KEY"
+
+// ExportPEM encodes a key as unencrypted PKCS#8 PEM, for moving it into other
+// tools. The key version and identity are not carried over.
+func ExportPEM(key *Key) ([]byte, error) {
+	der, err := signatures.MarshalPrivateKey(key.PrivateKey, key.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemType, Bytes: der}), nil
+}
+
+// ImportPEM decodes an unencrypted PKCS#8 or SEC 1 PEM private key. The caller
+// records the key version and identity before encrypting it into a key file.
+func ImportPEM(data []byte) (*Key, error) {
+	block, rest := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	if next, _ := pem.Decode(rest); next != nil {
+		return nil, fmt.Errorf("more than one PEM block found")
+	}
+
+	der := block.Bytes
+	switch block.Type {
+	case pemType:
+	case ecPEMType:
+		ecKey, err := x509.ParseECPrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse EC private key: %w", err)
+		}
+		if der, err = x509.MarshalPKCS8PrivateKey(ecKey); err != nil {
+			return nil, fmt.Errorf("failed to encode EC private key: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected PEM block type: %s", block.Type)
+	}
+
+	privateKey, algo, err := signatures.ParsePrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{PrivateKey: privateKey, Algorithm: algo}, nil
+}
@@ -0,0 +1,365 @@
+package merkle
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
+)
+
+// NodeStore persists the hashes of perfect subtrees. The node at (level, index)
+// covers leaves [index<<level, (index+1)<<level); level 0 holds leaf hashes.
+type NodeStore interface {
+	// GetNode returns the hash of the node at the given position
+	GetNode(level, index int) ([]byte, error)
+	// SetNode records the hash of a newly completed node
+	SetNode(level, index int, nodeHash []byte) error
+}
+
+// MemoryNodeStore keeps node hashes in memory, one slice per level
+type MemoryNodeStore struct {
+	levels [][][]byte
+}
+
+// NewMemoryNodeStore creates an empty in-memory node store
+func NewMemoryNodeStore() *MemoryNodeStore {
+	return &MemoryNodeStore{levels: make([][][]byte, 0)}
+}
+
+// GetNode implements NodeStore
+func (s *MemoryNodeStore) GetNode(level, index int) ([]byte, error) {
+	if level < 0 || level >= len(s.levels) || index < 0 || index >= len(s.levels[level]) {
+		return nil, fmt.Errorf("node not found: level %d index %d", level, index)
+	}
+	return s.levels[level][index], nil
+}
+
+// SetNode implements NodeStore. Nodes must be written in index order per level.
+func (s *MemoryNodeStore) SetNode(level, index int, nodeHash []byte) error {
+	for len(s.levels) <= level {
+		s.levels = append(s.levels, make([][]byte, 0))
+	}
+
+	if index != len(s.levels[level]) {
+		return fmt.Errorf("out of order node write: level %d index %d, expected index %d",
+			level, index, len(s.levels[level]))
+	}
+
+	s.levels[level] = append(s.levels[level], nodeHash)
+	return nil
+}
+
+// CompactRange is the right-edge frontier of a tree: the roots of the perfect
+// subtrees covering [0, size), largest first. It is all that is needed to
+// append a leaf and compute the root.
+type CompactRange struct {
+	hasher Hasher
+	size   int
+	hashes [][]byte
+}
+
+// NewCompactRange creates an empty compact range
+func NewCompactRange(hasher Hasher) *CompactRange {
+	return &CompactRange{hasher: hasher, hashes: make([][]byte, 0)}
+}
+
+// Size returns the number of leaves covered by the range
+func (c *CompactRange) Size() int {
+	return c.size
+}
+
+// Append adds a leaf hash to the range. visit, if not nil, is called for the
+// leaf and for every interior node completed by the append.
+func (c *CompactRange) Append(leafHash []byte, visit func(level, index int, nodeHash []byte) error) error {
+	if visit != nil {
+		if err := visit(0, c.size, leafHash); err != nil {
+			return err
+		}
+	}
+
+	// Each trailing one bit of the old size is a subtree of equal height to merge with
+	merges := bits.TrailingZeros(^uint(c.size))
+	node := leafHash
+	hashes := c.hashes
+	for level := 1; level <= merges; level++ {
+		left := hashes[len(hashes)-1]
+		hashes = hashes[:len(hashes)-1]
+
+		parent, err := c.hasher.HashChildren(left, node)
+		if err != nil {
+			return err
+		}
+		node = parent
+
+		if visit != nil {
+			if err := visit(level, c.size>>level, node); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.hashes = append(hashes, node)
+	c.size++
+	return nil
+}
+
+// Root returns the root hash of the range, or nil if it is empty
+func (c *CompactRange) Root() ([]byte, error) {
+	if len(c.hashes) == 0 {
+		return nil, nil
+	}
+
+	root := c.hashes[len(c.hashes)-1]
+	for i := len(c.hashes) - 2; i >= 0; i-- {
+		var err error
+		if root, err = c.hasher.HashChildren(c.hashes[i], root); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+// IncrementalTree is an append-only Merkle tree with the same shape and roots
+// as Tree. Appends cost O(log n): only the frontier is rehashed, and completed
+// node hashes are written to a NodeStore for proofs and historical roots.
+type IncrementalTree struct {
+	hasher   Hasher
+	store    NodeStore
+	frontier *CompactRange
+	root     []byte
+}
+
+// NewIncrementalTree creates an empty in-memory tree using the default hashing scheme
+func NewIncrementalTree(hashAlgo hash.Algorithm) *IncrementalTree {
+	hasher := Hasher{Algorithm: hashAlgo, Scheme: DefaultHashScheme}
+	return &IncrementalTree{
+		hasher:   hasher,
+		store:    NewMemoryNodeStore(),
+		frontier: NewCompactRange(hasher),
+	}
+}
+
+// OpenIncrementalTree opens a tree of the given size whose node hashes are held in store
+func OpenIncrementalTree(hasher Hasher, store NodeStore, size int) (*IncrementalTree, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid tree size: %d", size)
+	}
+
+	// Rebuild the frontier from the perfect subtrees that make up size
+	frontier := NewCompactRange(hasher)
+	offset := 0
+	for level := bits.Len(uint(size)) - 1; level >= 0; level-- {
+		if size&(1<<level) == 0 {
+			continue
+		}
+		h, err := store.GetNode(level, offset>>level)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load frontier: %w", err)
+		}
+		frontier.hashes = append(frontier.hashes, h)
+		offset += 1 << level
+	}
+	frontier.size = size
+
+	root, err := frontier.Root()
+	if err != nil {
+		return nil, err
+	}
+
+	return &IncrementalTree{
+		hasher:   hasher,
+		store:    store,
+		frontier: frontier,
+		root:     root,
+	}, nil
+}
+
+// Hasher returns the hasher for the tree's algorithm and scheme
+func (t *IncrementalTree) Hasher() Hasher {
+	return t.hasher
+}
+
+// Append adds a new leaf to the tree
+func (t *IncrementalTree) Append(data []byte) error {
+	leafHash, err := t.hasher.HashLeaf(data)
+	if err != nil {
+		return fmt.Errorf("failed to hash leaf data: %w", err)
+	}
+
+	if err := t.frontier.Append(leafHash, t.store.SetNode); err != nil {
+		return fmt.Errorf("failed to append leaf: %w", err)
+	}
+
+	root, err := t.frontier.Root()
+	if err != nil {
+		return err
+	}
+	t.root = root
+
+	return nil
+}
+
+// RootHash returns the root hash of the tree
+func (t *IncrementalTree) RootHash() []byte {
+	return t.root
+}
+
+// Size returns the number of leaves in the tree
+func (t *IncrementalTree) Size() int {
+	return t.frontier.Size()
+}
+
+// RootAt returns the root hash the tree had when it contained size leaves
+func (t *IncrementalTree) RootAt(size int) ([]byte, error) {
+	if size < 0 || size > t.Size() {
+		return nil, fmt.Errorf("invalid tree size: %d", size)
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	return t.subtreeHash(0, size)
+}
+
+// GenerateInclusionProof generates a proof that a leaf at the given index is in the tree
+func (t *IncrementalTree) GenerateInclusionProof(leafIndex int) (*InclusionProof, error) {
+	return t.InclusionProofAt(leafIndex, t.Size())
+}
+
+// GenerateConsistencyProof generates a proof that the tree at oldSize is consistent with the current size
+func (t *IncrementalTree) GenerateConsistencyProof(oldSize int) (*ConsistencyProof, error) {
+	if oldSize < 0 || oldSize > t.Size() {
+		return nil, fmt.Errorf("invalid old size: %d", oldSize)
+	}
+
+	path := make([][]byte, 0)
+	if oldSize > 0 && oldSize < t.Size() {
+		if err := t.subproof(oldSize, 0, t.Size(), true, &path); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ConsistencyProof{
+		OldSize: oldSize,
+		NewSize: t.Size(),
+		Path:    path,
+	}, nil
+}
+
+// InclusionProofAt generates an inclusion proof for leafIndex against the root
+// the tree had when it contained size leaves
+func (t *IncrementalTree) InclusionProofAt(leafIndex, size int) (*InclusionProof, error) {
+	if size < 0 || size > t.Size() {
+		return nil, fmt.Errorf("invalid tree size: %d", size)
+	}
+	if leafIndex < 0 || leafIndex >= size {
+		return nil, fmt.Errorf("invalid leaf index: %d", leafIndex)
+	}
+
+	leafHash, err := t.store.GetNode(0, leafIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	path := make([][]byte, 0)
+	if err := t.path(leafIndex, 0, size, &path); err != nil {
+		return nil, err
+	}
+
+	return &InclusionProof{
+		LeafIndex: leafIndex,
+		LeafHash:  leafHash,
+		TreeSize:  size,
+		Path:      path,
+	}, nil
+}
+
+// path implements PATH(m, D[n]) from RFC 9162 over leaves [offset, offset+n)
+func (t *IncrementalTree) path(m, offset, n int, path *[][]byte) error {
+	if n == 1 {
+		return nil
+	}
+
+	k := splitPoint(n)
+	var sibling []byte
+	var err error
+	if m < k {
+		if err = t.path(m, offset, k, path); err != nil {
+			return err
+		}
+		sibling, err = t.subtreeHash(offset+k, n-k)
+	} else {
+		if err = t.path(m-k, offset+k, n-k, path); err != nil {
+			return err
+		}
+		sibling, err = t.subtreeHash(offset, k)
+	}
+	if err != nil {
+		return err
+	}
+
+	*path = append(*path, sibling)
+	return nil
+}
+
+// subproof implements SUBPROOF(m, D[n], b) from RFC 9162 over leaves [offset, offset+n)
+func (t *IncrementalTree) subproof(m, offset, n int, complete bool, path *[][]byte) error {
+	if m == n {
+		if !complete {
+			h, err := t.subtreeHash(offset, n)
+			if err != nil {
+				return err
+			}
+			*path = append(*path, h)
+		}
+		return nil
+	}
+
+	k := splitPoint(n)
+	var sibling []byte
+	var err error
+	if m <= k {
+		if err = t.subproof(m, offset, k, complete, path); err != nil {
+			return err
+		}
+		sibling, err = t.subtreeHash(offset+k, n-k)
+	} else {
+		if err = t.subproof(m-k, offset+k, n-k, false, path); err != nil {
+			return err
+		}
+		sibling, err = t.subtreeHash(offset, k)
+	}
+	if err != nil {
+		return err
+	}
+
+	*path = append(*path, sibling)
+	return nil
+}
+
+// subtreeHash returns MTH(D[offset:offset+n]) for a subtree on the RFC 9162
+// split boundaries. Perfect subtrees are read from the store; the rest are
+// folded from the perfect subtrees along their right edge.
+func (t *IncrementalTree) subtreeHash(offset, n int) ([]byte, error) {
+	if n&(n-1) == 0 && offset%n == 0 {
+		return t.store.GetNode(bits.TrailingZeros(uint(n)), offset/n)
+	}
+
+	k := splitPoint(n)
+	left, err := t.subtreeHash(offset, k)
+	if err != nil {
+		return nil, err
+	}
+	right, err := t.subtreeHash(offset+k, n-k)
+	if err != nil {
+		return nil, err
+	}
+	return t.hasher.HashChildren(left, right)
+}
+
+// NodeHash returns the stored hash of the perfect subtree at (level, index)
+func (t *IncrementalTree) NodeHash(level, index int) ([]byte, error) {
+	if level < 0 || level >= bits.UintSize-1 || index < 0 || (index+1)<<level > t.Size() {
+		return nil, fmt.Errorf("node not in tree: level %d index %d", level, index)
+	}
+	return t.store.GetNode(level, index)
+}
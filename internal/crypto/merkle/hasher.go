@@ -0,0 +1,106 @@
+package merkle
+
+import (
+	"fmt"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
+)
+
+// HashScheme identifies how leaf and interior node hashes are computed
+type HashScheme int
+
+const (
+	// HashSchemeV0 hashes leaves and interior nodes without domain separation.
+	// It is kept only so that trees built before HashSchemeV1 can be rebuilt.
+	HashSchemeV0 HashScheme = 0
+	// HashSchemeV1 prefixes leaves with 0x00 and interior nodes with 0x01 (RFC 9162 §2.1.1)
+	HashSchemeV1 HashScheme = 1
+
+	// DefaultHashScheme is the scheme used for new trees
+	DefaultHashScheme = HashSchemeV1
+)
+
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// String returns the scheme name
+func (s HashScheme) String() string {
+	switch s {
+	case HashSchemeV0:
+		return "v0-legacy"
+	case HashSchemeV1:
+		return "v1-rfc9162"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// Hasher computes leaf and interior node hashes for one tree hashing scheme
+type Hasher struct {
+	Algorithm hash.Algorithm
+	Scheme    HashScheme
+}
+
+// NewHasher creates a hasher for the given algorithm and scheme
+func NewHasher(hashAlgo hash.Algorithm, scheme HashScheme) (Hasher, error) {
+	if scheme != HashSchemeV0 && scheme != HashSchemeV1 {
+		return Hasher{}, fmt.Errorf("unsupported tree hash scheme: %s", scheme)
+	}
+	return Hasher{Algorithm: hashAlgo, Scheme: scheme}, nil
+}
+
+// HashLeaf computes the hash of a leaf from its data
+func (h Hasher) HashLeaf(data []byte) ([]byte, error) {
+	var input []byte
+	switch h.Scheme {
+	case HashSchemeV0:
+		input = data
+	case HashSchemeV1:
+		input = make([]byte, 0, 1+len(data))
+		input = append(input, leafPrefix)
+		input = append(input, data...)
+	default:
+		return nil, fmt.Errorf("unsupported tree hash scheme: %s", h.Scheme)
+	}
+
+	leafHash, err := hash.Hash(input, h.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash leaf: %w", err)
+	}
+	return leafHash, nil
+}
+
+// HashChildren computes the hash of an interior node from its children. Both
+// children must be digests of the hasher's algorithm, so a proof cannot pass
+// off other data, such as a leaf's contents, as a node hash.
+func (h Hasher) HashChildren(left, right []byte) ([]byte, error) {
+	spec, err := hash.Lookup(h.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if len(left) != spec.Size || len(right) != spec.Size {
+		return nil, fmt.Errorf("invalid node hash length: %d and %d bytes, expected %d for %s",
+			len(left), len(right), spec.Size, h.Algorithm)
+	}
+
+	var input []byte
+	switch h.Scheme {
+	case HashSchemeV0:
+		input = make([]byte, 0, len(left)+len(right))
+	case HashSchemeV1:
+		input = make([]byte, 0, 1+len(left)+len(right))
+		input = append(input, nodePrefix)
+	default:
+		return nil, fmt.Errorf("unsupported tree hash scheme: %s", h.Scheme)
+	}
+	input = append(input, left...)
+	input = append(input, right...)
+
+	nodeHash, err := hash.Hash(input, h.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash interior node: %w", err)
+	}
+	return nodeHash, nil
+}
@@ -1,7 +1,6 @@
 package merkle
 
 import (
-	"bytes"
 	"fmt"
 
 	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
@@ -20,26 +19,46 @@ type Node struct {
 
 // Tree represents a binary append-only Merkle tree
 type Tree struct {
-	Root      *Node
-	Leaves    []*Node
-	HashAlgo  hash.Algorithm
-	treeSize  int
+	Root     *Node
+	Leaves   []*Node
+	HashAlgo hash.Algorithm
+	// Scheme is the tree hashing scheme used for leaves and interior nodes
+	Scheme   HashScheme
+	treeSize int
 }
 
-// NewTree creates a new Merkle tree
+// NewTree creates a new Merkle tree using the default hashing scheme
 func NewTree(hashAlgo hash.Algorithm) *Tree {
 	return &Tree{
 		Root:     nil,
 		Leaves:   make([]*Node, 0),
 		HashAlgo: hashAlgo,
+		Scheme:   DefaultHashScheme,
 		treeSize: 0,
 	}
 }
 
+// NewTreeWithScheme creates a new Merkle tree using the given hashing scheme.
+// HashSchemeV0 is only meant for rebuilding trees created before domain separation.
+func NewTreeWithScheme(hashAlgo hash.Algorithm, scheme HashScheme) (*Tree, error) {
+	if _, err := NewHasher(hashAlgo, scheme); err != nil {
+		return nil, err
+	}
+
+	t := NewTree(hashAlgo)
+	t.Scheme = scheme
+	return t, nil
+}
+
+// Hasher returns the hasher for the tree's algorithm and scheme
+func (t *Tree) Hasher() Hasher {
+	return Hasher{Algorithm: t.HashAlgo, Scheme: t.Scheme}
+}
+
 // Append adds a new leaf to the tree
 func (t *Tree) Append(data []byte) error {
 	// Compute hash of the data
-	leafHash, err := hash.Hash(data, t.HashAlgo)
+	leafHash, err := t.Hasher().HashLeaf(data)
 	if err != nil {
 		return fmt.Errorf("failed to hash leaf data: %w", err)
 	}
@@ -55,21 +74,25 @@ func (t *Tree) Append(data []byte) error {
 	t.treeSize++
 
 	// Rebuild the tree
-	t.rebuildTree()
+	if err := t.rebuildTree(); err != nil {
+		t.Leaves = t.Leaves[:len(t.Leaves)-1]
+		t.treeSize--
+		return err
+	}
 
 	return nil
 }
 
 // rebuildTree rebuilds the tree from leaves
-func (t *Tree) rebuildTree() {
+func (t *Tree) rebuildTree() error {
 	if len(t.Leaves) == 0 {
 		t.Root = nil
-		return
+		return nil
 	}
 
 	if len(t.Leaves) == 1 {
 		t.Root = t.Leaves[0]
-		return
+		return nil
 	}
 
 	// Build tree bottom-up
@@ -82,7 +105,10 @@ func (t *Tree) rebuildTree() {
 		for i := 0; i < len(currentLevel); i += 2 {
 			if i+1 < len(currentLevel) {
 				// Combine two nodes
-				parent := t.combineNodes(currentLevel[i], currentLevel[i+1])
+				parent, err := t.combineNodes(currentLevel[i], currentLevel[i+1])
+				if err != nil {
+					return err
+				}
 				nextLevel = append(nextLevel, parent)
 			} else {
 				// Odd node out, promote to next level
@@ -94,20 +120,22 @@ func (t *Tree) rebuildTree() {
 	}
 
 	t.Root = currentLevel[0]
+	return nil
 }
 
 // combineNodes combines two nodes into a parent node
-func (t *Tree) combineNodes(left, right *Node) *Node {
-	// Concatenate hashes: hash(left || right)
-	combined := append(left.Hash, right.Hash...)
-	parentHash, _ := hash.Hash(combined, t.HashAlgo)
+func (t *Tree) combineNodes(left, right *Node) (*Node, error) {
+	parentHash, err := t.Hasher().HashChildren(left.Hash, right.Hash)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Node{
 		Hash:   parentHash,
 		Left:   left,
 		Right:  right,
 		IsLeaf: false,
-	}
+	}, nil
 }
 
 // RootHash returns the root hash of the tree
@@ -131,51 +159,52 @@ type InclusionProof struct {
 	Path      [][]byte // Hashes along the path from leaf to root
 }
 
-// GenerateInclusionProof generates a proof that a leaf at the given index is in the tree
+// GenerateInclusionProof generates a proof that a leaf at the given index is in the tree.
+// The path follows RFC 9162 §2.1.3.1 and is ordered from the leaf up to the root.
 func (t *Tree) GenerateInclusionProof(leafIndex int) (*InclusionProof, error) {
 	if leafIndex < 0 || leafIndex >= len(t.Leaves) {
 		return nil, fmt.Errorf("invalid leaf index: %d", leafIndex)
 	}
 
-	leaf := t.Leaves[leafIndex]
+	// Walk down from the root, recording the sibling of every node on the way
 	path := make([][]byte, 0)
+	node := t.Root
+	offset, size := 0, t.Size()
+	for size > 1 {
+		k := splitPoint(size)
+		if leafIndex-offset < k {
+			path = append(path, node.Right.Hash)
+			node = node.Left
+			size = k
+		} else {
+			path = append(path, node.Left.Hash)
+			node = node.Right
+			offset += k
+			size -= k
+		}
+	}
 
-	// Build path from leaf to root
-	t.buildPath(leaf, &path)
+	// Siblings were collected root first
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
 
 	return &InclusionProof{
 		LeafIndex: leafIndex,
-		LeafHash:  leaf.Hash,
+		LeafHash:  t.Leaves[leafIndex].Hash,
 		TreeSize:  t.Size(),
 		Path:      path,
 	}, nil
 }
 
-// buildPath recursively builds the path from a node to the root
-func (t *Tree) buildPath(node *Node, path *[][]byte) {
-	// Find the node's sibling and add to path
-	// This is a simplified implementation
-	// A production implementation would traverse the tree structure
-	for i, leaf := range t.Leaves {
-		if bytes.Equal(leaf.Hash, node.Hash) {
-			// Add sibling hashes to path
-			sibling := i ^ 1 // XOR with 1 to get sibling index
-			if sibling < len(t.Leaves) {
-				*path = append(*path, t.Leaves[sibling].Hash)
-			}
-			break
-		}
-	}
-}
-
-// VerifyInclusionProof verifies an inclusion proof
+// VerifyInclusionProof verifies an inclusion proof against the current root
 func (t *Tree) VerifyInclusionProof(proof *InclusionProof) bool {
-	if proof.LeafIndex < 0 || proof.LeafIndex >= len(t.Leaves) {
+	if proof.TreeSize != t.Size() {
 		return false
 	}
 
-	leaf := t.Leaves[proof.LeafIndex]
-	return bytes.Equal(leaf.Hash, proof.LeafHash)
+	valid, err := VerifyInclusion(t.Hasher(), proof.LeafHash, proof.LeafIndex, proof.TreeSize, proof.Path, t.RootHash())
+	return err == nil && valid
 }
 
 // ConsistencyProof represents a proof that two tree states are consistent
@@ -185,17 +214,42 @@ type ConsistencyProof struct {
 	Path    [][]byte
 }
 
-// GenerateConsistencyProof generates a proof that the tree at oldSize is consistent with newSize
+// GenerateConsistencyProof generates a proof that the tree at oldSize is consistent with newSize.
+// The path follows RFC 9162 §2.1.4.1; it is empty when oldSize is 0 or equals the current size.
 func (t *Tree) GenerateConsistencyProof(oldSize int) (*ConsistencyProof, error) {
 	if oldSize < 0 || oldSize > t.Size() {
 		return nil, fmt.Errorf("invalid old size: %d", oldSize)
 	}
 
-	// Simplified consistency proof
-	// A production implementation would properly compute the consistency path
+	path := make([][]byte, 0)
+	if oldSize > 0 && oldSize < t.Size() {
+		t.subproof(oldSize, t.Root, t.Size(), true, &path)
+	}
+
 	return &ConsistencyProof{
 		OldSize: oldSize,
 		NewSize: t.Size(),
-		Path:    make([][]byte, 0),
+		Path:    path,
 	}, nil
 }
+
+// subproof implements SUBPROOF(m, D[n], b) from RFC 9162 over the subtree rooted at node
+func (t *Tree) subproof(m int, node *Node, n int, complete bool, path *[][]byte) {
+	if m == n {
+		// The old tree is this whole subtree; its hash is only needed
+		// when the verifier cannot derive it from the old root
+		if !complete {
+			*path = append(*path, node.Hash)
+		}
+		return
+	}
+
+	k := splitPoint(n)
+	if m <= k {
+		t.subproof(m, node.Left, k, complete, path)
+		*path = append(*path, node.Right.Hash)
+	} else {
+		t.subproof(m-k, node.Right, n-k, false, path)
+		*path = append(*path, node.Left.Hash)
+	}
+}
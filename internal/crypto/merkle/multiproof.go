@@ -0,0 +1,171 @@
+package merkle
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// MultiInclusionProof proves that several leaves are included in one tree.
+// Subtrees shared by more than one leaf's audit path appear only once.
+type MultiInclusionProof struct {
+	// LeafIndices are the proven leaf indices in ascending order
+	LeafIndices []int
+	// LeafHashes are the hashes of the proven leaves, in the order of LeafIndices
+	LeafHashes [][]byte
+	TreeSize   int
+	// Path holds the roots of the subtrees that contain none of the proven
+	// leaves, in depth-first, left-to-right order
+	Path [][]byte
+}
+
+// GenerateMultiInclusionProof generates a single proof for a set of leaf indices
+func (t *IncrementalTree) GenerateMultiInclusionProof(leafIndices []int) (*MultiInclusionProof, error) {
+	return t.multiInclusionProof(leafIndices, t.Size())
+}
+
+// multiInclusionProof generates a multi-leaf proof in the tree of the given size
+func (t *IncrementalTree) multiInclusionProof(leafIndices []int, size int) (*MultiInclusionProof, error) {
+	if size < 0 || size > t.Size() {
+		return nil, fmt.Errorf("invalid tree size: %d", size)
+	}
+
+	indices, err := normalizeIndices(leafIndices, size)
+	if err != nil {
+		return nil, err
+	}
+
+	leafHashes := make([][]byte, len(indices))
+	for i, index := range indices {
+		if leafHashes[i], err = t.store.GetNode(0, index); err != nil {
+			return nil, err
+		}
+	}
+
+	path := make([][]byte, 0)
+	if err := t.multiPath(indices, 0, size, &path); err != nil {
+		return nil, err
+	}
+
+	return &MultiInclusionProof{
+		LeafIndices: indices,
+		LeafHashes:  leafHashes,
+		TreeSize:    size,
+		Path:        path,
+	}, nil
+}
+
+// multiPath emits the roots of the subtrees of [offset, offset+n) that contain none of indices
+func (t *IncrementalTree) multiPath(indices []int, offset, n int, path *[][]byte) error {
+	if len(indices) == 0 {
+		h, err := t.subtreeHash(offset, n)
+		if err != nil {
+			return err
+		}
+		*path = append(*path, h)
+		return nil
+	}
+
+	if n == 1 {
+		return nil
+	}
+
+	k := splitPoint(n)
+	split := sort.SearchInts(indices, offset+k)
+	if err := t.multiPath(indices[:split], offset, k, path); err != nil {
+		return err
+	}
+	return t.multiPath(indices[split:], offset+k, n-k, path)
+}
+
+// VerifyMultiInclusion verifies a multi-leaf inclusion proof without access to the tree.
+// leafIndices must be unique and in ascending order, with leafHashes in the same order.
+func VerifyMultiInclusion(hasher Hasher, leafIndices []int, leafHashes [][]byte, treeSize int, path [][]byte, root []byte) (bool, error) {
+	if len(leafIndices) != len(leafHashes) {
+		return false, fmt.Errorf("%d leaf indices but %d leaf hashes", len(leafIndices), len(leafHashes))
+	}
+
+	indices, err := normalizeIndices(leafIndices, treeSize)
+	if err != nil {
+		return false, err
+	}
+	// Normalizing drops duplicates, whose leaf hashes would go unchecked
+	if len(indices) != len(leafIndices) {
+		return false, fmt.Errorf("leaf indices must be unique and in ascending order")
+	}
+	for i := range indices {
+		if indices[i] != leafIndices[i] {
+			return false, fmt.Errorf("leaf indices must be unique and in ascending order")
+		}
+	}
+
+	v := &multiVerifier{hasher: hasher, indices: indices, leafHashes: leafHashes, path: path}
+	computed, err := v.root(0, 0, len(indices), treeSize)
+	if err != nil {
+		return false, err
+	}
+
+	if len(v.path) != 0 {
+		return false, fmt.Errorf("multi-inclusion path too long: %d unused hashes", len(v.path))
+	}
+
+	return bytes.Equal(computed, root), nil
+}
+
+// multiVerifier recomputes a root from proven leaves, consuming path hashes
+// in the same order multiPath emitted them
+type multiVerifier struct {
+	hasher     Hasher
+	indices    []int
+	leafHashes [][]byte
+	path       [][]byte
+}
+
+// root computes MTH(D[offset:offset+n]) where indices[lo:hi] fall in the range
+func (v *multiVerifier) root(offset, lo, hi, n int) ([]byte, error) {
+	if lo == hi {
+		if len(v.path) == 0 {
+			return nil, fmt.Errorf("multi-inclusion path too short")
+		}
+		h := v.path[0]
+		v.path = v.path[1:]
+		return h, nil
+	}
+
+	if n == 1 {
+		return v.leafHashes[lo], nil
+	}
+
+	k := splitPoint(n)
+	split := lo + sort.SearchInts(v.indices[lo:hi], offset+k)
+	left, err := v.root(offset, lo, split, k)
+	if err != nil {
+		return nil, err
+	}
+	right, err := v.root(offset+k, split, hi, n-k)
+	if err != nil {
+		return nil, err
+	}
+	return v.hasher.HashChildren(left, right)
+}
+
+// normalizeIndices returns a sorted, deduplicated copy of indices, all of which must be in [0, size)
+func normalizeIndices(indices []int, size int) ([]int, error) {
+	if len(indices) == 0 {
+		return nil, fmt.Errorf("no leaf indices given")
+	}
+
+	sorted := append([]int(nil), indices...)
+	sort.Ints(sorted)
+
+	unique := sorted[:0]
+	for i, index := range sorted {
+		if index < 0 || index >= size {
+			return nil, fmt.Errorf("leaf index %d out of range for tree size %d", index, size)
+		}
+		if i == 0 || index != sorted[i-1] {
+			unique = append(unique, index)
+		}
+	}
+	return unique, nil
+}
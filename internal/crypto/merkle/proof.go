@@ -0,0 +1,130 @@
+package merkle
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// VerifyInclusion verifies an RFC 9162 inclusion proof without access to the tree.
+// It returns false if the path does not lead to root, and an error if the
+// proof is malformed for the given index and tree size.
+func VerifyInclusion(hasher Hasher, leafHash []byte, index, treeSize int, path [][]byte, root []byte) (bool, error) {
+	if index < 0 || index >= treeSize {
+		return false, fmt.Errorf("leaf index %d out of range for tree size %d", index, treeSize)
+	}
+
+	// Algorithm from RFC 9162 §2.1.3.2
+	fn, sn := index, treeSize-1
+	r := leafHash
+	for _, p := range path {
+		if sn == 0 {
+			return false, fmt.Errorf("inclusion path too long: %d hashes", len(path))
+		}
+
+		var err error
+		if fn&1 == 1 || fn == sn {
+			r, err = hasher.HashChildren(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r, err = hasher.HashChildren(r, p)
+		}
+		if err != nil {
+			return false, err
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return false, fmt.Errorf("inclusion path too short: %d hashes", len(path))
+	}
+
+	return bytes.Equal(r, root), nil
+}
+
+// VerifyConsistency verifies an RFC 9162 consistency proof between two tree states
+// without access to the tree. It returns false if the path does not reproduce both
+// roots, and an error if the proof is malformed for the given sizes.
+func VerifyConsistency(hasher Hasher, oldSize, newSize int, oldRoot, newRoot []byte, path [][]byte) (bool, error) {
+	if oldSize < 0 || oldSize > newSize {
+		return false, fmt.Errorf("invalid tree sizes: %d -> %d", oldSize, newSize)
+	}
+
+	if oldSize == newSize {
+		if len(path) != 0 {
+			return false, fmt.Errorf("consistency path must be empty for equal tree sizes")
+		}
+		return bytes.Equal(oldRoot, newRoot), nil
+	}
+
+	// Every tree is consistent with the empty tree
+	if oldSize == 0 {
+		if len(path) != 0 {
+			return false, fmt.Errorf("consistency path must be empty for an empty old tree")
+		}
+		return true, nil
+	}
+
+	if len(path) == 0 {
+		return false, fmt.Errorf("consistency path is empty")
+	}
+
+	// Algorithm from RFC 9162 §2.1.4.2
+	if oldSize&(oldSize-1) == 0 {
+		path = append([][]byte{oldRoot}, path...)
+	}
+
+	fn, sn := oldSize-1, newSize-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := path[0], path[0]
+	for _, c := range path[1:] {
+		if sn == 0 {
+			return false, fmt.Errorf("consistency path too long: %d hashes", len(path))
+		}
+
+		var err error
+		if fn&1 == 1 || fn == sn {
+			if fr, err = hasher.HashChildren(c, fr); err != nil {
+				return false, err
+			}
+			if sr, err = hasher.HashChildren(c, sr); err != nil {
+				return false, err
+			}
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			if sr, err = hasher.HashChildren(sr, c); err != nil {
+				return false, err
+			}
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return false, fmt.Errorf("consistency path too short: %d hashes", len(path))
+	}
+
+	return bytes.Equal(fr, oldRoot) && bytes.Equal(sr, newRoot), nil
+}
+
+// splitPoint returns the largest power of two smaller than n (n > 1).
+// This is the size of the left subtree in RFC 9162 §2.1.1.
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
@@ -0,0 +1,129 @@
+package shamir
+
+import (
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// sealedShareVersion is the version of the sealed share envelope
+const sealedShareVersion = 1
+
+// sealInfo is the HKDF info string for share encryption keys
+const sealInfo = "civic-attest shamir share seal v1"
+
+// SealedShare is a share encrypted to one trustee's X25519 public key. The
+// key is derived with HKDF-SHA256 from an X25519 exchange with a fresh
+// ephemeral key, and the share is sealed with ChaCha20-Poly1305.
+type SealedShare struct {
+	Version int `json:"version"`
+	// Index is the share index, authenticated as associated data
+	Index byte `json:"index"`
+	// Recipient is the trustee's X25519 public key
+	Recipient []byte `json:"recipient"`
+	// Ephemeral is the sender's ephemeral X25519 public key
+	Ephemeral  []byte `json:"ephemeral"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// GenerateTrusteeKey generates an X25519 key pair for a trustee to receive shares
+func GenerateTrusteeKey() (privateKey, publicKey []byte, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate trustee key: %w", err)
+	}
+	return key.Bytes(), key.PublicKey().Bytes(), nil
+}
+
+// Seal encrypts a share to a trustee's X25519 public key
+func Seal(share Share, recipient []byte) (*SealedShare, error) {
+	recipientKey, err := ecdh.X25519().NewPublicKey(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trustee public key: %w", err)
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	shared, err := ephemeral.ECDH(recipientKey)
+	if err != nil {
+		return nil, fmt.Errorf("key exchange failed: %w", err)
+	}
+
+	sealed := &SealedShare{
+		Version:   sealedShareVersion,
+		Index:     share.Index,
+		Recipient: recipientKey.Bytes(),
+		Ephemeral: ephemeral.PublicKey().Bytes(),
+		Nonce:     make([]byte, chacha20poly1305.NonceSize),
+	}
+	if _, err := rand.Read(sealed.Nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	aead, err := sealed.aead(shared)
+	if err != nil {
+		return nil, err
+	}
+	sealed.Ciphertext = aead.Seal(nil, sealed.Nonce, share.Value, sealed.associatedData())
+	return sealed, nil
+}
+
+// Open decrypts a sealed share with the trustee's X25519 private key
+func Open(sealed *SealedShare, privateKey []byte) (Share, error) {
+	if sealed.Version != sealedShareVersion {
+		return Share{}, fmt.Errorf("unsupported sealed share version: %d", sealed.Version)
+	}
+	if len(sealed.Nonce) != chacha20poly1305.NonceSize {
+		return Share{}, fmt.Errorf("invalid sealed share nonce length: %d", len(sealed.Nonce))
+	}
+
+	key, err := ecdh.X25519().NewPrivateKey(privateKey)
+	if err != nil {
+		return Share{}, fmt.Errorf("invalid trustee private key: %w", err)
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(sealed.Ephemeral)
+	if err != nil {
+		return Share{}, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+	shared, err := key.ECDH(ephemeral)
+	if err != nil {
+		return Share{}, fmt.Errorf("key exchange failed: %w", err)
+	}
+
+	// The recipient is recomputed rather than trusted from the envelope
+	opened := *sealed
+	opened.Recipient = key.PublicKey().Bytes()
+
+	aead, err := opened.aead(shared)
+	if err != nil {
+		return Share{}, err
+	}
+	value, err := aead.Open(nil, sealed.Nonce, sealed.Ciphertext, opened.associatedData())
+	if err != nil {
+		return Share{}, fmt.Errorf("failed to decrypt share: wrong trustee key or corrupted share")
+	}
+	return Share{Index: sealed.Index, Value: value}, nil
+}
+
+// aead derives the share cipher from the X25519 shared secret, bound to both public keys
+func (s *SealedShare) aead(shared []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte(nil), s.Ephemeral...), s.Recipient...)
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(sealInfo)), key); err != nil {
+		return nil, fmt.Errorf("failed to derive share key: %w", err)
+	}
+	return chacha20poly1305.New(key)
+}
+
+// associatedData authenticates the envelope version and share index
+func (s *SealedShare) associatedData() []byte {
+	return []byte{byte(s.Version), s.Index}
+}
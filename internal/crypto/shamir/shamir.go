@@ -0,0 +1,147 @@
+// Package shamir implements Shamir's secret sharing over GF(2^8) for backing
+// up ceremony keys. A secret is split into shares of which any threshold
+// recover it, while fewer reveal nothing about it. Shares are sealed to each
+// trustee's X25519 key for distribution, and a commitment to every share is
+// published in the ceremony record so tampered shares are caught at recovery.
+package shamir
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+)
+
+// MaxShares is the largest number of shares a secret can be split into
+const MaxShares = 255
+
+// commitmentDomain separates share commitments from other SHA-256 uses
+const commitmentDomain = "civic-attest shamir share v1"
+
+// Share is one share of a secret
+type Share struct {
+	// Index is the share's x-coordinate; it is never zero
+	Index byte `json:"index"`
+	// Value holds one polynomial evaluation per byte of the secret
+	Value []byte `json:"value"`
+}
+
+// Split splits secret into n shares, any threshold of which recover it
+func Split(secret []byte, threshold, n int) ([]Share, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("secret is empty")
+	}
+	if threshold < 2 || threshold > n || n > MaxShares {
+		return nil, fmt.Errorf("invalid threshold %d of %d", threshold, n)
+	}
+
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i] = Share{Index: byte(i + 1), Value: make([]byte, len(secret))}
+	}
+
+	// One random polynomial of degree threshold-1 per secret byte, with the
+	// secret byte as its constant term
+	coefficients := make([]byte, threshold)
+	defer clear(coefficients)
+	for b, s := range secret {
+		coefficients[0] = s
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, fmt.Errorf("failed to read randomness: %w", err)
+		}
+		for i := range shares {
+			shares[i].Value[b] = evaluate(coefficients, shares[i].Index)
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine recovers the secret from shares by interpolating at zero. Given
+// fewer shares than the threshold it returns an unrelated value, so callers
+// must check the result (e.g., against a recorded public key hash).
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, fmt.Errorf("at least 2 shares are required, got %d", len(shares))
+	}
+
+	size := len(shares[0].Value)
+	seen := make(map[byte]bool, len(shares))
+	for _, s := range shares {
+		if s.Index == 0 {
+			return nil, fmt.Errorf("share has a zero index")
+		}
+		if seen[s.Index] {
+			return nil, fmt.Errorf("duplicate share %d", s.Index)
+		}
+		seen[s.Index] = true
+		if len(s.Value) != size || size == 0 {
+			return nil, fmt.Errorf("share %d has length %d, expected %d", s.Index, len(s.Value), size)
+		}
+	}
+
+	// Lagrange basis at zero: l_i = prod x_j / (x_j - x_i), where subtraction is XOR
+	basis := make([]byte, len(shares))
+	for i, si := range shares {
+		num, den := byte(1), byte(1)
+		for j, sj := range shares {
+			if i == j {
+				continue
+			}
+			num = mul(num, sj.Index)
+			den = mul(den, sj.Index^si.Index)
+		}
+		basis[i] = mul(num, inverse(den))
+	}
+
+	secret := make([]byte, size)
+	for b := range secret {
+		var v byte
+		for i, s := range shares {
+			v ^= mul(s.Value[b], basis[i])
+		}
+		secret[b] = v
+	}
+	return secret, nil
+}
+
+// Commit returns the integrity commitment to a share that is published in
+// the ceremony record
+func Commit(share Share) []byte {
+	h := sha256.New()
+	h.Write([]byte(commitmentDomain))
+	h.Write([]byte{share.Index})
+	h.Write(share.Value)
+	return h.Sum(nil)
+}
+
+// evaluate evaluates a polynomial at x with Horner's rule
+func evaluate(coefficients []byte, x byte) byte {
+	var result byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = mul(result, x) ^ coefficients[i]
+	}
+	return result
+}
+
+// mul multiplies in GF(2^8) modulo the AES polynomial x^8+x^4+x^3+x+1. It
+// runs in constant time, as the operands are secret.
+func mul(a, b byte) byte {
+	var p byte
+	for i := 0; i < 8; i++ {
+		p ^= a & -(b & 1)
+		carry := -(a >> 7)
+		a = a<<1 ^ 0x1b&carry
+		b >>= 1
+	}
+	return p
+}
+
+// inverse returns a^254, the multiplicative inverse of a non-zero a
+func inverse(a byte) byte {
+	result := a
+	for i := 0; i < 6; i++ {
+		a = mul(a, a)
+		result = mul(result, a)
+	}
+	return mul(result, result)
+}
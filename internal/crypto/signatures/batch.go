@@ -0,0 +1,196 @@
+package signatures
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"sort"
+
+	"filippo.io/edwards25519"
+)
+
+// pinpointThreshold is the sub-batch size below which failed batches are
+// checked one signature at a time rather than bisected further
+const pinpointThreshold = 4
+
+// BatchVerifier verifies many Ed25519 signatures at once, several times
+// faster than calling Verify for each. Signatures by the same public key,
+// as in a ledger replay, are cheaper still.
+//
+// The batch checks the cofactored equation [8][s]B = [8]R + [8][k]A, which
+// unlike the cofactorless one can be batched soundly. Verify checks the same
+// equation with the same encoding rules, and failed batches are pinpointed
+// with it, so a signature gets the same verdict from Verify and from a batch
+// whatever else is in it.
+type BatchVerifier struct {
+	entries []batchEntry
+}
+
+// batchEntry is one decoded signature. Malformed signatures are kept with
+// invalid set so their index is reported.
+type batchEntry struct {
+	publicKey []byte
+	message   []byte
+	signature []byte
+	a, r      *edwards25519.Point
+	s, k      *edwards25519.Scalar
+	invalid   bool
+}
+
+// NewBatchVerifier creates an empty batch
+func NewBatchVerifier() *BatchVerifier {
+	return &BatchVerifier{}
+}
+
+// Add queues an Ed25519 signature for verification. Its index in the results
+// of Verify is the number of signatures added before it.
+func (v *BatchVerifier) Add(publicKey, message, signature []byte) {
+	v.entries = append(v.entries, parseEd25519(publicKey, message, signature))
+}
+
+// parseEd25519 decodes an Ed25519 signature. A and R must be canonically
+// encoded and s reduced, and A must not have small order, since the
+// cofactored equation holds for any message under a small-order key.
+func parseEd25519(publicKey, message, signature []byte) batchEntry {
+	e := batchEntry{publicKey: publicKey, message: message, signature: signature}
+	if len(publicKey) != ed25519.PublicKeySize || len(signature) != ed25519.SignatureSize {
+		e.invalid = true
+		return e
+	}
+
+	var err error
+	if e.a, err = edwards25519.NewIdentityPoint().SetBytes(publicKey); err != nil || !bytes.Equal(e.a.Bytes(), publicKey) {
+		e.invalid = true
+	} else if edwards25519.NewIdentityPoint().MultByCofactor(e.a).Equal(edwards25519.NewIdentityPoint()) == 1 {
+		e.invalid = true
+	}
+	if e.r, err = edwards25519.NewIdentityPoint().SetBytes(signature[:32]); err != nil || !bytes.Equal(e.r.Bytes(), signature[:32]) {
+		e.invalid = true
+	}
+	if e.s, err = edwards25519.NewScalar().SetCanonicalBytes(signature[32:]); err != nil {
+		e.invalid = true
+	}
+	if !e.invalid {
+		h := sha512.New()
+		h.Write(signature[:32])
+		h.Write(publicKey)
+		h.Write(message)
+		e.k, _ = edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+	}
+	return e
+}
+
+// Len returns the number of signatures in the batch
+func (v *BatchVerifier) Len() int {
+	return len(v.entries)
+}
+
+// Reset empties the batch for reuse
+func (v *BatchVerifier) Reset() {
+	v.entries = v.entries[:0]
+}
+
+// Verify checks every signature in the batch. It reports whether all are
+// valid; if not, failed lists the indexes of the invalid signatures, found
+// by bisecting the batch.
+func (v *BatchVerifier) Verify() (valid bool, failed []int) {
+	indexes := make([]int, 0, len(v.entries))
+	for i, e := range v.entries {
+		if e.invalid {
+			failed = append(failed, i)
+		} else {
+			indexes = append(indexes, i)
+		}
+	}
+
+	failed = append(failed, v.pinpoint(indexes)...)
+	if len(failed) == 0 {
+		return true, nil
+	}
+	sort.Ints(failed)
+	return false, failed
+}
+
+// pinpoint returns the invalid signatures among indexes
+func (v *BatchVerifier) pinpoint(indexes []int) []int {
+	if len(indexes) == 0 || v.verifyEquation(indexes) {
+		return nil
+	}
+
+	if len(indexes) <= pinpointThreshold {
+		var failed []int
+		for _, i := range indexes {
+			if !v.entries[i].verify() {
+				failed = append(failed, i)
+			}
+		}
+		return failed
+	}
+
+	mid := len(indexes) / 2
+	return append(v.pinpoint(indexes[:mid]), v.pinpoint(indexes[mid:])...)
+}
+
+// verify checks the cofactored equation [8]([s]B - [k]A - R) = 0 for a
+// single signature
+func (e *batchEntry) verify() bool {
+	if e.invalid {
+		return false
+	}
+	minusA := edwards25519.NewIdentityPoint().Negate(e.a)
+	check := edwards25519.NewIdentityPoint().VarTimeDoubleScalarBaseMult(e.k, minusA, e.s)
+	check.Subtract(check, e.r)
+	check.MultByCofactor(check)
+	return check.Equal(edwards25519.NewIdentityPoint()) == 1
+}
+
+// verifyEquation checks the batch equation
+//
+//	[8](-sum(z_i*s_i) B + sum(z_i R_i) + sum(z_i*k_i A_i)) = 0
+//
+// with random 128-bit z_i, merging the terms of repeated public keys
+func (v *BatchVerifier) verifyEquation(indexes []int) bool {
+	scalars := make([]*edwards25519.Scalar, 0, 2*len(indexes)+1)
+	points := make([]*edwards25519.Point, 0, 2*len(indexes)+1)
+
+	bCoefficient := edwards25519.NewScalar()
+	scalars = append(scalars, bCoefficient)
+	points = append(points, edwards25519.NewGeneratorPoint())
+
+	keyCoefficients := make(map[string]*edwards25519.Scalar)
+	for _, i := range indexes {
+		e := v.entries[i]
+
+		z, err := randomBatchScalar()
+		if err != nil {
+			return false
+		}
+
+		bCoefficient.Subtract(bCoefficient, edwards25519.NewScalar().Multiply(z, e.s))
+		scalars = append(scalars, z)
+		points = append(points, e.r)
+
+		zk := edwards25519.NewScalar().Multiply(z, e.k)
+		if c, ok := keyCoefficients[string(e.publicKey)]; ok {
+			c.Add(c, zk)
+			continue
+		}
+		keyCoefficients[string(e.publicKey)] = zk
+		scalars = append(scalars, zk)
+		points = append(points, e.a)
+	}
+
+	check := edwards25519.NewIdentityPoint().VarTimeMultiScalarMult(scalars, points)
+	check.MultByCofactor(check)
+	return check.Equal(edwards25519.NewIdentityPoint()) == 1
+}
+
+// randomBatchScalar returns a random 128-bit scalar
+func randomBatchScalar() (*edwards25519.Scalar, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:16]); err != nil {
+		return nil, err
+	}
+	return edwards25519.NewScalar().SetCanonicalBytes(b[:])
+}
@@ -0,0 +1,133 @@
+package signatures
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"math/big"
+)
+
+// ECDSA keys are held raw like the EdDSA keys: the public key is the
+// uncompressed SEC 1 point and the private key the big-endian scalar.
+// Signatures are ASN.1 DER over the SHA-256 (P-256) or SHA-384 (P-384)
+// digest of the message, as produced by X.509 and PIV tooling.
+
+// ecdsaMaxSignatureSize bounds a DER ECDSA-P384 signature
+const ecdsaMaxSignatureSize = 104
+
+// IsECDSA reports whether an algorithm is an ECDSA algorithm
+func IsECDSA(algo Algorithm) bool {
+	return algo == ECDSAP256 || algo == ECDSAP384
+}
+
+// ecdsaCurves returns the curve of an ECDSA algorithm
+func ecdsaCurves(algo Algorithm) (ecdh.Curve, elliptic.Curve) {
+	if algo == ECDSAP384 {
+		return ecdh.P384(), elliptic.P384()
+	}
+	return ecdh.P256(), elliptic.P256()
+}
+
+// ECDSADigest returns the digest of message that an ECDSA algorithm signs
+func ECDSADigest(message []byte, algo Algorithm) []byte {
+	if algo == ECDSAP384 {
+		digest := sha512.Sum384(message)
+		return digest[:]
+	}
+	digest := sha256.Sum256(message)
+	return digest[:]
+}
+
+// ecdsaAlgorithm returns the algorithm of an ECDSA key's curve
+func ecdsaAlgorithm(curve elliptic.Curve) (Algorithm, error) {
+	switch curve {
+	case elliptic.P256():
+		return ECDSAP256, nil
+	case elliptic.P384():
+		return ECDSAP384, nil
+	default:
+		return "", fmt.Errorf("unsupported ECDSA curve: %s", curve.Params().Name)
+	}
+}
+
+// generateECDSAKey generates an ECDSA key pair
+func generateECDSAKey(algo Algorithm) (*KeyPair, error) {
+	curve, _ := ecdsaCurves(algo)
+	priv, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", algo, err)
+	}
+	return &KeyPair{
+		PublicKey:  priv.PublicKey().Bytes(),
+		PrivateKey: priv.Bytes(),
+		Algorithm:  algo,
+	}, nil
+}
+
+// ecdsaPublicKey decodes an uncompressed point, checking it is on the curve
+func ecdsaPublicKey(publicKey []byte, algo Algorithm) (*ecdsa.PublicKey, error) {
+	curve, ec := ecdsaCurves(algo)
+	if _, err := curve.NewPublicKey(publicKey); err != nil {
+		return nil, fmt.Errorf("invalid %s public key", algo)
+	}
+
+	size := (len(publicKey) - 1) / 2
+	return &ecdsa.PublicKey{
+		Curve: ec,
+		X:     new(big.Int).SetBytes(publicKey[1 : 1+size]),
+		Y:     new(big.Int).SetBytes(publicKey[1+size:]),
+	}, nil
+}
+
+// ecdsaPrivateKey decodes a private scalar, checking it is in range
+func ecdsaPrivateKey(privateKey []byte, algo Algorithm) (*ecdsa.PrivateKey, error) {
+	curve, _ := ecdsaCurves(algo)
+	priv, err := curve.NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s private key", algo)
+	}
+	pub, err := ecdsaPublicKey(priv.PublicKey().Bytes(), algo)
+	if err != nil {
+		return nil, err
+	}
+	return &ecdsa.PrivateKey{PublicKey: *pub, D: new(big.Int).SetBytes(privateKey)}, nil
+}
+
+// ecdsaPublicKeyFromPrivate returns the uncompressed point of a private scalar
+func ecdsaPublicKeyFromPrivate(privateKey []byte, algo Algorithm) ([]byte, error) {
+	curve, _ := ecdsaCurves(algo)
+	priv, err := curve.NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s private key", algo)
+	}
+	return priv.PublicKey().Bytes(), nil
+}
+
+// signECDSA signs the digest of message with a private scalar
+func signECDSA(privateKey, message []byte, algo Algorithm) ([]byte, error) {
+	priv, err := ecdsaPrivateKey(privateKey, algo)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := ecdsa.SignASN1(rand.Reader, priv, ECDSADigest(message, algo))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign with %s: %w", algo, err)
+	}
+	return signature, nil
+}
+
+// verifyECDSA verifies a DER signature over the digest of message
+func verifyECDSA(publicKey, message, signature []byte, algo Algorithm) (bool, error) {
+	pub, err := ecdsaPublicKey(publicKey, algo)
+	if err != nil {
+		return false, err
+	}
+	if len(signature) == 0 || len(signature) > ecdsaMaxSignatureSize {
+		return false, fmt.Errorf("invalid %s signature size", algo)
+	}
+	return ecdsa.VerifyASN1(pub, ECDSADigest(message, algo), signature), nil
+}
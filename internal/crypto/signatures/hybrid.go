@@ -0,0 +1,106 @@
+package signatures
+
+import (
+	"fmt"
+)
+
+// Policy selects which signatures of a hybrid classical + post-quantum
+// signature must be present and valid. The values match signature_policy in
+// the v2 bundle schema and the phases of the quantum migration plan.
+type Policy string
+
+const (
+	// PolicyClassicalOnly requires a valid classical signature; post-quantum signatures are ignored
+	PolicyClassicalOnly Policy = "REQUIRE_CLASSICAL_ONLY"
+	// PolicyClassicalAndOptionalPQ requires a valid classical signature and a valid post-quantum signature if present
+	PolicyClassicalAndOptionalPQ Policy = "REQUIRE_CLASSICAL_AND_OPTIONAL_PQ"
+	// PolicyClassicalAndPQ requires valid classical and post-quantum signatures
+	PolicyClassicalAndPQ Policy = "REQUIRE_CLASSICAL_AND_PQ"
+	// PolicyPQOnly requires a valid post-quantum signature; classical signatures are ignored
+	PolicyPQOnly Policy = "REQUIRE_PQ_ONLY"
+)
+
+// ParsePolicy parses a policy name; an empty name is PolicyClassicalOnly, the schema default
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case "":
+		return PolicyClassicalOnly, nil
+	case PolicyClassicalOnly, PolicyClassicalAndOptionalPQ, PolicyClassicalAndPQ, PolicyPQOnly:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown signature policy: %q", s)
+	}
+}
+
+// RequiresClassical reports whether the policy requires a classical signature
+func (p Policy) RequiresClassical() bool {
+	return p != PolicyPQOnly
+}
+
+// RequiresPostQuantum reports whether the policy requires a post-quantum signature
+func (p Policy) RequiresPostQuantum() bool {
+	return p == PolicyClassicalAndPQ || p == PolicyPQOnly
+}
+
+// checksPostQuantum reports whether a present post-quantum signature must verify
+func (p Policy) checksPostQuantum() bool {
+	return p != PolicyClassicalOnly
+}
+
+// Satisfies reports whether the policy demands at least every signature the
+// minimum policy does. PolicyPQOnly and PolicyClassicalOnly do not satisfy
+// each other; PolicyClassicalAndPQ satisfies every policy.
+func (p Policy) Satisfies(minimum Policy) bool {
+	return (p.RequiresClassical() || !minimum.RequiresClassical()) &&
+		(p.RequiresPostQuantum() || !minimum.RequiresPostQuantum()) &&
+		(p.checksPostQuantum() || !minimum.checksPostQuantum())
+}
+
+// Component is one signature of a hybrid signature
+type Component struct {
+	// Algorithm is the signature algorithm
+	Algorithm Algorithm
+	// PublicKey is the verification key
+	PublicKey []byte
+	// Signature is the signature over the message
+	Signature []byte
+}
+
+// VerifyHybrid verifies the classical and post-quantum signatures over message
+// as required by policy. A nil component means the signature is absent. An
+// error is returned if the policy requires a missing signature or a component
+// uses an algorithm of the wrong kind.
+func VerifyHybrid(policy Policy, message []byte, classical, postQuantum *Component) (bool, error) {
+	if _, err := ParsePolicy(string(policy)); err != nil {
+		return false, err
+	}
+	if classical != nil && IsPostQuantum(classical.Algorithm) {
+		return false, fmt.Errorf("classical signature uses post-quantum algorithm %s", classical.Algorithm)
+	}
+	if postQuantum != nil && !IsPostQuantum(postQuantum.Algorithm) {
+		return false, fmt.Errorf("post-quantum signature uses classical algorithm %s", postQuantum.Algorithm)
+	}
+
+	if policy.RequiresClassical() && classical == nil {
+		return false, fmt.Errorf("signature policy %s requires a classical signature", policy)
+	}
+	if policy.RequiresPostQuantum() && postQuantum == nil {
+		return false, fmt.Errorf("signature policy %s requires a post-quantum signature", policy)
+	}
+
+	if policy.RequiresClassical() {
+		valid, err := Verify(classical.PublicKey, message, classical.Signature, classical.Algorithm)
+		if err != nil || !valid {
+			return false, err
+		}
+	}
+
+	if postQuantum != nil && policy.checksPostQuantum() {
+		valid, err := Verify(postQuantum.PublicKey, message, postQuantum.Signature, postQuantum.Algorithm)
+		if err != nil || !valid {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,301 @@
+package signatures
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/ed448"
+)
+
+// Object identifiers for EdDSA keys (RFC 8410)
+var (
+	oidEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+	oidEd448   = asn1.ObjectIdentifier{1, 3, 101, 113}
+	oidMLDSA65 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 18}
+	oidMLDSA87 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 19}
+	// oidECPublicKey is id-ecPublicKey (RFC 5480); the curve is in the parameters
+	oidECPublicKey = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+)
+
+// algorithmIdentifier has no parameters for EdDSA keys
+type algorithmIdentifier struct {
+	Algorithm asn1.ObjectIdentifier
+}
+
+// subjectPublicKeyInfo is the X.509 public key encoding
+type subjectPublicKeyInfo struct {
+	Algorithm algorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// oneAsymmetricKey is the PKCS#8 private key encoding (RFC 5958)
+type oneAsymmetricKey struct {
+	Version    int
+	Algorithm  algorithmIdentifier
+	PrivateKey []byte
+	Attributes asn1.RawValue  `asn1:"optional,tag:0"`
+	PublicKey  asn1.BitString `asn1:"optional,tag:1"`
+}
+
+// PublicKeySize returns the public key length of an algorithm
+func PublicKeySize(algo Algorithm) (int, error) {
+	switch algo {
+	case Ed25519:
+		return ed25519.PublicKeySize, nil
+	case Ed448:
+		return ed448.PublicKeySize, nil
+	case MLDSA65, MLDSA87:
+		return mldsaScheme(algo).PublicKeySize(), nil
+	case ECDSAP256:
+		return 65, nil
+	case ECDSAP384:
+		return 97, nil
+	default:
+		return 0, fmt.Errorf("unsupported signature algorithm: %s", algo)
+	}
+}
+
+// MarshalPublicKey encodes a public key as DER SubjectPublicKeyInfo
+func MarshalPublicKey(publicKey []byte, algo Algorithm) ([]byte, error) {
+	if IsECDSA(algo) {
+		pub, err := ecdsaPublicKey(publicKey, algo)
+		if err != nil {
+			return nil, err
+		}
+		return x509.MarshalPKIXPublicKey(pub)
+	}
+
+	oid, err := keyOID(algo)
+	if err != nil {
+		return nil, err
+	}
+	size, _ := PublicKeySize(algo)
+	if len(publicKey) != size {
+		return nil, fmt.Errorf("invalid %s public key size", algo)
+	}
+
+	return asn1.Marshal(subjectPublicKeyInfo{
+		Algorithm: algorithmIdentifier{Algorithm: oid},
+		PublicKey: asn1.BitString{Bytes: publicKey, BitLength: 8 * len(publicKey)},
+	})
+}
+
+// ParsePublicKey decodes a DER SubjectPublicKeyInfo
+func ParsePublicKey(der []byte) ([]byte, Algorithm, error) {
+	var spki subjectPublicKeyInfo
+	rest, err := asn1.Unmarshal(der, &spki)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse public key: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, "", fmt.Errorf("trailing data after public key")
+	}
+	if spki.Algorithm.Algorithm.Equal(oidECPublicKey) {
+		return parseECDSAPublicKey(der)
+	}
+
+	algo, err := keyAlgorithm(spki.Algorithm.Algorithm)
+	if err != nil {
+		return nil, "", err
+	}
+	size, _ := PublicKeySize(algo)
+	if spki.PublicKey.BitLength != 8*size || len(spki.PublicKey.Bytes) != size {
+		return nil, "", fmt.Errorf("invalid %s public key size", algo)
+	}
+
+	return spki.PublicKey.Bytes, algo, nil
+}
+
+// MarshalPrivateKey encodes an EdDSA private key as DER PKCS#8 holding the
+// key seed, or an ECDSA private key as DER PKCS#8 holding an RFC 5915 key
+func MarshalPrivateKey(privateKey []byte, algo Algorithm) ([]byte, error) {
+	if IsECDSA(algo) {
+		priv, err := ecdsaPrivateKey(privateKey, algo)
+		if err != nil {
+			return nil, err
+		}
+		return x509.MarshalPKCS8PrivateKey(priv)
+	}
+	if IsPostQuantum(algo) {
+		return nil, fmt.Errorf("PKCS#8 encoding of %s private keys is not supported", algo)
+	}
+	oid, err := keyOID(algo)
+	if err != nil {
+		return nil, err
+	}
+
+	var seed []byte
+	switch algo {
+	case Ed25519:
+		if len(privateKey) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 private key size")
+		}
+		seed = ed25519.PrivateKey(privateKey).Seed()
+	case Ed448:
+		if len(privateKey) != ed448.PrivateKeySize {
+			return nil, fmt.Errorf("invalid Ed448 private key size")
+		}
+		seed = ed448.PrivateKey(privateKey).Seed()
+	}
+
+	// CurvePrivateKey is itself an OCTET STRING inside the PKCS#8 private key field
+	curvePrivateKey, err := asn1.Marshal(seed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	return asn1.Marshal(oneAsymmetricKey{
+		Algorithm:  algorithmIdentifier{Algorithm: oid},
+		PrivateKey: curvePrivateKey,
+	})
+}
+
+// ParsePrivateKey decodes a DER PKCS#8 private key and expands its seed into
+// the private key format used by Sign
+func ParsePrivateKey(der []byte) ([]byte, Algorithm, error) {
+	var key oneAsymmetricKey
+	rest, err := asn1.Unmarshal(der, &key)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse private key: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, "", fmt.Errorf("trailing data after private key")
+	}
+	if key.Version != 0 && key.Version != 1 {
+		return nil, "", fmt.Errorf("unsupported private key version: %d", key.Version)
+	}
+	if key.Algorithm.Algorithm.Equal(oidECPublicKey) {
+		return parseECDSAPrivateKey(der)
+	}
+
+	algo, err := keyAlgorithm(key.Algorithm.Algorithm)
+	if err != nil {
+		return nil, "", err
+	}
+	if IsPostQuantum(algo) {
+		return nil, "", fmt.Errorf("PKCS#8 encoding of %s private keys is not supported", algo)
+	}
+
+	var seed []byte
+	if rest, err := asn1.Unmarshal(key.PrivateKey, &seed); err != nil || len(rest) != 0 {
+		return nil, "", fmt.Errorf("malformed %s private key", algo)
+	}
+
+	var privateKey []byte
+	switch algo {
+	case Ed25519:
+		if len(seed) != ed25519.SeedSize {
+			return nil, "", fmt.Errorf("invalid Ed25519 seed size")
+		}
+		privateKey = ed25519.NewKeyFromSeed(seed)
+	case Ed448:
+		if len(seed) != ed448.SeedSize {
+			return nil, "", fmt.Errorf("invalid Ed448 seed size")
+		}
+		privateKey = ed448.NewKeyFromSeed(seed)
+	}
+
+	return privateKey, algo, nil
+}
+
+// PublicKeyFromPrivate returns the public key of a private key
+func PublicKeyFromPrivate(privateKey []byte, algo Algorithm) ([]byte, error) {
+	switch algo {
+	case Ed25519:
+		if len(privateKey) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 private key size")
+		}
+		return ed25519.PrivateKey(privateKey).Public().(ed25519.PublicKey), nil
+	case Ed448:
+		if len(privateKey) != ed448.PrivateKeySize {
+			return nil, fmt.Errorf("invalid Ed448 private key size")
+		}
+		return ed448.PrivateKey(privateKey).Public().(ed448.PublicKey), nil
+	case MLDSA65, MLDSA87:
+		priv, err := mldsaScheme(algo).UnmarshalBinaryPrivateKey(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s private key: %w", algo, err)
+		}
+		return priv.Public().(sign.PublicKey).MarshalBinary()
+	case ECDSAP256, ECDSAP384:
+		return ecdsaPublicKeyFromPrivate(privateKey, algo)
+	default:
+		return nil, fmt.Errorf("unsupported signature algorithm: %s", algo)
+	}
+}
+
+// parseECDSAPublicKey decodes an ECDSA SubjectPublicKeyInfo into its uncompressed point
+func parseECDSAPublicKey(der []byte) ([]byte, Algorithm, error) {
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse public key: %w", err)
+	}
+	ecKey, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, "", fmt.Errorf("malformed ECDSA public key")
+	}
+	algo, err := ecdsaAlgorithm(ecKey.Curve)
+	if err != nil {
+		return nil, "", err
+	}
+	ecdhKey, err := ecKey.ECDH()
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid %s public key: %w", algo, err)
+	}
+	return ecdhKey.Bytes(), algo, nil
+}
+
+// parseECDSAPrivateKey decodes an ECDSA PKCS#8 private key into its scalar
+func parseECDSAPrivateKey(der []byte) ([]byte, Algorithm, error) {
+	priv, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse private key: %w", err)
+	}
+	ecKey, ok := priv.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, "", fmt.Errorf("malformed ECDSA private key")
+	}
+	algo, err := ecdsaAlgorithm(ecKey.Curve)
+	if err != nil {
+		return nil, "", err
+	}
+	ecdhKey, err := ecKey.ECDH()
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid %s private key: %w", algo, err)
+	}
+	return ecdhKey.Bytes(), algo, nil
+}
+
+func keyOID(algo Algorithm) (asn1.ObjectIdentifier, error) {
+	switch algo {
+	case Ed25519:
+		return oidEd25519, nil
+	case Ed448:
+		return oidEd448, nil
+	case MLDSA65:
+		return oidMLDSA65, nil
+	case MLDSA87:
+		return oidMLDSA87, nil
+	default:
+		return nil, fmt.Errorf("unsupported signature algorithm: %s", algo)
+	}
+}
+
+func keyAlgorithm(oid asn1.ObjectIdentifier) (Algorithm, error) {
+	switch {
+	case oid.Equal(oidEd25519):
+		return Ed25519, nil
+	case oid.Equal(oidEd448):
+		return Ed448, nil
+	case oid.Equal(oidMLDSA65):
+		return MLDSA65, nil
+	case oid.Equal(oidMLDSA87):
+		return MLDSA87, nil
+	default:
+		return "", fmt.Errorf("unsupported key algorithm OID: %s", oid)
+	}
+}
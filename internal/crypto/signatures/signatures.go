@@ -5,6 +5,11 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/ed448"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
 )
 
 // Algorithm represents a signature algorithm
@@ -13,8 +18,16 @@ type Algorithm string
 const (
 	// Ed25519 is the primary signature algorithm
 	Ed25519 Algorithm = "Ed25519"
-	// Ed448 is the secondary profile (reserved for future implementation)
+	// Ed448 is the higher-assurance profile (RFC 8032 Ed448 with an empty context)
 	Ed448 Algorithm = "Ed448"
+	// MLDSA65 is the post-quantum profile (FIPS 204 ML-DSA-65 with an empty context)
+	MLDSA65 Algorithm = "ML-DSA-65"
+	// MLDSA87 is the higher-assurance post-quantum profile (FIPS 204 ML-DSA-87)
+	MLDSA87 Algorithm = "ML-DSA-87"
+	// ECDSAP256 is ECDSA over NIST P-256 with SHA-256, for existing PIV and X.509 credentials
+	ECDSAP256 Algorithm = "ECDSA-P256"
+	// ECDSAP384 is ECDSA over NIST P-384 with SHA-384, for existing PIV and X.509 credentials
+	ECDSAP384 Algorithm = "ECDSA-P384"
 )
 
 // KeyPair represents a public/private key pair
@@ -38,7 +51,29 @@ func GenerateKeyPair(algo Algorithm) (*KeyPair, error) {
 			Algorithm:  Ed25519,
 		}, nil
 	case Ed448:
-		return nil, fmt.Errorf("Ed448 not yet implemented")
+		pub, priv, err := ed448.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate Ed448 key: %w", err)
+		}
+		return &KeyPair{
+			PublicKey:  pub,
+			PrivateKey: priv,
+			Algorithm:  Ed448,
+		}, nil
+	case MLDSA65, MLDSA87:
+		pub, priv, err := mldsaScheme(algo).GenerateKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate %s key: %w", algo, err)
+		}
+		pubBytes, _ := pub.MarshalBinary()
+		privBytes, _ := priv.MarshalBinary()
+		return &KeyPair{
+			PublicKey:  pubBytes,
+			PrivateKey: privBytes,
+			Algorithm:  algo,
+		}, nil
+	case ECDSAP256, ECDSAP384:
+		return generateECDSAKey(algo)
 	default:
 		return nil, fmt.Errorf("unsupported signature algorithm: %s", algo)
 	}
@@ -54,13 +89,28 @@ func Sign(privateKey []byte, message []byte, algo Algorithm) ([]byte, error) {
 		signature := ed25519.Sign(privateKey, message)
 		return signature, nil
 	case Ed448:
-		return nil, fmt.Errorf("Ed448 not yet implemented")
+		if len(privateKey) != ed448.PrivateKeySize {
+			return nil, fmt.Errorf("invalid Ed448 private key size")
+		}
+		signature := ed448.Sign(privateKey, message, "")
+		return signature, nil
+	case MLDSA65, MLDSA87:
+		scheme := mldsaScheme(algo)
+		priv, err := scheme.UnmarshalBinaryPrivateKey(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s private key: %w", algo, err)
+		}
+		return scheme.Sign(priv, message, nil), nil
+	case ECDSAP256, ECDSAP384:
+		return signECDSA(privateKey, message, algo)
 	default:
 		return nil, fmt.Errorf("unsupported signature algorithm: %s", algo)
 	}
 }
 
-// Verify verifies a signature against a message and public key
+// Verify verifies a signature against a message and public key. Ed25519
+// signatures are checked with the cofactored equation, as by BatchVerifier,
+// and non-canonical encodings and small-order public keys are rejected.
 func Verify(publicKey []byte, message []byte, signature []byte, algo Algorithm) (bool, error) {
 	switch algo {
 	case Ed25519:
@@ -70,14 +120,46 @@ func Verify(publicKey []byte, message []byte, signature []byte, algo Algorithm)
 		if len(signature) != ed25519.SignatureSize {
 			return false, fmt.Errorf("invalid Ed25519 signature size")
 		}
-		return ed25519.Verify(publicKey, message, signature), nil
+		e := parseEd25519(publicKey, message, signature)
+		return e.verify(), nil
 	case Ed448:
-		return false, fmt.Errorf("Ed448 not yet implemented")
+		if len(publicKey) != ed448.PublicKeySize {
+			return false, fmt.Errorf("invalid Ed448 public key size")
+		}
+		if len(signature) != ed448.SignatureSize {
+			return false, fmt.Errorf("invalid Ed448 signature size")
+		}
+		return ed448.Verify(publicKey, message, signature, ""), nil
+	case MLDSA65, MLDSA87:
+		scheme := mldsaScheme(algo)
+		pub, err := scheme.UnmarshalBinaryPublicKey(publicKey)
+		if err != nil {
+			return false, fmt.Errorf("invalid %s public key: %w", algo, err)
+		}
+		if len(signature) != scheme.SignatureSize() {
+			return false, fmt.Errorf("invalid %s signature size", algo)
+		}
+		return scheme.Verify(pub, message, signature, nil), nil
+	case ECDSAP256, ECDSAP384:
+		return verifyECDSA(publicKey, message, signature, algo)
 	default:
 		return false, fmt.Errorf("unsupported signature algorithm: %s", algo)
 	}
 }
 
+// IsPostQuantum reports whether an algorithm is a post-quantum signature algorithm
+func IsPostQuantum(algo Algorithm) bool {
+	return algo == MLDSA65 || algo == MLDSA87
+}
+
+// mldsaScheme returns the circl scheme of an ML-DSA parameter set
+func mldsaScheme(algo Algorithm) sign.Scheme {
+	if algo == MLDSA87 {
+		return mldsa87.Scheme()
+	}
+	return mldsa65.Scheme()
+}
+
 // PublicKeyString returns hex-encoded public key
 func PublicKeyString(publicKey []byte) string {
 	return hex.EncodeToString(publicKey)
@@ -0,0 +1,56 @@
+package signatures
+
+import (
+	"context"
+	"fmt"
+)
+
+// Signer signs with a private key it may never expose, e.g. one held in an
+// HSM or a remote KMS. Implementations must be safe for concurrent use.
+type Signer interface {
+	// PublicKey returns the raw public key
+	PublicKey() []byte
+	// Algorithm returns the signature algorithm of the key
+	Algorithm() Algorithm
+	// Sign signs a digest (the multihash of the content or a note body)
+	Sign(ctx context.Context, digest []byte) ([]byte, error)
+}
+
+// KeySigner is a Signer over a private key held in memory
+type KeySigner struct {
+	privateKey []byte
+	publicKey  []byte
+	algo       Algorithm
+}
+
+// NewKeySigner creates a signer for a raw private key
+func NewKeySigner(privateKey []byte, algo Algorithm) (*KeySigner, error) {
+	publicKey, err := PublicKeyFromPrivate(privateKey, algo)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s private key: %w", algo, err)
+	}
+
+	return &KeySigner{
+		privateKey: append([]byte(nil), privateKey...),
+		publicKey:  publicKey,
+		algo:       algo,
+	}, nil
+}
+
+// PublicKey returns the raw public key
+func (s *KeySigner) PublicKey() []byte {
+	return append([]byte(nil), s.publicKey...)
+}
+
+// Algorithm returns the signature algorithm of the key
+func (s *KeySigner) Algorithm() Algorithm {
+	return s.algo
+}
+
+// Sign signs a digest with the in-memory key
+func (s *KeySigner) Sign(ctx context.Context, digest []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return Sign(s.privateKey, digest, s.algo)
+}
@@ -0,0 +1,369 @@
+// Package smt implements a sparse Merkle tree over fixed-length keys. Every
+// possible key has a leaf, most of them empty, so the tree can prove both that
+// a key is present (membership) and that it is absent (non-membership).
+package smt
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
+)
+
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// Tree is a sparse Merkle tree. Keys are digests of the tree's hash algorithm,
+// so the tree depth is the digest length in bits. Empty subtrees hash to all
+// zero bytes, and only non-empty nodes are stored.
+type Tree struct {
+	hashAlgo hash.Algorithm
+	keySize  int
+	depth    int
+	empty    []byte
+	leaves   map[string][]byte
+	nodes    map[string][]byte
+}
+
+// Update sets or removes the value of one key
+type Update struct {
+	// Key is the leaf key
+	Key []byte
+	// Value is the hash of the value bound to the key; nil removes the key
+	Value []byte
+}
+
+// Proof is a compact proof for one key. Siblings that are empty subtrees are
+// omitted and marked by a zero bit in Bitmap.
+type Proof struct {
+	// Key is the proven key
+	Key []byte `json:"key" cbor:"1,keyasint"`
+	// Value is the value hash bound to the key, or nil for a non-membership proof
+	Value []byte `json:"value,omitempty" cbor:"2,keyasint,omitempty"`
+	// Bitmap has bit h set (LSB first) if the sibling at height h is non-empty
+	Bitmap []byte `json:"bitmap" cbor:"3,keyasint"`
+	// Siblings are the non-empty sibling hashes from the leaf up to the root
+	Siblings [][]byte `json:"siblings" cbor:"4,keyasint"`
+}
+
+// NewTree creates an empty sparse Merkle tree for the given hash algorithm
+func NewTree(hashAlgo hash.Algorithm) (*Tree, error) {
+	keySize, err := digestSize(hashAlgo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tree{
+		hashAlgo: hashAlgo,
+		keySize:  keySize,
+		depth:    keySize * 8,
+		empty:    make([]byte, keySize),
+		leaves:   make(map[string][]byte),
+		nodes:    make(map[string][]byte),
+	}, nil
+}
+
+// KeyFor derives the tree key for an identifier, e.g. an identity ID
+func KeyFor(hashAlgo hash.Algorithm, id string) ([]byte, error) {
+	return hash.Hash([]byte(id), hashAlgo)
+}
+
+// Root returns the root hash of the tree
+func (t *Tree) Root() []byte {
+	return t.node(t.depth, nil)
+}
+
+// Size returns the number of keys with a value
+func (t *Tree) Size() int {
+	return len(t.leaves)
+}
+
+// Get returns the value hash bound to key, or nil if the key is absent
+func (t *Tree) Get(key []byte) ([]byte, error) {
+	if err := t.checkKey(key); err != nil {
+		return nil, err
+	}
+	return t.leaves[string(key)], nil
+}
+
+// Set binds a value hash to a key
+func (t *Tree) Set(key, value []byte) error {
+	return t.Apply([]Update{{Key: key, Value: value}})
+}
+
+// Delete removes a key from the tree
+func (t *Tree) Delete(key []byte) error {
+	return t.Apply([]Update{{Key: key}})
+}
+
+// Apply applies a batch of updates. Nodes shared by several updated keys are
+// rehashed once per batch rather than once per key. Later updates to the same
+// key win. The batch is validated before the tree is modified.
+func (t *Tree) Apply(updates []Update) error {
+	for _, u := range updates {
+		if err := t.checkKey(u.Key); err != nil {
+			return err
+		}
+		if u.Value != nil && len(u.Value) != t.keySize {
+			return fmt.Errorf("invalid value hash length: %d", len(u.Value))
+		}
+	}
+
+	// Apply leaf changes and collect the dirty leaves
+	dirty := make(map[string][]byte)
+	for _, u := range updates {
+		k := string(u.Key)
+		if u.Value == nil {
+			delete(t.leaves, k)
+		} else {
+			t.leaves[k] = append([]byte(nil), u.Value...)
+		}
+		dirty[k] = u.Key
+	}
+
+	for _, key := range dirty {
+		h, err := t.leafHash(key)
+		if err != nil {
+			return err
+		}
+		t.setNode(0, key, h)
+	}
+
+	// Walk up one level at a time, merging siblings that share a parent
+	for height := 1; height <= t.depth; height++ {
+		parents := make(map[string][]byte, len(dirty))
+		for _, key := range dirty {
+			prefix := truncate(key, t.depth-height)
+			parents[string(prefix)] = prefix
+		}
+
+		for _, prefix := range parents {
+			left := t.node(height-1, withBit(prefix, t.depth-height, 0))
+			right := t.node(height-1, withBit(prefix, t.depth-height, 1))
+			h, err := t.hashChildren(left, right)
+			if err != nil {
+				return err
+			}
+			t.setNode(height, prefix, h)
+		}
+		dirty = parents
+	}
+
+	return nil
+}
+
+// Prove generates a membership proof if key is present and a non-membership proof otherwise
+func (t *Tree) Prove(key []byte) (*Proof, error) {
+	if err := t.checkKey(key); err != nil {
+		return nil, err
+	}
+
+	proof := &Proof{
+		Key:      append([]byte(nil), key...),
+		Bitmap:   make([]byte, (t.depth+7)/8),
+		Siblings: make([][]byte, 0),
+	}
+	if value, ok := t.leaves[string(key)]; ok {
+		proof.Value = append([]byte(nil), value...)
+	}
+
+	for height := 0; height < t.depth; height++ {
+		prefix := truncate(key, t.depth-height)
+		sibling := t.node(height, flipBit(prefix, t.depth-height-1))
+		if !bytes.Equal(sibling, t.empty) {
+			proof.Bitmap[height/8] |= 1 << (height % 8)
+			proof.Siblings = append(proof.Siblings, sibling)
+		}
+	}
+
+	return proof, nil
+}
+
+// Keys returns all keys with a value, in ascending order
+func (t *Tree) Keys() [][]byte {
+	keys := make([][]byte, 0, len(t.leaves))
+	for k := range t.leaves {
+		keys = append(keys, []byte(k))
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+	return keys
+}
+
+// VerifyProof checks a proof against a root hash. A proof with a nil Value
+// proves that the key is absent.
+func VerifyProof(hashAlgo hash.Algorithm, root []byte, proof *Proof) (bool, error) {
+	keySize, err := digestSize(hashAlgo)
+	if err != nil {
+		return false, err
+	}
+	depth := keySize * 8
+
+	if len(proof.Key) != keySize {
+		return false, fmt.Errorf("invalid key length: %d", len(proof.Key))
+	}
+	if proof.Value != nil && len(proof.Value) != keySize {
+		return false, fmt.Errorf("invalid value hash length: %d", len(proof.Value))
+	}
+	if len(proof.Bitmap) != (depth+7)/8 {
+		return false, fmt.Errorf("invalid proof bitmap length: %d", len(proof.Bitmap))
+	}
+
+	v := &Tree{hashAlgo: hashAlgo, keySize: keySize, depth: depth, empty: make([]byte, keySize)}
+
+	current := v.empty
+	if proof.Value != nil {
+		if current, err = v.hashLeaf(proof.Key, proof.Value); err != nil {
+			return false, err
+		}
+	}
+
+	siblings := proof.Siblings
+	for height := 0; height < depth; height++ {
+		sibling := v.empty
+		if proof.Bitmap[height/8]&(1<<(height%8)) != 0 {
+			if len(siblings) == 0 {
+				return false, fmt.Errorf("proof has too few siblings")
+			}
+			sibling, siblings = siblings[0], siblings[1:]
+			if len(sibling) != keySize {
+				return false, fmt.Errorf("invalid sibling hash length: %d", len(sibling))
+			}
+		}
+
+		if bitAt(proof.Key, depth-height-1) == 0 {
+			current, err = v.hashChildren(current, sibling)
+		} else {
+			current, err = v.hashChildren(sibling, current)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if len(siblings) != 0 {
+		return false, fmt.Errorf("proof has %d unused siblings", len(siblings))
+	}
+
+	return bytes.Equal(current, root), nil
+}
+
+// VerifyMembership checks that key is bound to value under root
+func VerifyMembership(hashAlgo hash.Algorithm, root, key, value []byte, proof *Proof) (bool, error) {
+	if proof.Value == nil || !bytes.Equal(proof.Key, key) || !bytes.Equal(proof.Value, value) {
+		return false, nil
+	}
+	return VerifyProof(hashAlgo, root, proof)
+}
+
+// VerifyNonMembership checks that key is absent under root
+func VerifyNonMembership(hashAlgo hash.Algorithm, root, key []byte, proof *Proof) (bool, error) {
+	if proof.Value != nil || !bytes.Equal(proof.Key, key) {
+		return false, nil
+	}
+	return VerifyProof(hashAlgo, root, proof)
+}
+
+func (t *Tree) checkKey(key []byte) error {
+	if len(key) != t.keySize {
+		return fmt.Errorf("invalid key length: %d, expected %d", len(key), t.keySize)
+	}
+	return nil
+}
+
+// leafHash returns the hash of the leaf for key, empty if the key is absent
+func (t *Tree) leafHash(key []byte) ([]byte, error) {
+	value, ok := t.leaves[string(key)]
+	if !ok {
+		return t.empty, nil
+	}
+	return t.hashLeaf(key, value)
+}
+
+func (t *Tree) hashLeaf(key, value []byte) ([]byte, error) {
+	input := make([]byte, 0, 1+len(key)+len(value))
+	input = append(input, leafPrefix)
+	input = append(input, key...)
+	input = append(input, value...)
+	return hash.Hash(input, t.hashAlgo)
+}
+
+// hashChildren hashes two children; a node over two empty subtrees is itself empty
+func (t *Tree) hashChildren(left, right []byte) ([]byte, error) {
+	if bytes.Equal(left, t.empty) && bytes.Equal(right, t.empty) {
+		return t.empty, nil
+	}
+
+	input := make([]byte, 0, 1+len(left)+len(right))
+	input = append(input, nodePrefix)
+	input = append(input, left...)
+	input = append(input, right...)
+	return hash.Hash(input, t.hashAlgo)
+}
+
+// node returns the hash of the node at height whose path from the root is the
+// first depth-height bits of prefix
+func (t *Tree) node(height int, prefix []byte) []byte {
+	if h, ok := t.nodes[nodeID(height, prefix)]; ok {
+		return h
+	}
+	return t.empty
+}
+
+func (t *Tree) setNode(height int, prefix []byte, h []byte) {
+	id := nodeID(height, truncate(prefix, t.depth-height))
+	if bytes.Equal(h, t.empty) {
+		delete(t.nodes, id)
+		return
+	}
+	t.nodes[id] = h
+}
+
+// nodeID identifies a node by its height and its (truncated) path bits
+func nodeID(height int, prefix []byte) string {
+	return string([]byte{byte(height >> 8), byte(height)}) + string(prefix)
+}
+
+// truncate returns the first n bits of key, zero padded to whole bytes
+func truncate(key []byte, n int) []byte {
+	out := make([]byte, (n+7)/8)
+	copy(out, key)
+	if n%8 != 0 {
+		out[len(out)-1] &= byte(0xff << (8 - n%8))
+	}
+	return out
+}
+
+// withBit returns prefix extended to i+1 bits with bit i set to b
+func withBit(prefix []byte, i int, b byte) []byte {
+	out := make([]byte, i/8+1)
+	copy(out, prefix)
+	if b == 1 {
+		out[i/8] |= 0x80 >> (i % 8)
+	} else {
+		out[i/8] &^= 0x80 >> (i % 8)
+	}
+	return out
+}
+
+// flipBit returns a copy of prefix with bit i inverted
+func flipBit(prefix []byte, i int) []byte {
+	out := append([]byte(nil), prefix...)
+	out[i/8] ^= 0x80 >> (i % 8)
+	return out
+}
+
+// bitAt returns bit i of key, most significant bit first
+func bitAt(key []byte, i int) byte {
+	return (key[i/8] >> (7 - i%8)) & 1
+}
+
+func digestSize(hashAlgo hash.Algorithm) (int, error) {
+	h, err := hash.Hash(nil, hashAlgo)
+	if err != nil {
+		return 0, err
+	}
+	return len(h), nil
+}
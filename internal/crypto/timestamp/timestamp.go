@@ -2,7 +2,10 @@ package timestamp
 
 import (
 	"encoding/asn1"
+	"fmt"
 	"time"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
 )
 
 // Token represents an RFC 3161 compliant timestamp token
@@ -11,10 +14,8 @@ type Token struct {
 	Version int
 	// GenTime is the time at which the timestamp was generated
 	GenTime time.Time
-	// MessageImprint is the hash of the timestamped message
-	MessageImprint []byte
-	// HashAlgorithm used for the message imprint
-	HashAlgorithm string
+	// MessageImprint is the hash of the timestamped message, tagged with its algorithm
+	MessageImprint hash.Digest
 	// SerialNumber unique to this timestamp
 	SerialNumber int64
 	// TSA identifier
@@ -23,8 +24,8 @@ type Token struct {
 
 // TSAClient represents a timestamp authority client interface
 type TSAClient interface {
-	// Request requests a timestamp token for the given hash
-	Request(messageHash []byte, hashAlgo string) (*Token, error)
+	// Request requests a timestamp token for the given message imprint
+	Request(imprint hash.Digest) (*Token, error)
 }
 
 // MockTSAClient is a mock implementation for testing
@@ -38,12 +39,18 @@ func NewMockTSAClient() *MockTSAClient {
 }
 
 // Request implements TSAClient for testing
-func (m *MockTSAClient) Request(messageHash []byte, hashAlgo string) (*Token, error) {
+func (m *MockTSAClient) Request(imprint hash.Digest) (*Token, error) {
+	if err := imprint.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid message imprint: %w", err)
+	}
+	if err := hash.CheckSign(imprint.Algorithm); err != nil {
+		return nil, err
+	}
+
 	token := &Token{
 		Version:        1,
 		GenTime:        time.Now().UTC(),
-		MessageImprint: messageHash,
-		HashAlgorithm:  hashAlgo,
+		MessageImprint: imprint,
 		SerialNumber:   m.counter,
 		TSA:            "mock-tsa",
 	}
@@ -51,25 +58,32 @@ func (m *MockTSAClient) Request(messageHash []byte, hashAlgo string) (*Token, er
 	return token, nil
 }
 
+// tokenASN1 is the ASN.1 structure of an encoded token
+type tokenASN1 struct {
+	Version        int
+	GenTime        time.Time
+	MessageImprint []byte
+	HashAlgorithm  asn1.ObjectIdentifier
+	SerialNumber   int64
+	TSA            string
+}
+
 // Encode encodes the timestamp token to ASN.1 DER format
 func (t *Token) Encode() ([]byte, error) {
-	type tokenASN1 struct {
-		Version        int
-		GenTime        time.Time
-		MessageImprint []byte
-		HashAlgorithm  asn1.ObjectIdentifier
-		SerialNumber   int64
-		TSA            string
+	// RFC 3161 identifies the imprint algorithm by OID
+	spec, err := hash.Lookup(t.MessageImprint.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if spec.OID == nil {
+		return nil, fmt.Errorf("no RFC 3161 object identifier for %s", t.MessageImprint.Algorithm)
 	}
-
-	// For simplicity, using SHA-256 OID
-	sha256OID := asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
 
 	asn1Token := tokenASN1{
 		Version:        t.Version,
 		GenTime:        t.GenTime,
-		MessageImprint: t.MessageImprint,
-		HashAlgorithm:  sha256OID,
+		MessageImprint: t.MessageImprint.Value,
+		HashAlgorithm:  spec.OID,
 		SerialNumber:   t.SerialNumber,
 		TSA:            t.TSA,
 	}
@@ -77,21 +91,37 @@ func (t *Token) Encode() ([]byte, error) {
 	return asn1.Marshal(asn1Token)
 }
 
-// Verify verifies that the timestamp token is valid for the given message hash
-func (t *Token) Verify(messageHash []byte, hashAlgo string) bool {
-	if t.HashAlgorithm != hashAlgo {
-		return false
+// Decode decodes a timestamp token from ASN.1 DER format
+func Decode(data []byte) (*Token, error) {
+	var asn1Token tokenASN1
+	rest, err := asn1.Unmarshal(data, &asn1Token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode timestamp token: %w", err)
 	}
-
-	if len(t.MessageImprint) != len(messageHash) {
-		return false
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("trailing data after timestamp token")
 	}
 
-	for i := range t.MessageImprint {
-		if t.MessageImprint[i] != messageHash[i] {
-			return false
-		}
+	spec, err := hash.DefaultRegistry.LookupOID(asn1Token.HashAlgorithm)
+	if err != nil {
+		return nil, err
 	}
+	imprint := hash.Digest{Algorithm: spec.Algorithm, Value: asn1Token.MessageImprint}
+	if err := imprint.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid message imprint: %w", err)
+	}
+
+	return &Token{
+		Version:        asn1Token.Version,
+		GenTime:        asn1Token.GenTime,
+		MessageImprint: imprint,
+		SerialNumber:   asn1Token.SerialNumber,
+		TSA:            asn1Token.TSA,
+	}, nil
+}
 
-	return true
+// Verify verifies that the timestamp token is valid for the given message
+// imprint; the algorithm must match as well as the digest
+func (t *Token) Verify(imprint hash.Digest) bool {
+	return t.MessageImprint.Equal(imprint)
 }
@@ -40,6 +40,9 @@ type Identity struct {
 	RevocationPointer string `json:"revocation_pointer,omitempty" cbor:"9,keyasint,omitempty"`
 	// IdentityID is a unique identifier for this identity version
 	IdentityID string `json:"identity_id" cbor:"10,keyasint"`
+	// CertificateChain optionally binds the identity to an existing X.509
+	// credential: DER certificates, leaf first, the leaf certifying PublicKey
+	CertificateChain [][]byte `json:"certificate_chain,omitempty" cbor:"11,keyasint,omitempty"`
 }
 
 // IsValid checks if the identity is currently valid
@@ -73,6 +76,14 @@ type KeyCeremonyRecord struct {
 	PublicKeyHash []byte `json:"public_key_hash"`
 	// LedgerEntryHash is the hash of the ledger entry
 	LedgerEntryHash []byte `json:"ledger_entry_hash"`
+	// ThresholdScheme names the threshold signature scheme when the key is
+	// shared among the trustees (e.g., FROST(Ed25519, SHA-512)); empty otherwise
+	ThresholdScheme string `json:"threshold_scheme,omitempty"`
+	// VerifyingShares are the public keys of each trustee's key share, keyed by trustee
+	VerifyingShares map[string][]byte `json:"verifying_shares,omitempty"`
+	// ShareCommitments commit to each trustee's backup share of the key,
+	// keyed by trustee, so tampered shares are detected at recovery
+	ShareCommitments map[string][]byte `json:"share_commitments,omitempty"`
 }
 
 // RotationRecord records a key rotation event
@@ -0,0 +1,144 @@
+// Package pki binds identities to X.509 certificate chains issued under an
+// existing government PKI, such as PIV credentials, and validates those
+// chains against a configured set of trusted roots. Revocation of the
+// certificates is not checked; identities are revoked through the ledger.
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+	"github.com/IAmSoThirsty/civic-attest/internal/identity/models"
+)
+
+// certificatePEMType is the PEM block type of an X.509 certificate
+const certificatePEMType = "CERTIFICATE"
+
+// ParseChainPEM decodes a PEM certificate chain, leaf first, into the DER
+// certificates held in models.Identity.CertificateChain
+func ParseChainPEM(data []byte) ([][]byte, error) {
+	var chain [][]byte
+	for {
+		block, rest := pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != certificatePEMType {
+			return nil, fmt.Errorf("unexpected PEM block type: %s", block.Type)
+		}
+		chain = append(chain, block.Bytes)
+		data = rest
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no certificates found")
+	}
+
+	// Parse now so a malformed chain is refused at issuance
+	if _, err := ParseChain(chain); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// ParseChain parses DER certificates, leaf first
+func ParseChain(chain [][]byte) ([]*x509.Certificate, error) {
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("empty certificate chain")
+	}
+	certs := make([]*x509.Certificate, len(chain))
+	for i, der := range chain {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %d: %w", i, err)
+		}
+		certs[i] = cert
+	}
+	return certs, nil
+}
+
+// LoadRoots parses a PEM bundle of trusted root certificates
+func LoadRoots(data []byte) (*x509.CertPool, error) {
+	roots := x509.NewCertPool()
+	count := 0
+	for {
+		block, rest := pem.Decode(data)
+		if block == nil {
+			break
+		}
+		data = rest
+		if block.Type != certificatePEMType {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse root certificate %d: %w", count, err)
+		}
+		roots.AddCert(cert)
+		count++
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("no root certificates found")
+	}
+	return roots, nil
+}
+
+// LeafPublicKey returns the raw public key and algorithm the leaf of a chain certifies
+func LeafPublicKey(chain [][]byte) ([]byte, signatures.Algorithm, error) {
+	certs, err := ParseChain(chain)
+	if err != nil {
+		return nil, "", err
+	}
+	return leafPublicKey(certs[0])
+}
+
+// leafPublicKey returns the raw public key and algorithm a certificate certifies
+func leafPublicKey(leaf *x509.Certificate) ([]byte, signatures.Algorithm, error) {
+	publicKey, algo, err := signatures.ParsePublicKey(leaf.RawSubjectPublicKeyInfo)
+	if err != nil {
+		return nil, "", fmt.Errorf("unsupported leaf certificate key: %w", err)
+	}
+	return publicKey, algo, nil
+}
+
+// VerifyChain checks that an identity's certificate chain leads to one of
+// roots at the given time and that its leaf certifies the identity's key
+// for digital signatures
+func VerifyChain(identity *models.Identity, roots *x509.CertPool, at time.Time) error {
+	certs, err := ParseChain(identity.CertificateChain)
+	if err != nil {
+		return err
+	}
+	leaf := certs[0]
+
+	publicKey, algo, err := leafPublicKey(leaf)
+	if err != nil {
+		return err
+	}
+	if string(algo) != identity.KeyAlgorithm || !bytes.Equal(publicKey, identity.PublicKey) {
+		return fmt.Errorf("leaf certificate does not certify the identity's %s key", identity.KeyAlgorithm)
+	}
+	if leaf.KeyUsage != 0 && leaf.KeyUsage&(x509.KeyUsageDigitalSignature|x509.KeyUsageContentCommitment) == 0 {
+		return fmt.Errorf("leaf certificate is not valid for digital signatures")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	// Agency credentials carry varied extended key usages (PIV authentication,
+	// email protection, document signing), so none is required
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify certificate chain: %w", err)
+	}
+	return nil
+}
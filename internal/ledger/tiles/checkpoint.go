@@ -0,0 +1,142 @@
+package tiles
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+)
+
+// Checkpoint is the body of a C2SP tlog-checkpoint: the log origin, tree size and root hash
+type Checkpoint struct {
+	// Origin uniquely identifies the log
+	Origin string
+	// Size is the number of entries in the tree
+	Size int
+	// Root is the root hash of the tree
+	Root []byte
+}
+
+// Marshal encodes the checkpoint body
+func (c *Checkpoint) Marshal() []byte {
+	return []byte(fmt.Sprintf("%s\n%d\n%s\n", c.Origin, c.Size, base64.StdEncoding.EncodeToString(c.Root)))
+}
+
+// ParseCheckpoint decodes a checkpoint body. Extension lines after the root are ignored.
+func ParseCheckpoint(body []byte) (*Checkpoint, error) {
+	lines := strings.Split(string(body), "\n")
+	if len(lines) < 4 || lines[len(lines)-1] != "" {
+		return nil, fmt.Errorf("malformed checkpoint")
+	}
+
+	if lines[0] == "" {
+		return nil, fmt.Errorf("checkpoint has no origin")
+	}
+
+	size, err := strconv.ParseUint(lines[1], 10, 63)
+	if err != nil || strconv.FormatUint(size, 10) != lines[1] {
+		return nil, fmt.Errorf("malformed checkpoint tree size: %q", lines[1])
+	}
+
+	root, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed checkpoint root hash: %w", err)
+	}
+
+	return &Checkpoint{Origin: lines[0], Size: int(size), Root: root}, nil
+}
+
+// noteSignaturePrefix starts every signature line of a signed note
+const noteSignaturePrefix = "— "
+
+// algEd25519 is the signed-note signature type for Ed25519
+const algEd25519 = 0x01
+
+// KeyID computes the signed-note key ID for an Ed25519 key with the given name
+func KeyID(name string, publicKey ed25519.PublicKey) uint32 {
+	h := sha256.New()
+	h.Write([]byte(name))
+	h.Write([]byte{'\n', algEd25519})
+	h.Write(publicKey)
+	return binary.BigEndian.Uint32(h.Sum(nil))
+}
+
+// SignNote signs a note body with an Ed25519 signer, producing a C2SP signed note
+func SignNote(ctx context.Context, body []byte, name string, signer signatures.Signer) ([]byte, error) {
+	if len(body) == 0 || body[len(body)-1] != '\n' {
+		return nil, fmt.Errorf("note body must end with a newline")
+	}
+	if name == "" || strings.ContainsAny(name, " +\n") {
+		return nil, fmt.Errorf("invalid key name: %q", name)
+	}
+	if signer.Algorithm() != signatures.Ed25519 {
+		return nil, fmt.Errorf("signed notes require an Ed25519 key, got %s", signer.Algorithm())
+	}
+
+	sig, err := signer.Sign(ctx, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign note: %w", err)
+	}
+
+	keyed := binary.BigEndian.AppendUint32(nil, KeyID(name, signer.PublicKey()))
+	keyed = append(keyed, sig...)
+
+	var note bytes.Buffer
+	note.Write(body)
+	note.WriteString("\n")
+	note.WriteString(noteSignaturePrefix + name + " " + base64.StdEncoding.EncodeToString(keyed) + "\n")
+	return note.Bytes(), nil
+}
+
+// OpenNote verifies that a signed note carries a valid signature by the named
+// Ed25519 key and returns its body. Signatures by other keys are ignored.
+func OpenNote(note []byte, name string, publicKey ed25519.PublicKey) ([]byte, error) {
+	split := bytes.LastIndex(note, []byte("\n\n"))
+	if split < 0 {
+		return nil, fmt.Errorf("malformed note: missing signature block")
+	}
+	body := note[:split+1]
+	sigBlock := string(note[split+2:])
+
+	if !strings.HasSuffix(sigBlock, "\n") {
+		return nil, fmt.Errorf("malformed note: unterminated signature line")
+	}
+
+	keyID := KeyID(name, publicKey)
+	for _, line := range strings.Split(strings.TrimSuffix(sigBlock, "\n"), "\n") {
+		if !strings.HasPrefix(line, noteSignaturePrefix) {
+			return nil, fmt.Errorf("malformed note signature line: %q", line)
+		}
+
+		fields := strings.SplitN(strings.TrimPrefix(line, noteSignaturePrefix), " ", 2)
+		if len(fields) != 2 || fields[0] != name {
+			continue
+		}
+
+		keyed, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(keyed) < 4 {
+			return nil, fmt.Errorf("malformed note signature")
+		}
+		if binary.BigEndian.Uint32(keyed) != keyID {
+			continue
+		}
+
+		valid, err := signatures.Verify(publicKey, body, keyed[4:], signatures.Ed25519)
+		if err != nil {
+			return nil, err
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid note signature by %s", name)
+		}
+		return body, nil
+	}
+
+	return nil, fmt.Errorf("note is not signed by %s", name)
+}
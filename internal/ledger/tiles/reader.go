@@ -0,0 +1,153 @@
+package tiles
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/merkle"
+)
+
+// Fetcher retrieves the file at a tile path, e.g. from a directory, web server or CDN
+type Fetcher func(path string) ([]byte, error)
+
+// DirFetcher fetches tiles from a local directory
+func DirFetcher(dir string) Fetcher {
+	return func(path string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+	}
+}
+
+// HTTPFetcher fetches tiles from a static file server rooted at baseURL
+func HTTPFetcher(client *http.Client, baseURL string) Fetcher {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return func(path string) ([]byte, error) {
+		resp, err := client.Get(baseURL + "/" + path)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching %s: %s", path, resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	}
+}
+
+// Reader serves node hashes of a tree of fixed size from its tiles. It implements
+// merkle.NodeStore, so merkle.OpenIncrementalTree can build proofs from tiles alone.
+type Reader struct {
+	hasher   merkle.Hasher
+	fetch    Fetcher
+	treeSize int
+	hashSize int
+
+	mu    sync.Mutex
+	cache map[string][][]byte
+}
+
+// NewReader creates a reader for the tree of the given size, typically taken from a verified checkpoint
+func NewReader(hasher merkle.Hasher, fetch Fetcher, treeSize int) (*Reader, error) {
+	size, err := hashSize(hasher.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{
+		hasher:   hasher,
+		fetch:    fetch,
+		treeSize: treeSize,
+		hashSize: size,
+		cache:    make(map[string][][]byte),
+	}, nil
+}
+
+// GetNode implements merkle.NodeStore. Nodes between tile levels are
+// recomputed from the hashes of the tile below them.
+func (r *Reader) GetNode(level, index int) ([]byte, error) {
+	if level < 0 || index < 0 || (index+1)<<level > r.treeSize {
+		return nil, fmt.Errorf("node not in tree: level %d index %d", level, index)
+	}
+
+	tileLevel, height := level/Height, level%Height
+	first := index << height
+	count := 1 << height
+
+	tileIndex := first / Width
+	hashes, err := r.tile(tileLevel, tileIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := first - tileIndex*Width
+	if offset+count > len(hashes) {
+		return nil, fmt.Errorf("tile %d/%d too short for node at level %d index %d", tileLevel, tileIndex, level, index)
+	}
+
+	nodes := hashes[offset : offset+count]
+	for len(nodes) > 1 {
+		next := make([][]byte, len(nodes)/2)
+		for i := range next {
+			if next[i], err = r.hasher.HashChildren(nodes[2*i], nodes[2*i+1]); err != nil {
+				return nil, err
+			}
+		}
+		nodes = next
+	}
+	return nodes[0], nil
+}
+
+// SetNode implements merkle.NodeStore; tiles are read-only
+func (r *Reader) SetNode(level, index int, nodeHash []byte) error {
+	return fmt.Errorf("tile reader is read-only")
+}
+
+// tile fetches and splits the tile at (level, index) as of the reader's tree size
+func (r *Reader) tile(level, index int) ([][]byte, error) {
+	width := tileWidth(level, index, r.treeSize)
+	path := TilePath(level, index, width)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if hashes, ok := r.cache[path]; ok {
+		return hashes, nil
+	}
+
+	data, err := r.fetch(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tile %s: %w", path, err)
+	}
+	if len(data) != width*r.hashSize {
+		return nil, fmt.Errorf("tile %s has %d bytes, expected %d", path, len(data), width*r.hashSize)
+	}
+
+	hashes := make([][]byte, width)
+	for i := range hashes {
+		hashes[i] = data[i*r.hashSize : (i+1)*r.hashSize]
+	}
+	r.cache[path] = hashes
+	return hashes, nil
+}
+
+// ParseEntries splits an entry bundle into its entries
+func ParseEntries(data []byte) ([][]byte, error) {
+	entries := make([][]byte, 0)
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, fmt.Errorf("truncated entry length")
+		}
+		n := int(data[0])<<8 | int(data[1])
+		if len(data) < 2+n {
+			return nil, fmt.Errorf("truncated entry")
+		}
+		entries = append(entries, data[2:2+n])
+		data = data[2+n:]
+	}
+	return entries, nil
+}
@@ -0,0 +1,73 @@
+// Package tiles stores the ledger's Merkle tree as immutable tiles following
+// the C2SP tlog-tiles layout, so that proofs can be served from static files.
+package tiles
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
+)
+
+const (
+	// Height is the number of tree levels covered by one tile
+	Height = 8
+	// Width is the number of hashes in a full tile
+	Width = 1 << Height
+	// CheckpointPath is the path of the signed checkpoint
+	CheckpointPath = "checkpoint"
+)
+
+// TilePath returns the path of the tile at tile level level and index index.
+// width is the number of hashes in the tile; partial tiles get a ".p/<width>" suffix.
+func TilePath(level, index, width int) string {
+	return fmt.Sprintf("tile/%d/%s", level, encodeIndex(index, width))
+}
+
+// EntriesPath returns the path of the entry bundle with the given index and width
+func EntriesPath(index, width int) string {
+	return "tile/entries/" + encodeIndex(index, width)
+}
+
+// encodeIndex encodes a tile index as three-digit path elements, all but the
+// last prefixed with "x", e.g. 1234067 becomes "x001/x234/067"
+func encodeIndex(index, width int) string {
+	parts := []string{fmt.Sprintf("%03d", index%1000)}
+	for index >= 1000 {
+		index /= 1000
+		parts = append(parts, fmt.Sprintf("x%03d", index%1000))
+	}
+
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+
+	path := strings.Join(parts, "/")
+	if width < Width {
+		path += fmt.Sprintf(".p/%d", width)
+	}
+	return path
+}
+
+// tileWidth returns the width of the tile at (level, index) in a tree of the given size,
+// or 0 if the tree has no hashes in that tile yet
+func tileWidth(level, index, treeSize int) int {
+	nodes := treeSize >> (level * Height)
+	width := nodes - index*Width
+	if width <= 0 {
+		return 0
+	}
+	if width > Width {
+		return Width
+	}
+	return width
+}
+
+// hashSize returns the digest length of a hash algorithm
+func hashSize(algo hash.Algorithm) (int, error) {
+	h, err := hash.Hash(nil, algo)
+	if err != nil {
+		return 0, err
+	}
+	return len(h), nil
+}
@@ -0,0 +1,141 @@
+package tiles
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Source provides the node hashes and leaf data of a tree
+type Source interface {
+	// NodeHash returns the hash of the perfect subtree at (level, index)
+	NodeHash(level, index int) ([]byte, error)
+	// LeafData returns the data committed to by the leaf at index
+	LeafData(index int) ([]byte, error)
+}
+
+// Writer writes tiles and entry bundles to a directory that can be served as static files
+type Writer struct {
+	dir string
+}
+
+// NewWriter creates a tile writer rooted at dir
+func NewWriter(dir string) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create tile directory: %w", err)
+	}
+	return &Writer{dir: dir}, nil
+}
+
+// Dir returns the directory tiles are written to
+func (w *Writer) Dir() string {
+	return w.dir
+}
+
+// Update writes every tile and entry bundle that changed when the tree grew
+// from oldSize to newSize. Existing files are never modified; a tile that
+// grew is written to a new partial or full path.
+func (w *Writer) Update(src Source, oldSize, newSize int) error {
+	if oldSize < 0 || oldSize > newSize {
+		return fmt.Errorf("invalid tree sizes: %d -> %d", oldSize, newSize)
+	}
+	if oldSize == newSize {
+		return nil
+	}
+
+	for level := 0; newSize>>(level*Height) > 0; level++ {
+		first := (oldSize >> (level * Height)) / Width
+		last := ((newSize >> (level * Height)) - 1) / Width
+		for index := first; index <= last; index++ {
+			width := tileWidth(level, index, newSize)
+			if width == tileWidth(level, index, oldSize) {
+				continue
+			}
+			if err := w.writeTile(src, level, index, width); err != nil {
+				return err
+			}
+		}
+	}
+
+	for index := oldSize / Width; index <= (newSize-1)/Width; index++ {
+		width := tileWidth(0, index, newSize)
+		if err := w.writeEntries(src, index, width); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeTile writes the hashes of tree level level*Height covered by one tile
+func (w *Writer) writeTile(src Source, level, index, width int) error {
+	data := make([]byte, 0)
+	for i := 0; i < width; i++ {
+		h, err := src.NodeHash(level*Height, index*Width+i)
+		if err != nil {
+			return fmt.Errorf("failed to read node for tile %d/%d: %w", level, index, err)
+		}
+		data = append(data, h...)
+	}
+
+	return w.writeFile(TilePath(level, index, width), data)
+}
+
+// writeEntries writes an entry bundle: each entry is prefixed with its big-endian uint16 length
+func (w *Writer) writeEntries(src Source, index, width int) error {
+	data := make([]byte, 0)
+	for i := 0; i < width; i++ {
+		entry, err := src.LeafData(index*Width + i)
+		if err != nil {
+			return fmt.Errorf("failed to read entry for bundle %d: %w", index, err)
+		}
+		if len(entry) > 0xffff {
+			return fmt.Errorf("entry %d too large for an entry bundle: %d bytes", index*Width+i, len(entry))
+		}
+		data = binary.BigEndian.AppendUint16(data, uint16(len(entry)))
+		data = append(data, entry...)
+	}
+
+	return w.writeFile(EntriesPath(index, width), data)
+}
+
+// WriteCheckpoint replaces the signed checkpoint
+func (w *Writer) WriteCheckpoint(note []byte) error {
+	return w.replaceFile(CheckpointPath, note)
+}
+
+// writeFile writes an immutable file, leaving an existing one untouched
+func (w *Writer) writeFile(path string, data []byte) error {
+	if _, err := os.Stat(filepath.Join(w.dir, path)); err == nil {
+		return nil
+	}
+	return w.replaceFile(path, data)
+}
+
+// replaceFile atomically writes a file so readers never observe a partial write
+func (w *Writer) replaceFile(path string, data []byte) error {
+	full := filepath.Join(w.dir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return fmt.Errorf("failed to create tile directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(full), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return os.Rename(tmp.Name(), full)
+}
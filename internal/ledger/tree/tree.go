@@ -13,13 +13,13 @@ import (
 // Entry represents a ledger entry
 type Entry struct {
 	// EntryHash is the hash of the entry content
-	EntryHash []byte `json:"entry_hash"`
+	EntryHash hash.Digest `json:"entry_hash"`
 	// Timestamp is when the entry was created
 	Timestamp time.Time `json:"timestamp"`
 	// SignerIdentityID is the identity that signed
 	SignerIdentityID string `json:"signer_identity_id"`
 	// SignatureHash is the hash of the signature
-	SignatureHash []byte `json:"signature_hash"`
+	SignatureHash hash.Digest `json:"signature_hash"`
 	// EntryType is the type of entry
 	EntryType string `json:"entry_type"`
 	// SequenceNumber is the sequence number in the ledger
@@ -43,7 +43,7 @@ type SignedTreeHead struct {
 // LedgerTree represents the append-only Merkle tree ledger
 type LedgerTree struct {
 	mu       sync.RWMutex
-	tree     *merkle.Tree
+	tree     *merkle.IncrementalTree
 	entries  []*Entry
 	hashAlgo hash.Algorithm
 	sequence int64
@@ -52,26 +52,70 @@ type LedgerTree struct {
 // NewLedgerTree creates a new ledger tree
 func NewLedgerTree(hashAlgo hash.Algorithm) *LedgerTree {
 	return &LedgerTree{
-		tree:     merkle.NewTree(hashAlgo),
+		tree:     merkle.NewIncrementalTree(hashAlgo),
 		entries:  make([]*Entry, 0),
 		hashAlgo: hashAlgo,
 		sequence: 0,
 	}
 }
 
-// Append adds a new entry to the ledger
+// Append adds a new entry to the ledger. Its hashes must use algorithms the
+// hash policy allows for new signatures.
 func (lt *LedgerTree) Append(entry *Entry) error {
+	return lt.append(entry, false)
+}
+
+// Replay adds an entry recorded earlier, e.g. when loading a ledger from
+// storage. Entries must be replayed in sequence order, and their hashes need
+// only be acceptable for verification, so entries made with an algorithm
+// that has since been deprecated still load.
+func (lt *LedgerTree) Replay(entry *Entry) error {
+	return lt.append(entry, true)
+}
+
+// append adds a new or replayed entry after checking its hash algorithms against the policy
+func (lt *LedgerTree) append(entry *Entry, replay bool) error {
+	checkPolicy := hash.CheckSign
+	if replay {
+		now := time.Now()
+		checkPolicy = func(algo hash.Algorithm) error {
+			return hash.CheckVerify(algo, now)
+		}
+	}
+
+	if !entry.EntryHash.IsZero() {
+		if err := entry.EntryHash.Validate(); err != nil {
+			return fmt.Errorf("invalid entry hash: %w", err)
+		}
+		if err := checkPolicy(entry.EntryHash.Algorithm); err != nil {
+			return fmt.Errorf("invalid entry hash: %w", err)
+		}
+	} else if err := checkPolicy(lt.hashAlgo); err != nil {
+		return fmt.Errorf("failed to hash entry: %w", err)
+	}
+	if !entry.SignatureHash.IsZero() {
+		if err := entry.SignatureHash.Validate(); err != nil {
+			return fmt.Errorf("invalid signature hash: %w", err)
+		}
+		if err := checkPolicy(entry.SignatureHash.Algorithm); err != nil {
+			return fmt.Errorf("invalid signature hash: %w", err)
+		}
+	}
+
 	lt.mu.Lock()
 	defer lt.mu.Unlock()
 
-	// Assign sequence number
+	// Assign sequence number; replayed entries keep the one they were recorded with
+	if replay && entry.SequenceNumber != lt.sequence+1 {
+		return fmt.Errorf("replayed entry has sequence number %d, expected %d", entry.SequenceNumber, lt.sequence+1)
+	}
 	lt.sequence++
 	entry.SequenceNumber = lt.sequence
 
 	// Compute entry hash if not already set
-	if entry.EntryHash == nil {
+	if entry.EntryHash.IsZero() {
 		entryData := lt.serializeEntry(entry)
-		h, err := hash.Hash(entryData, lt.hashAlgo)
+		h, err := hash.Sum(entryData, lt.hashAlgo)
 		if err != nil {
 			return fmt.Errorf("failed to hash entry: %w", err)
 		}
@@ -81,21 +125,23 @@ func (lt *LedgerTree) Append(entry *Entry) error {
 	// Add to entries list
 	lt.entries = append(lt.entries, entry)
 
-	// Add to Merkle tree
-	if err := lt.tree.Append(entry.EntryHash); err != nil {
+	// Add to Merkle tree; the leaf commits to the algorithm as well as the digest
+	if err := lt.tree.Append(entry.EntryHash.Bytes()); err != nil {
 		return fmt.Errorf("failed to append to tree: %w", err)
 	}
 
 	return nil
 }
 
-// serializeEntry serializes an entry for hashing
+// serializeEntry serializes an entry for hashing. Entry hashes depend on this
+// layout, so it holds the raw signature hash as it always has; changing it
+// needs a new, versioned serialization.
 func (lt *LedgerTree) serializeEntry(entry *Entry) []byte {
 	// Simple concatenation for demonstration
 	// Production would use canonical encoding
 	data := fmt.Sprintf("%s|%s|%s|%d",
 		entry.SignerIdentityID,
-		hex.EncodeToString(entry.SignatureHash),
+		hex.EncodeToString(entry.SignatureHash.Value),
 		entry.EntryType,
 		entry.Timestamp.Unix(),
 	)
@@ -109,6 +155,14 @@ func (lt *LedgerTree) GetRootHash() []byte {
 	return lt.tree.RootHash()
 }
 
+// RootAt returns the root hash of the ledger when it contained size entries
+func (lt *LedgerTree) RootAt(size int) ([]byte, error) {
+	lt.mu.RLock()
+	defer lt.mu.RUnlock()
+
+	return lt.tree.RootAt(size)
+}
+
 // GetSize returns the number of entries in the ledger
 func (lt *LedgerTree) GetSize() int {
 	lt.mu.RLock()
@@ -128,6 +182,31 @@ func (lt *LedgerTree) GetEntry(index int) (*Entry, error) {
 	return lt.entries[index], nil
 }
 
+// NodeHash returns the hash of the perfect subtree at (level, index) of the ledger tree
+func (lt *LedgerTree) NodeHash(level, index int) ([]byte, error) {
+	lt.mu.RLock()
+	defer lt.mu.RUnlock()
+
+	return lt.tree.NodeHash(level, index)
+}
+
+// LeafData returns the data committed to by the leaf at index: the multihash of the entry hash
+func (lt *LedgerTree) LeafData(index int) ([]byte, error) {
+	lt.mu.RLock()
+	defer lt.mu.RUnlock()
+
+	if index < 0 || index >= len(lt.entries) {
+		return nil, fmt.Errorf("invalid entry index: %d", index)
+	}
+
+	return lt.entries[index].EntryHash.Bytes(), nil
+}
+
+// Hasher returns the Merkle hasher used by the ledger tree
+func (lt *LedgerTree) Hasher() merkle.Hasher {
+	return lt.tree.Hasher()
+}
+
 // GenerateInclusionProof generates a proof that an entry is in the ledger
 func (lt *LedgerTree) GenerateInclusionProof(index int) (*merkle.InclusionProof, error) {
 	lt.mu.RLock()
@@ -136,6 +215,23 @@ func (lt *LedgerTree) GenerateInclusionProof(index int) (*merkle.InclusionProof,
 	return lt.tree.GenerateInclusionProof(index)
 }
 
+// InclusionProofAt generates a proof that an entry is in the ledger as of an
+// earlier tree size, e.g. the size recorded in a bundle or a cached tree head
+func (lt *LedgerTree) InclusionProofAt(index, size int) (*merkle.InclusionProof, error) {
+	lt.mu.RLock()
+	defer lt.mu.RUnlock()
+
+	return lt.tree.InclusionProofAt(index, size)
+}
+
+// GenerateMultiInclusionProof generates a single proof that several entries are in the ledger
+func (lt *LedgerTree) GenerateMultiInclusionProof(indices []int) (*merkle.MultiInclusionProof, error) {
+	lt.mu.RLock()
+	defer lt.mu.RUnlock()
+
+	return lt.tree.GenerateMultiInclusionProof(indices)
+}
+
 // GenerateConsistencyProof generates a proof that the tree is consistent
 func (lt *LedgerTree) GenerateConsistencyProof(oldSize int) (*merkle.ConsistencyProof, error) {
 	lt.mu.RLock()
@@ -156,13 +252,17 @@ func (lt *LedgerTree) GetSignedTreeHead() *SignedTreeHead {
 	}
 }
 
-// VerifyConsistency verifies that the ledger maintains append-only property
-func (lt *LedgerTree) VerifyConsistency(oldSTH, newSTH *SignedTreeHead) bool {
-	// Simplified verification
-	// Production would verify the consistency proof
-	if newSTH.TreeSize < oldSTH.TreeSize {
-		return false
+// VerifyConsistency verifies that newSTH extends oldSTH, i.e. that the ledger
+// only appended entries between the two tree heads
+func (lt *LedgerTree) VerifyConsistency(oldSTH, newSTH *SignedTreeHead, proof *merkle.ConsistencyProof) (bool, error) {
+	if proof == nil {
+		return false, fmt.Errorf("missing consistency proof")
+	}
+
+	if proof.OldSize != oldSTH.TreeSize || proof.NewSize != newSTH.TreeSize {
+		return false, fmt.Errorf("consistency proof covers %d -> %d, tree heads are %d -> %d",
+			proof.OldSize, proof.NewSize, oldSTH.TreeSize, newSTH.TreeSize)
 	}
 
-	return true
+	return merkle.VerifyConsistency(lt.tree.Hasher(), oldSTH.TreeSize, newSTH.TreeSize, oldSTH.RootHash, newSTH.RootHash, proof.Path)
 }
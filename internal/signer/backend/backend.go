@@ -0,0 +1,114 @@
+// Package backend provides signatures.Signer implementations for the places
+// signing keys are kept: passphrase-encrypted key files, PKCS#11 tokens (HSMs)
+// and remote key management services. Keys are named by URI:
+//
+//	file:/etc/civic-attest/office.key
+//	pkcs11:token=civic;object=office-key?module-path=/usr/lib/softhsm/libsofthsm2.so
+//	kms:https://kms.example.gov/v1/keys/8f2c...
+//
+// Secrets (passphrase, PIN, KMS token) are read from the environment so they
+// do not appear in process listings or shell history.
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/keyfile"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+)
+
+// Environment variables holding backend secrets
+const (
+	// PassphraseEnv holds the passphrase of encrypted key files
+	PassphraseEnv = "CIVIC_ATTEST_KEY_PASSPHRASE"
+	// PINEnv holds the PKCS#11 user PIN
+	PINEnv = "CIVIC_ATTEST_PKCS11_PIN"
+	// KMSTokenEnv holds the bearer token for the remote KMS
+	KMSTokenEnv = "CIVIC_ATTEST_KMS_TOKEN"
+	// IdentityFileEnv names the X25519 private key file that opens
+	// recipient-encrypted key files
+	IdentityFileEnv = "CIVIC_ATTEST_KEY_IDENTITY_FILE"
+)
+
+// Identified is implemented by signers whose keys record the identity and
+// key version they belong to, so callers can refuse to sign with the wrong key
+type Identified interface {
+	// IdentityID returns the identity the key belongs to, or "" if unrecorded
+	IdentityID() string
+	// KeyVersion returns the identity key version, or 0 if unrecorded
+	KeyVersion() int
+}
+
+// URI schemes of the backends
+const (
+	schemeFile   = "file:"
+	schemePKCS11 = "pkcs11:"
+	schemeKMS    = "kms:"
+)
+
+// IsURI reports whether s names a key by backend URI rather than a plain key file path
+func IsURI(s string) bool {
+	return strings.HasPrefix(s, schemeFile) || strings.HasPrefix(s, schemePKCS11) || strings.HasPrefix(s, schemeKMS)
+}
+
+// Open opens an existing key
+func Open(ctx context.Context, uri string) (signatures.Signer, error) {
+	switch {
+	case strings.HasPrefix(uri, schemeFile):
+		return OpenFile(strings.TrimPrefix(uri, schemeFile), os.Getenv(PassphraseEnv))
+	case strings.HasPrefix(uri, schemePKCS11):
+		cfg, err := ParsePKCS11URI(uri)
+		if err != nil {
+			return nil, err
+		}
+		return openPKCS11(cfg)
+	case strings.HasPrefix(uri, schemeKMS):
+		return OpenKMS(ctx, strings.TrimPrefix(uri, schemeKMS), os.Getenv(KMSTokenEnv))
+	default:
+		return nil, fmt.Errorf("unsupported key URI: %q", uri)
+	}
+}
+
+// Generate creates a new key and returns its signer and the URI that opens it
+// later. A kms: URI names the KMS base URL; the KMS assigns the key ID.
+func Generate(ctx context.Context, uri string, algo signatures.Algorithm) (signatures.Signer, string, error) {
+	switch {
+	case strings.HasPrefix(uri, schemeFile):
+		signer, err := CreateFile(strings.TrimPrefix(uri, schemeFile), algo, os.Getenv(PassphraseEnv))
+		if err != nil {
+			return nil, "", err
+		}
+		return signer, uri, nil
+	case strings.HasPrefix(uri, schemePKCS11):
+		cfg, err := ParsePKCS11URI(uri)
+		if err != nil {
+			return nil, "", err
+		}
+		signer, err := generatePKCS11(cfg, algo)
+		if err != nil {
+			return nil, "", err
+		}
+		return signer, uri, nil
+	case strings.HasPrefix(uri, schemeKMS):
+		signer, err := CreateKMSKey(ctx, strings.TrimPrefix(uri, schemeKMS), os.Getenv(KMSTokenEnv), algo)
+		if err != nil {
+			return nil, "", err
+		}
+		return signer, schemeKMS + signer.KeyURL(), nil
+	default:
+		return nil, "", fmt.Errorf("unsupported key URI: %q", uri)
+	}
+}
+
+// Import stores an existing private key, such as one recovered from backup
+// shares. Only key files accept imported keys; HSM and KMS keys are generated
+// in place and cannot be restored from outside.
+func Import(uri string, key *keyfile.Key) (signatures.Signer, error) {
+	if !strings.HasPrefix(uri, schemeFile) {
+		return nil, fmt.Errorf("importing keys is only supported for %s URIs", schemeFile)
+	}
+	return WriteFile(strings.TrimPrefix(uri, schemeFile), key, os.Getenv(PassphraseEnv))
+}
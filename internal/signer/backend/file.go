@@ -0,0 +1,143 @@
+package backend
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/keyfile"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+)
+
+// FileSigner is a signer over a decrypted key file. It carries the identity
+// and key version recorded in the file.
+type FileSigner struct {
+	*signatures.KeySigner
+	identityID string
+	keyVersion int
+}
+
+// IdentityID returns the identity the key belongs to, or "" if unrecorded
+func (s *FileSigner) IdentityID() string {
+	return s.identityID
+}
+
+// KeyVersion returns the identity key version of the key, or 0 if unrecorded
+func (s *FileSigner) KeyVersion() int {
+	return s.keyVersion
+}
+
+// EncryptKey seals a private key into a passphrase-encrypted key file
+func EncryptKey(privateKey []byte, algo signatures.Algorithm, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("no passphrase given (set %s)", PassphraseEnv)
+	}
+	return keyfile.Encrypt(&keyfile.Key{PrivateKey: privateKey, Algorithm: algo}, passphrase, keyfile.KDFScrypt)
+}
+
+// DecryptKey opens a passphrase-encrypted key file and returns the private key and its algorithm
+func DecryptKey(data []byte, passphrase string) ([]byte, signatures.Algorithm, error) {
+	if passphrase == "" {
+		return nil, "", fmt.Errorf("no passphrase given (set %s)", PassphraseEnv)
+	}
+	key, err := keyfile.Decrypt(data, passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+	return key.PrivateKey, key.Algorithm, nil
+}
+
+// OpenFile opens a key file. A passphrase-encrypted file is opened with
+// passphrase; a recipient-encrypted file with the X25519 key named by
+// IdentityFileEnv.
+func OpenFile(path, passphrase string) (signatures.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	var key *keyfile.Key
+	if keyfile.IsRecipientEncrypted(data) {
+		identity, err := readIdentityFile(os.Getenv(IdentityFileEnv))
+		if err != nil {
+			return nil, err
+		}
+		key, err = keyfile.DecryptWith(data, identity)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if passphrase == "" {
+			return nil, fmt.Errorf("no passphrase given (set %s)", PassphraseEnv)
+		}
+		key, err = keyfile.Decrypt(data, passphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newFileSigner(key)
+}
+
+// CreateFile generates a key and writes it to a new encrypted key file. An
+// existing file is never overwritten.
+func CreateFile(path string, algo signatures.Algorithm, passphrase string) (signatures.Signer, error) {
+	kp, err := signatures.GenerateKeyPair(algo)
+	if err != nil {
+		return nil, err
+	}
+	return WriteFile(path, &keyfile.Key{PrivateKey: kp.PrivateKey, Algorithm: algo}, passphrase)
+}
+
+// WriteFile writes an existing key, such as one imported from PEM or
+// recovered from backup shares, to a new encrypted key file. An existing
+// file is never overwritten.
+func WriteFile(path string, key *keyfile.Key, passphrase string) (signatures.Signer, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("no passphrase given (set %s)", PassphraseEnv)
+	}
+	data, err := keyfile.Encrypt(key, passphrase, keyfile.KDFScrypt)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key file: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write key file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write key file: %w", err)
+	}
+
+	return newFileSigner(key)
+}
+
+// newFileSigner creates a signer for a decrypted key
+func newFileSigner(key *keyfile.Key) (*FileSigner, error) {
+	signer, err := signatures.NewKeySigner(key.PrivateKey, key.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSigner{KeySigner: signer, identityID: key.IdentityID, keyVersion: key.KeyVersion}, nil
+}
+
+// readIdentityFile reads a hex-encoded X25519 private key
+func readIdentityFile(path string) ([]byte, error) {
+	if path == "" {
+		return nil, fmt.Errorf("key file is encrypted to a recipient key; set %s", IdentityFileEnv)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity key: %w", err)
+	}
+	identity, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode identity key: %w", err)
+	}
+	return identity, nil
+}
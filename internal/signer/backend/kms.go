@@ -0,0 +1,191 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+)
+
+// The remote KMS protocol is plain JSON over HTTP, with byte fields base64
+// encoded and an optional bearer token:
+//
+//	POST {base}/v1/keys             {"algorithm"}  -> KMSKey
+//	GET  {base}/v1/keys/{id}                       -> KMSKey
+//	POST {base}/v1/keys/{id}/sign   KMSSignRequest -> KMSSignResponse
+//
+// Errors are returned with a non-2xx status and a KMSError body.
+
+// KMSKey describes a key held by the KMS
+type KMSKey struct {
+	// KeyID identifies the key within the KMS
+	KeyID string `json:"key_id"`
+	// Algorithm is the signature algorithm of the key
+	Algorithm signatures.Algorithm `json:"algorithm"`
+	// PublicKey is the raw public key
+	PublicKey []byte `json:"public_key,omitempty"`
+}
+
+// KMSSignRequest asks the KMS to sign a digest
+type KMSSignRequest struct {
+	// Digest is the data to sign
+	Digest []byte `json:"digest"`
+}
+
+// KMSSignResponse carries the signature over the requested digest
+type KMSSignResponse struct {
+	// Signature is the signature over the digest
+	Signature []byte `json:"signature"`
+}
+
+// KMSError is the body of a failed KMS request
+type KMSError struct {
+	// Error describes the failure
+	Error string `json:"error"`
+}
+
+// KMSSigner signs with a key held by a remote KMS. Every signature is checked
+// against the key's public key before it is returned, so a faulty or
+// compromised KMS cannot hand back a signature by a different key.
+type KMSSigner struct {
+	client    *http.Client
+	keyURL    string
+	token     string
+	publicKey []byte
+	algo      signatures.Algorithm
+}
+
+// OpenKMS opens the KMS key at keyURL, e.g. https://kms.example.gov/v1/keys/8f2c
+func OpenKMS(ctx context.Context, keyURL, token string) (*KMSSigner, error) {
+	s := &KMSSigner{client: &http.Client{Timeout: 30 * time.Second}, keyURL: strings.TrimSuffix(keyURL, "/"), token: token}
+
+	var key KMSKey
+	if err := s.call(ctx, http.MethodGet, s.keyURL, nil, &key); err != nil {
+		return nil, fmt.Errorf("failed to fetch KMS key: %w", err)
+	}
+	if err := s.setKey(&key); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// CreateKMSKey asks the KMS at baseURL to generate a new key
+func CreateKMSKey(ctx context.Context, baseURL, token string, algo signatures.Algorithm) (*KMSSigner, error) {
+	s := &KMSSigner{client: &http.Client{Timeout: 30 * time.Second}, token: token}
+
+	var key KMSKey
+	keysURL := strings.TrimSuffix(baseURL, "/") + "/v1/keys"
+	if err := s.call(ctx, http.MethodPost, keysURL, &KMSKey{Algorithm: algo}, &key); err != nil {
+		return nil, fmt.Errorf("failed to create KMS key: %w", err)
+	}
+	if key.KeyID == "" || strings.ContainsAny(key.KeyID, "/?#") {
+		return nil, fmt.Errorf("KMS returned invalid key ID: %q", key.KeyID)
+	}
+	if key.Algorithm != algo {
+		return nil, fmt.Errorf("KMS created an %s key, requested %s", key.Algorithm, algo)
+	}
+
+	s.keyURL = keysURL + "/" + key.KeyID
+	if err := s.setKey(&key); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// KeyURL returns the URL of the key within the KMS
+func (s *KMSSigner) KeyURL() string {
+	return s.keyURL
+}
+
+// PublicKey returns the raw public key
+func (s *KMSSigner) PublicKey() []byte {
+	return append([]byte(nil), s.publicKey...)
+}
+
+// Algorithm returns the signature algorithm of the key
+func (s *KMSSigner) Algorithm() signatures.Algorithm {
+	return s.algo
+}
+
+// Sign asks the KMS to sign a digest
+func (s *KMSSigner) Sign(ctx context.Context, digest []byte) ([]byte, error) {
+	var resp KMSSignResponse
+	if err := s.call(ctx, http.MethodPost, s.keyURL+"/sign", &KMSSignRequest{Digest: digest}, &resp); err != nil {
+		return nil, fmt.Errorf("KMS signing failed: %w", err)
+	}
+
+	valid, err := signatures.Verify(s.publicKey, digest, resp.Signature, s.algo)
+	if err != nil || !valid {
+		return nil, fmt.Errorf("KMS returned a signature that does not verify under the key")
+	}
+	return resp.Signature, nil
+}
+
+// setKey checks and records the key description returned by the KMS
+func (s *KMSSigner) setKey(key *KMSKey) error {
+	size, err := signatures.PublicKeySize(key.Algorithm)
+	if err != nil {
+		return err
+	}
+	if len(key.PublicKey) != size {
+		return fmt.Errorf("KMS returned invalid %s public key length: %d", key.Algorithm, len(key.PublicKey))
+	}
+
+	s.publicKey = key.PublicKey
+	s.algo = key.Algorithm
+	return nil
+}
+
+// call performs one JSON request against the KMS
+func (s *KMSSigner) call(ctx context.Context, method, url string, req, resp interface{}) error {
+	var body io.Reader
+	if req != nil {
+		data, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if req != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if s.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	httpResp, err := s.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(httpResp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read KMS response: %w", err)
+	}
+
+	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+		var kmsErr KMSError
+		if json.Unmarshal(data, &kmsErr) == nil && kmsErr.Error != "" {
+			return fmt.Errorf("%s: %s", httpResp.Status, kmsErr.Error)
+		}
+		return fmt.Errorf("%s", httpResp.Status)
+	}
+
+	if err := json.Unmarshal(data, resp); err != nil {
+		return fmt.Errorf("failed to decode KMS response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package backend
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+)
+
+// KMSServer is a local stand-in for a remote KMS speaking the protocol
+// KMSSigner expects. Keys are generated and held in memory, so it is meant
+// for development and tests, not for production keys.
+type KMSServer struct {
+	mu    sync.RWMutex
+	keys  map[string]*signatures.KeySigner
+	token string
+	mux   *http.ServeMux
+}
+
+// NewKMSServer creates a stand-in KMS. Requests must carry token as a bearer
+// token unless it is empty.
+func NewKMSServer(token string) *KMSServer {
+	s := &KMSServer{
+		keys:  make(map[string]*signatures.KeySigner),
+		token: token,
+		mux:   http.NewServeMux(),
+	}
+	s.mux.HandleFunc("POST /v1/keys", s.createKey)
+	s.mux.HandleFunc("GET /v1/keys/{id}", s.getKey)
+	s.mux.HandleFunc("POST /v1/keys/{id}/sign", s.sign)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *KMSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) != 1 {
+		writeKMSError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *KMSServer) createKey(w http.ResponseWriter, r *http.Request) {
+	var req KMSKey
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		writeKMSError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	kp, err := signatures.GenerateKeyPair(req.Algorithm)
+	if err != nil {
+		writeKMSError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	signer, err := signatures.NewKeySigner(kp.PrivateKey, kp.Algorithm)
+	if err != nil {
+		writeKMSError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		writeKMSError(w, http.StatusInternalServerError, "failed to generate key ID")
+		return
+	}
+	keyID := hex.EncodeToString(id)
+
+	s.mu.Lock()
+	s.keys[keyID] = signer
+	s.mu.Unlock()
+
+	writeKMSJSON(w, http.StatusCreated, &KMSKey{KeyID: keyID, Algorithm: signer.Algorithm(), PublicKey: signer.PublicKey()})
+}
+
+func (s *KMSServer) getKey(w http.ResponseWriter, r *http.Request) {
+	keyID := r.PathValue("id")
+	signer, ok := s.lookup(keyID)
+	if !ok {
+		writeKMSError(w, http.StatusNotFound, "unknown key")
+		return
+	}
+
+	writeKMSJSON(w, http.StatusOK, &KMSKey{KeyID: keyID, Algorithm: signer.Algorithm(), PublicKey: signer.PublicKey()})
+}
+
+func (s *KMSServer) sign(w http.ResponseWriter, r *http.Request) {
+	signer, ok := s.lookup(r.PathValue("id"))
+	if !ok {
+		writeKMSError(w, http.StatusNotFound, "unknown key")
+		return
+	}
+
+	var req KMSSignRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil || len(req.Digest) == 0 {
+		writeKMSError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	sig, err := signer.Sign(r.Context(), req.Digest)
+	if err != nil {
+		writeKMSError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeKMSJSON(w, http.StatusOK, &KMSSignResponse{Signature: sig})
+}
+
+func (s *KMSServer) lookup(keyID string) (*signatures.KeySigner, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	signer, ok := s.keys[keyID]
+	return signer, ok
+}
+
+func writeKMSJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeKMSError(w http.ResponseWriter, status int, msg string) {
+	writeKMSJSON(w, status, &KMSError{Error: msg})
+}
@@ -0,0 +1,356 @@
+//go:build pkcs11
+
+package backend
+
+import (
+	"context"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+)
+
+// PKCS#11 3.0 EdDSA identifiers, not defined by the 2.40 headers of miekg/pkcs11
+const (
+	ckkECEdwards           = 0x00000040
+	ckmECEdwardsKeyPairGen = 0x00001055
+	ckmEdDSA               = 0x00001057
+)
+
+// Curve identifiers for CKA_EC_PARAMS (RFC 8410, RFC 5480)
+var (
+	oidEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+	oidEd448   = asn1.ObjectIdentifier{1, 3, 101, 113}
+	oidP256    = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidP384    = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+)
+
+// PKCS11Signer signs with a non-extractable EdDSA or ECDSA key on a PKCS#11
+// token, such as a PIV smart card. Ed448 keys sign with CKM_EDDSA without
+// parameters, i.e. pure Ed448 with an empty context. ECDSA keys sign the
+// SHA-256 or SHA-384 digest with CKM_ECDSA.
+type PKCS11Signer struct {
+	mu        sync.Mutex
+	ctx       *pkcs11.Ctx
+	session   pkcs11.SessionHandle
+	key       pkcs11.ObjectHandle
+	publicKey []byte
+	algo      signatures.Algorithm
+	// finalize is set if this signer initialized the module and must finalize it
+	finalize bool
+}
+
+// OpenPKCS11 opens an existing key pair on a token
+func OpenPKCS11(cfg *PKCS11Config) (*PKCS11Signer, error) {
+	s, err := openSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.findObject(pkcs11.CKO_PRIVATE_KEY, cfg.Object)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	pub, err := s.findObject(pkcs11.CKO_PUBLIC_KEY, cfg.Object)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	s.key = key
+	if err := s.loadPublicKey(pub); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// GeneratePKCS11 generates a key pair on a token. The private key is
+// sensitive and non-extractable, so it never leaves the token.
+func GeneratePKCS11(cfg *PKCS11Config, algo signatures.Algorithm) (*PKCS11Signer, error) {
+	curve, err := curveOID(algo)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(curve)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := openSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.findObject(pkcs11.CKO_PRIVATE_KEY, cfg.Object); err == nil {
+		s.Close()
+		return nil, fmt.Errorf("token %s already holds a key labelled %s", cfg.Token, cfg.Object)
+	}
+
+	keyType, mechanism := uint(ckkECEdwards), uint(ckmECEdwardsKeyPairGen)
+	if signatures.IsECDSA(algo) {
+		keyType, mechanism = pkcs11.CKK_EC, pkcs11.CKM_EC_KEY_PAIR_GEN
+	}
+
+	publicTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, cfg.Object),
+	}
+	privateTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, cfg.Object),
+	}
+
+	pub, priv, err := s.ctx.GenerateKeyPair(s.session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)},
+		publicTemplate, privateTemplate)
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to generate %s key on token: %w", algo, err)
+	}
+
+	s.key = priv
+	if err := s.loadPublicKey(pub); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// PublicKey returns the raw public key
+func (s *PKCS11Signer) PublicKey() []byte {
+	return append([]byte(nil), s.publicKey...)
+}
+
+// Algorithm returns the signature algorithm of the key
+func (s *PKCS11Signer) Algorithm() signatures.Algorithm {
+	return s.algo
+}
+
+// Sign signs a digest on the token
+func (s *PKCS11Signer) Sign(ctx context.Context, digest []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// A session runs one operation at a time
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mechanism, message := uint(ckmEdDSA), digest
+	if signatures.IsECDSA(s.algo) {
+		mechanism, message = pkcs11.CKM_ECDSA, signatures.ECDSADigest(digest, s.algo)
+	}
+
+	if err := s.ctx.SignInit(s.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, s.key); err != nil {
+		return nil, fmt.Errorf("failed to start PKCS#11 signing: %w", err)
+	}
+	sig, err := s.ctx.Sign(s.session, message)
+	if err != nil {
+		return nil, fmt.Errorf("PKCS#11 signing failed: %w", err)
+	}
+	if signatures.IsECDSA(s.algo) {
+		return ecdsaSignatureDER(sig)
+	}
+	return sig, nil
+}
+
+// ecdsaSignatureDER converts a CKM_ECDSA signature, r and s concatenated,
+// into the DER encoding used by signatures.Verify
+func ecdsaSignatureDER(sig []byte) ([]byte, error) {
+	if len(sig) == 0 || len(sig)%2 != 0 {
+		return nil, fmt.Errorf("malformed ECDSA signature from token")
+	}
+	half := len(sig) / 2
+	return asn1.Marshal(struct{ R, S *big.Int }{
+		R: new(big.Int).SetBytes(sig[:half]),
+		S: new(big.Int).SetBytes(sig[half:]),
+	})
+}
+
+// Close closes the session and releases the PKCS#11 module
+func (s *PKCS11Signer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.ctx.CloseSession(s.session)
+	if s.finalize {
+		err = s.ctx.Finalize()
+	}
+	s.ctx.Destroy()
+	return err
+}
+
+// openSession loads the module and logs in to the token with the configured label
+func openSession(cfg *PKCS11Config) (*PKCS11Signer, error) {
+	p := pkcs11.New(cfg.Module)
+	if p == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module: %s", cfg.Module)
+	}
+	// The module is shared by every signer in the process; only the first initializes it
+	finalize := true
+	if err := p.Initialize(); err == pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		finalize = false
+	} else if err != nil {
+		p.Destroy()
+		return nil, fmt.Errorf("failed to initialize PKCS#11 module: %w", err)
+	}
+
+	fail := func(err error) (*PKCS11Signer, error) {
+		if finalize {
+			p.Finalize()
+		}
+		p.Destroy()
+		return nil, err
+	}
+
+	slots, err := p.GetSlotList(true)
+	if err != nil {
+		return fail(fmt.Errorf("failed to list PKCS#11 slots: %w", err))
+	}
+
+	for _, slot := range slots {
+		info, err := p.GetTokenInfo(slot)
+		if err != nil || strings.TrimRight(info.Label, " \x00") != cfg.Token {
+			continue
+		}
+
+		session, err := p.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+		if err != nil {
+			return fail(fmt.Errorf("failed to open PKCS#11 session: %w", err))
+		}
+		if err := p.Login(session, pkcs11.CKU_USER, cfg.PIN); err != nil && err != pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+			p.CloseSession(session)
+			return fail(fmt.Errorf("failed to log in to token %s: %w", cfg.Token, err))
+		}
+		return &PKCS11Signer{ctx: p, session: session, finalize: finalize}, nil
+	}
+
+	return fail(fmt.Errorf("PKCS#11 token not found: %s", cfg.Token))
+}
+
+// findObject finds the single object of a class with a label
+func (s *PKCS11Signer) findObject(class uint, label string) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := s.ctx.FindObjectsInit(s.session, template); err != nil {
+		return 0, fmt.Errorf("failed to search token: %w", err)
+	}
+	objects, _, err := s.ctx.FindObjects(s.session, 2)
+	s.ctx.FindObjectsFinal(s.session)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search token: %w", err)
+	}
+
+	switch len(objects) {
+	case 0:
+		return 0, fmt.Errorf("no key labelled %s on token", label)
+	case 1:
+		return objects[0], nil
+	default:
+		return 0, fmt.Errorf("several keys labelled %s on token", label)
+	}
+}
+
+// loadPublicKey reads the curve and point of an EdDSA or ECDSA public key object
+func (s *PKCS11Signer) loadPublicKey(pub pkcs11.ObjectHandle) error {
+	attrs, err := s.ctx.GetAttributeValue(s.session, pub, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read public key: %w", err)
+	}
+
+	algo, err := curveAlgorithm(attrs[0].Value)
+	if err != nil {
+		return err
+	}
+
+	// CKA_EC_POINT is a DER OCTET STRING, though some tokens return the raw point
+	point := attrs[1].Value
+	size, _ := signatures.PublicKeySize(algo)
+	if len(point) != size {
+		var raw []byte
+		if rest, err := asn1.Unmarshal(point, &raw); err != nil || len(rest) != 0 || len(raw) != size {
+			return fmt.Errorf("malformed %s public key on token", algo)
+		}
+		point = raw
+	}
+
+	s.publicKey = point
+	s.algo = algo
+	return nil
+}
+
+func curveOID(algo signatures.Algorithm) (asn1.ObjectIdentifier, error) {
+	switch algo {
+	case signatures.Ed25519:
+		return oidEd25519, nil
+	case signatures.Ed448:
+		return oidEd448, nil
+	case signatures.ECDSAP256:
+		return oidP256, nil
+	case signatures.ECDSAP384:
+		return oidP384, nil
+	default:
+		return nil, fmt.Errorf("unsupported PKCS#11 key algorithm: %s", algo)
+	}
+}
+
+// curveAlgorithm maps CKA_EC_PARAMS, an OID or a printable curve name, to an algorithm
+func curveAlgorithm(params []byte) (signatures.Algorithm, error) {
+	var oid asn1.ObjectIdentifier
+	if rest, err := asn1.Unmarshal(params, &oid); err == nil && len(rest) == 0 {
+		switch {
+		case oid.Equal(oidEd25519):
+			return signatures.Ed25519, nil
+		case oid.Equal(oidEd448):
+			return signatures.Ed448, nil
+		case oid.Equal(oidP256):
+			return signatures.ECDSAP256, nil
+		case oid.Equal(oidP384):
+			return signatures.ECDSAP384, nil
+		}
+		return "", fmt.Errorf("unsupported token key curve: %s", oid)
+	}
+
+	var name string
+	if rest, err := asn1.UnmarshalWithParams(params, &name, "printable"); err == nil && len(rest) == 0 {
+		switch name {
+		case "edwards25519":
+			return signatures.Ed25519, nil
+		case "edwards448":
+			return signatures.Ed448, nil
+		}
+		return "", fmt.Errorf("unsupported token key curve: %s", name)
+	}
+
+	return "", fmt.Errorf("malformed token key curve parameters")
+}
+
+func openPKCS11(cfg *PKCS11Config) (signatures.Signer, error) {
+	return OpenPKCS11(cfg)
+}
+
+func generatePKCS11(cfg *PKCS11Config, algo signatures.Algorithm) (signatures.Signer, error) {
+	return GeneratePKCS11(cfg, algo)
+}
@@ -0,0 +1,19 @@
+//go:build !pkcs11
+
+package backend
+
+import (
+	"fmt"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+)
+
+// PKCS#11 support needs cgo and is only compiled with the pkcs11 build tag
+
+func openPKCS11(cfg *PKCS11Config) (signatures.Signer, error) {
+	return nil, fmt.Errorf("PKCS#11 support not compiled in (build with -tags pkcs11)")
+}
+
+func generatePKCS11(cfg *PKCS11Config, algo signatures.Algorithm) (signatures.Signer, error) {
+	return nil, fmt.Errorf("PKCS#11 support not compiled in (build with -tags pkcs11)")
+}
@@ -0,0 +1,79 @@
+package backend
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// PKCS11Config locates a key on a PKCS#11 token
+type PKCS11Config struct {
+	// Module is the path of the PKCS#11 library, e.g. libsofthsm2.so
+	Module string
+	// Token is the token label
+	Token string
+	// Object is the key label; the private and public key share it
+	Object string
+	// PIN is the user PIN
+	PIN string
+}
+
+// ParsePKCS11URI parses the subset of RFC 7512 PKCS#11 URIs used to name
+// keys: the token and object path attributes and the module-path and
+// pin-value query attributes. The PIN defaults to CIVIC_ATTEST_PKCS11_PIN.
+func ParsePKCS11URI(uri string) (*PKCS11Config, error) {
+	if !strings.HasPrefix(uri, schemePKCS11) {
+		return nil, fmt.Errorf("not a PKCS#11 URI: %q", uri)
+	}
+	path, query, _ := strings.Cut(strings.TrimPrefix(uri, schemePKCS11), "?")
+
+	cfg := &PKCS11Config{PIN: os.Getenv(PINEnv)}
+	for _, attr := range strings.Split(path, ";") {
+		if attr == "" {
+			continue
+		}
+		name, value, err := pkcs11Attribute(attr)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "token":
+			cfg.Token = value
+		case "object":
+			cfg.Object = value
+		}
+	}
+	for _, attr := range strings.Split(query, "&") {
+		if attr == "" {
+			continue
+		}
+		name, value, err := pkcs11Attribute(attr)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "module-path":
+			cfg.Module = value
+		case "pin-value":
+			cfg.PIN = value
+		}
+	}
+
+	if cfg.Module == "" || cfg.Token == "" || cfg.Object == "" {
+		return nil, fmt.Errorf("PKCS#11 URI needs token, object and module-path: %q", uri)
+	}
+	return cfg, nil
+}
+
+func pkcs11Attribute(attr string) (string, string, error) {
+	name, raw, ok := strings.Cut(attr, "=")
+	if !ok {
+		return "", "", fmt.Errorf("malformed PKCS#11 URI attribute: %q", attr)
+	}
+	value, err := url.PathUnescape(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("malformed PKCS#11 URI attribute %s: %w", name, err)
+	}
+	return name, value, nil
+}
@@ -1,16 +1,53 @@
 package bundle
 
 import (
+	"fmt"
 	"time"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/canonical"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Bundle format versions
+const (
+	// LegacyVersion bundles carry the raw content hash under key 1 and its
+	// algorithm under key 2, and their signature covers the raw hash
+	LegacyVersion = "1.0"
+	// MultihashVersion bundles carry self-describing digests, and their
+	// signature covers the multihash of the content hash
+	MultihashVersion = "1.1"
+	// CurrentVersion bundles sign a payload binding the content hash to the
+	// rules it was computed with
+	CurrentVersion = "1.2"
 )
 
+// SigningContext separates the signing payload of bundles from anything else
+// a key may sign
+const SigningContext = "civic-attest bundle signature v1"
+
+// signingPayload is what the signatures of current bundles cover. It holds
+// every bundle field that changes how the content hash is reproduced, so none
+// can be altered without breaking the signature.
+type signingPayload struct {
+	_                      struct{} `cbor:",toarray"`
+	Context                string
+	ContentHash            []byte
+	ContentType            string
+	UnicodeNormalization   string
+	CanonicalFormatVersion string
+}
+
+// payloadEncMode encodes signing payloads in canonical CBOR
+var payloadEncMode, _ = cbor.CanonicalEncOptions().EncMode()
+
 // SignatureBundle represents the complete signature bundle format
 type SignatureBundle struct {
-	// ContentHash is the hash of the canonical content
-	ContentHash []byte `json:"content_hash" cbor:"1,keyasint"`
-	// ContentHashAlgorithm is the algorithm used for content hash
-	ContentHashAlgorithm string `json:"content_hash_algorithm" cbor:"2,keyasint"`
-	// CanonicalFormatVersion is the version of the canonicalization format
+	// ContentHash is the hash of the canonical content, tagged with its algorithm.
+	// Key 2 held the separate content hash algorithm in version 1.0 bundles.
+	ContentHash hash.Digest `json:"content_hash" cbor:"1,keyasint"`
+	// CanonicalFormatVersion selects the canonical encoding rules the content hash
+	// was computed with; verifiers refuse versions they do not know
 	CanonicalFormatVersion string `json:"canonical_format_version" cbor:"3,keyasint"`
 	// SignerIdentityID is the identity that created the signature
 	SignerIdentityID string `json:"signer_identity_id" cbor:"4,keyasint"`
@@ -21,11 +58,205 @@ type SignatureBundle struct {
 	// TimestampToken is the RFC 3161 timestamp token
 	TimestampToken []byte `json:"timestamp_token" cbor:"7,keyasint"`
 	// LedgerEntryHash is the hash of the ledger entry
-	LedgerEntryHash []byte `json:"ledger_entry_hash" cbor:"8,keyasint"`
+	LedgerEntryHash hash.Digest `json:"ledger_entry_hash" cbor:"8,keyasint"`
 	// MerkleInclusionProof is the proof of inclusion in the ledger
 	MerkleInclusionProof *InclusionProof `json:"merkle_inclusion_proof" cbor:"9,keyasint"`
 	// BundleVersion is the version of the bundle format
 	BundleVersion string `json:"bundle_version" cbor:"10,keyasint"`
+	// SignatureAlgorithm is the signature algorithm; bundles without it use Ed25519
+	SignatureAlgorithm string `json:"signature_algorithm,omitempty" cbor:"11,keyasint,omitempty"`
+	// PostQuantumSignature is the optional post-quantum half of a hybrid signature
+	PostQuantumSignature *PostQuantumSignature `json:"post_quantum_signature,omitempty" cbor:"12,keyasint,omitempty"`
+	// SignaturePolicy is the hybrid signature policy; bundles without it use REQUIRE_CLASSICAL_ONLY
+	SignaturePolicy string `json:"signature_policy,omitempty" cbor:"13,keyasint,omitempty"`
+	// ContentType selects the content canonicalizer; bundles without it hash the content as opaque bytes
+	ContentType string `json:"content_type,omitempty" cbor:"14,keyasint,omitempty"`
+	// UnicodeNormalization is the form (NFC or NFKC) document text was normalized to;
+	// bundles without it normalize text documents to NFC and leave JSON and XML unnormalized
+	UnicodeNormalization string `json:"unicode_normalization,omitempty" cbor:"15,keyasint,omitempty"`
+
+	// legacy is set for bundles converted from the version 1.0 layout
+	legacy bool
+}
+
+// SignedMessage returns the message the bundle's signatures cover: the
+// canonical CBOR array of SigningContext, the content hash multihash, the
+// content type, the Unicode normalization and the canonical format version.
+// Version 1.1 bundles sign the multihash alone and version 1.0 bundles the raw
+// hash.
+func (b *SignatureBundle) SignedMessage() ([]byte, error) {
+	switch {
+	case b.legacy:
+		return b.ContentHash.Value, nil
+	case b.BundleVersion == MultihashVersion:
+		return b.ContentHash.Bytes(), nil
+	case b.BundleVersion == CurrentVersion:
+		return payloadEncMode.Marshal(signingPayload{
+			Context:                SigningContext,
+			ContentHash:            b.ContentHash.Bytes(),
+			ContentType:            b.ContentType,
+			UnicodeNormalization:   b.UnicodeNormalization,
+			CanonicalFormatVersion: b.CanonicalFormatVersion,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported bundle version: %q", b.BundleVersion)
+	}
+}
+
+// SignsContentRules reports whether the bundle's signatures cover its content
+// type, Unicode normalization and canonical format version. Earlier bundle
+// versions leave them unsigned.
+func (b *SignatureBundle) SignsContentRules() bool {
+	return !b.legacy && b.BundleVersion != MultihashVersion
+}
+
+// LedgerLeafData returns the data the bundle's ledger leaf commits to: the
+// multihash of the ledger entry hash, or the raw hash in version 1.0 bundles
+func (b *SignatureBundle) LedgerLeafData() []byte {
+	if b.legacy {
+		return b.LedgerEntryHash.Value
+	}
+	return b.LedgerEntryHash.Bytes()
+}
+
+// Header holds the version fields of an encoded bundle, which select how the
+// rest of it is decoded
+type Header struct {
+	// CanonicalFormatVersion is the canonical format version
+	CanonicalFormatVersion string `cbor:"3,keyasint"`
+	// BundleVersion is the bundle format version
+	BundleVersion string `cbor:"10,keyasint"`
+}
+
+// headerDecMode ignores the fields a header does not hold but refuses
+// duplicate keys, so the versions read are the ones the full decode sees
+var headerDecMode, _ = cbor.DecOptions{DupMapKey: cbor.DupMapKeyEnforcedAPF}.DecMode()
+
+// ReadHeader reads the version fields of a CBOR encoded bundle
+func ReadHeader(data []byte) (Header, error) {
+	var h Header
+	if err := headerDecMode.Unmarshal(data, &h); err != nil {
+		return Header{}, fmt.Errorf("failed to read bundle header: %w", err)
+	}
+	return h, nil
+}
+
+// Decode decodes a bundle in canonical CBOR, converting version 1.0 bundles
+// to the current layout. Bundles are decoded strictly whatever canonical
+// format version they record; the profile of that version is returned so the
+// content hash can be reproduced with the same rules.
+func Decode(data []byte) (*SignatureBundle, *canonical.Profile, error) {
+	return decode(data, false)
+}
+
+// DecodeLegacy decodes a bundle like Decode, but accepts a bundle that is not
+// canonical CBOR if its canonical format version allows legacy decoding, as
+// version 1.0 verifiers did. Verifiers use it only when asked to.
+func DecodeLegacy(data []byte) (*SignatureBundle, *canonical.Profile, error) {
+	return decode(data, true)
+}
+
+func decode(data []byte, legacyCBOR bool) (*SignatureBundle, *canonical.Profile, error) {
+	header, err := ReadHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	profile, err := canonical.LookupProfile(header.CanonicalFormatVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	decodeCBOR := canonical.Decode
+	if legacyCBOR {
+		decodeCBOR = profile.DecodeLegacy
+	}
+
+	if header.BundleVersion == LegacyVersion {
+		var legacy LegacySignatureBundle
+		if err := decodeCBOR(data, canonical.CBOR, &legacy); err != nil {
+			return nil, nil, err
+		}
+		b, err := legacy.Upgrade()
+		if err != nil {
+			return nil, nil, err
+		}
+		return b, profile, nil
+	}
+
+	var b SignatureBundle
+	if err := decodeCBOR(data, canonical.CBOR, &b); err != nil {
+		return nil, nil, err
+	}
+	return &b, profile, nil
+}
+
+// LegacySignatureBundle is the layout of version 1.0 bundles
+type LegacySignatureBundle struct {
+	// ContentHash is the raw hash of the canonical content
+	ContentHash []byte `cbor:"1,keyasint"`
+	// ContentHashAlgorithm is the content hash algorithm; SHA-256 if empty
+	ContentHashAlgorithm string `cbor:"2,keyasint"`
+	// CanonicalFormatVersion is the version of the canonicalization format
+	CanonicalFormatVersion string `cbor:"3,keyasint"`
+	// SignerIdentityID is the identity that created the signature
+	SignerIdentityID string `cbor:"4,keyasint"`
+	// KeyVersion is the version of the key used
+	KeyVersion int `cbor:"5,keyasint"`
+	// Signature is the Ed25519 signature over the raw content hash
+	Signature []byte `cbor:"6,keyasint"`
+	// TimestampToken is the RFC 3161 timestamp token
+	TimestampToken []byte `cbor:"7,keyasint"`
+	// LedgerEntryHash is the raw SHA-256 hash of the ledger entry
+	LedgerEntryHash []byte `cbor:"8,keyasint"`
+	// MerkleInclusionProof is the proof of inclusion in the ledger
+	MerkleInclusionProof *InclusionProof `cbor:"9,keyasint"`
+	// BundleVersion is the version of the bundle format, 1.0
+	BundleVersion string `cbor:"10,keyasint"`
+}
+
+// Upgrade converts a version 1.0 bundle to the current layout. The result
+// keeps the 1.0 signed message, so its signature still verifies.
+func (l *LegacySignatureBundle) Upgrade() (*SignatureBundle, error) {
+	if l.BundleVersion != LegacyVersion {
+		return nil, fmt.Errorf("not a version %s bundle: %q", LegacyVersion, l.BundleVersion)
+	}
+
+	algo := hash.SHA256
+	if l.ContentHashAlgorithm != "" {
+		algo = hash.Algorithm(l.ContentHashAlgorithm)
+	}
+	contentHash, err := hash.NewDigest(algo, l.ContentHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content hash: %w", err)
+	}
+
+	var ledgerEntryHash hash.Digest
+	if len(l.LedgerEntryHash) > 0 {
+		// Version 1.0 ledgers hashed entries with SHA-256
+		if ledgerEntryHash, err = hash.NewDigest(hash.SHA256, l.LedgerEntryHash); err != nil {
+			return nil, fmt.Errorf("invalid ledger entry hash: %w", err)
+		}
+	}
+
+	return &SignatureBundle{
+		ContentHash:            contentHash,
+		CanonicalFormatVersion: l.CanonicalFormatVersion,
+		SignerIdentityID:       l.SignerIdentityID,
+		KeyVersion:             l.KeyVersion,
+		Signature:              l.Signature,
+		TimestampToken:         l.TimestampToken,
+		LedgerEntryHash:        ledgerEntryHash,
+		MerkleInclusionProof:   l.MerkleInclusionProof,
+		BundleVersion:          l.BundleVersion,
+		legacy:                 true,
+	}, nil
+}
+
+// PostQuantumSignature is a post-quantum signature over the same content hash as Signature
+type PostQuantumSignature struct {
+	// Algorithm is the post-quantum signature algorithm, e.g. ML-DSA-65
+	Algorithm string `json:"algorithm" cbor:"1,keyasint"`
+	// Signature is the post-quantum signature
+	Signature []byte `json:"signature" cbor:"2,keyasint"`
 }
 
 // InclusionProof represents a Merkle inclusion proof
@@ -38,6 +269,8 @@ type InclusionProof struct {
 	TreeSize int `json:"tree_size" cbor:"3,keyasint"`
 	// Path is the hash path from leaf to root
 	Path [][]byte `json:"path" cbor:"4,keyasint"`
+	// TreeHashScheme is the Merkle tree hashing scheme (0 for legacy unprefixed trees)
+	TreeHashScheme int `json:"tree_hash_scheme,omitempty" cbor:"5,keyasint,omitempty"`
 }
 
 // Metadata contains non-cryptographic metadata about the signature
@@ -0,0 +1,275 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/keyfile"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+	"github.com/IAmSoThirsty/civic-attest/internal/signer/backend"
+)
+
+// testSign signs a digest and checks the signature against the signer's public key
+func testSign(t *testing.T, signer signatures.Signer) {
+	t.Helper()
+
+	digest := []byte("content hash")
+	sig, err := signer.Sign(context.Background(), digest)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	valid, err := signatures.Verify(signer.PublicKey(), digest, sig, signer.Algorithm())
+	if err != nil {
+		t.Fatalf("Failed to verify: %v", err)
+	}
+	if !valid {
+		t.Error("Signature does not verify under the signer's public key")
+	}
+}
+
+func TestFileBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "office.key")
+
+	created, err := backend.CreateFile(path, signatures.Ed448, "correct horse")
+	if err != nil {
+		t.Fatalf("Failed to create key file: %v", err)
+	}
+	testSign(t, created)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat key file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected key file mode 0600, got %o", info.Mode().Perm())
+	}
+
+	opened, err := backend.OpenFile(path, "correct horse")
+	if err != nil {
+		t.Fatalf("Failed to open key file: %v", err)
+	}
+	if opened.Algorithm() != signatures.Ed448 || !bytes.Equal(opened.PublicKey(), created.PublicKey()) {
+		t.Error("Opened key does not match created key")
+	}
+	testSign(t, opened)
+
+	if _, err := backend.OpenFile(path, "wrong passphrase"); err == nil {
+		t.Error("Expected error for wrong passphrase")
+	}
+	if _, err := backend.OpenFile(path, ""); err == nil {
+		t.Error("Expected error for empty passphrase")
+	}
+	if _, err := backend.CreateFile(path, signatures.Ed25519, "correct horse"); err == nil {
+		t.Error("Expected error when overwriting an existing key file")
+	}
+}
+
+func TestFileBackendTampering(t *testing.T) {
+	kp, err := signatures.GenerateKeyPair(signatures.Ed25519)
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	data, err := backend.EncryptKey(kp.PrivateKey, signatures.Ed25519, "passphrase")
+	if err != nil {
+		t.Fatalf("Failed to encrypt key: %v", err)
+	}
+
+	// The algorithm is authenticated, so relabelling the key must fail
+	relabelled := bytes.Replace(data, []byte(`"Ed25519"`), []byte(`"Ed448"`), 1)
+	if _, _, err := backend.DecryptKey(relabelled, "passphrase"); err == nil {
+		t.Error("Expected error for relabelled key file")
+	}
+
+	// Absurd scrypt costs are refused before any work is done
+	expensive := bytes.Replace(data, []byte(`"n": 32768`), []byte(`"n": 1073741824`), 1)
+	if _, _, err := backend.DecryptKey(expensive, "passphrase"); err == nil || !strings.Contains(err.Error(), "scrypt") {
+		t.Errorf("Expected scrypt parameter error, got %v", err)
+	}
+
+	privateKey, algo, err := backend.DecryptKey(data, "passphrase")
+	if err != nil {
+		t.Fatalf("Failed to decrypt key: %v", err)
+	}
+	if algo != signatures.Ed25519 || !bytes.Equal(privateKey, kp.PrivateKey) {
+		t.Error("Decrypted key does not match")
+	}
+}
+
+func TestKMSBackend(t *testing.T) {
+	server := httptest.NewServer(backend.NewKMSServer("secret"))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	for _, algo := range []signatures.Algorithm{signatures.Ed25519, signatures.MLDSA65} {
+		created, err := backend.CreateKMSKey(ctx, server.URL, "secret", algo)
+		if err != nil {
+			t.Fatalf("Failed to create %s KMS key: %v", algo, err)
+		}
+		if created.Algorithm() != algo {
+			t.Errorf("Expected %s key, got %s", algo, created.Algorithm())
+		}
+		testSign(t, created)
+
+		opened, err := backend.OpenKMS(ctx, created.KeyURL(), "secret")
+		if err != nil {
+			t.Fatalf("Failed to open KMS key: %v", err)
+		}
+		if !bytes.Equal(opened.PublicKey(), created.PublicKey()) {
+			t.Error("Opened KMS key does not match created key")
+		}
+		testSign(t, opened)
+	}
+
+	if _, err := backend.CreateKMSKey(ctx, server.URL, "wrong", signatures.Ed25519); err == nil {
+		t.Error("Expected error for wrong bearer token")
+	}
+	if _, err := backend.OpenKMS(ctx, server.URL+"/v1/keys/missing", "secret"); err == nil {
+		t.Error("Expected error for unknown key")
+	}
+	if _, err := backend.CreateKMSKey(ctx, server.URL, "secret", signatures.Algorithm("RSA")); err == nil {
+		t.Error("Expected error for unsupported algorithm")
+	}
+}
+
+func TestKMSBackendRejectsForeignSignature(t *testing.T) {
+	server := httptest.NewServer(backend.NewKMSServer(""))
+	defer server.Close()
+
+	ctx := context.Background()
+	a, err := backend.CreateKMSKey(ctx, server.URL, "", signatures.Ed25519)
+	if err != nil {
+		t.Fatalf("Failed to create KMS key: %v", err)
+	}
+	b, err := backend.CreateKMSKey(ctx, server.URL, "", signatures.Ed25519)
+	if err != nil {
+		t.Fatalf("Failed to create KMS key: %v", err)
+	}
+
+	// A KMS that signs with key b when asked for key a must be caught
+	pathA := strings.TrimPrefix(a.KeyURL(), server.URL)
+	pathB := strings.TrimPrefix(b.KeyURL(), server.URL)
+	target, _ := url.Parse(server.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	misdirecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == pathA+"/sign" {
+			r.URL.Path = pathB + "/sign"
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	defer misdirecting.Close()
+
+	opened, err := backend.OpenKMS(ctx, misdirecting.URL+pathA, "")
+	if err != nil {
+		t.Fatalf("Failed to open KMS key: %v", err)
+	}
+	if _, err := opened.Sign(ctx, []byte("digest")); err == nil {
+		t.Error("Expected error for signature by a different key")
+	}
+}
+
+func TestOpenAndGenerateByURI(t *testing.T) {
+	server := httptest.NewServer(backend.NewKMSServer("token"))
+	defer server.Close()
+
+	t.Setenv(backend.PassphraseEnv, "passphrase")
+	t.Setenv(backend.KMSTokenEnv, "token")
+	ctx := context.Background()
+
+	for _, base := range []string{"file:" + filepath.Join(t.TempDir(), "k.json"), "kms:" + server.URL} {
+		generated, uri, err := backend.Generate(ctx, base, signatures.Ed25519)
+		if err != nil {
+			t.Fatalf("Failed to generate key at %s: %v", base, err)
+		}
+		if !backend.IsURI(uri) {
+			t.Errorf("Generate returned non-URI %q", uri)
+		}
+
+		opened, err := backend.Open(ctx, uri)
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", uri, err)
+		}
+		if !bytes.Equal(opened.PublicKey(), generated.PublicKey()) {
+			t.Errorf("Key opened from %s does not match generated key", uri)
+		}
+	}
+
+	if backend.IsURI("/etc/keys/office.pem") {
+		t.Error("Plain path reported as URI")
+	}
+	if _, err := backend.Open(ctx, "vault:secret/key"); err == nil {
+		t.Error("Expected error for unsupported scheme")
+	}
+}
+
+func TestImport(t *testing.T) {
+	t.Setenv(backend.PassphraseEnv, "passphrase")
+
+	kp, err := signatures.GenerateKeyPair(signatures.Ed25519)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	key := &keyfile.Key{PrivateKey: kp.PrivateKey, Algorithm: kp.Algorithm, KeyVersion: 3, IdentityID: "mayor-springfield-v3"}
+	uri := "file:" + filepath.Join(t.TempDir(), "restored.key")
+	if _, err := backend.Import(uri, key); err != nil {
+		t.Fatalf("Failed to import key: %v", err)
+	}
+	opened, err := backend.Open(context.Background(), uri)
+	if err != nil {
+		t.Fatalf("Failed to open imported key: %v", err)
+	}
+	if !bytes.Equal(opened.PublicKey(), kp.PublicKey) {
+		t.Error("Imported key does not match")
+	}
+	if id, ok := opened.(backend.Identified); !ok || id.IdentityID() != key.IdentityID || id.KeyVersion() != 3 {
+		t.Error("Imported key lost its identity and key version")
+	}
+
+	if _, err := backend.Import(uri, key); err == nil {
+		t.Error("Expected error importing over an existing key file")
+	}
+	if _, err := backend.Import("kms:https://kms.example.gov", key); err == nil {
+		t.Error("Expected error importing into a KMS")
+	}
+}
+
+func TestParsePKCS11URI(t *testing.T) {
+	t.Setenv(backend.PINEnv, "1234")
+
+	cfg, err := backend.ParsePKCS11URI("pkcs11:token=civic%20test;object=office-key?module-path=/usr/lib/softhsm/libsofthsm2.so")
+	if err != nil {
+		t.Fatalf("Failed to parse URI: %v", err)
+	}
+	if cfg.Token != "civic test" || cfg.Object != "office-key" || cfg.Module != "/usr/lib/softhsm/libsofthsm2.so" || cfg.PIN != "1234" {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+
+	cfg, err = backend.ParsePKCS11URI("pkcs11:token=t;object=o?module-path=m.so&pin-value=9999")
+	if err != nil {
+		t.Fatalf("Failed to parse URI: %v", err)
+	}
+	if cfg.PIN != "9999" {
+		t.Errorf("Expected PIN from URI, got %q", cfg.PIN)
+	}
+
+	for _, uri := range []string{
+		"pkcs11:token=t;object=o",
+		"pkcs11:object=o?module-path=m.so",
+		"pkcs11:token=t;object?module-path=m.so",
+		"pkcs11:token=%zz;object=o?module-path=m.so",
+	} {
+		if _, err := backend.ParsePKCS11URI(uri); err == nil {
+			t.Errorf("Expected error for %q", uri)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/canonical"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/merkle"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+	"github.com/IAmSoThirsty/civic-attest/internal/ledger/tree"
+	"github.com/IAmSoThirsty/civic-attest/internal/signer/bundle"
+)
+
+// A version 1.0 bundle written by the original signer for legacyBundleContent,
+// with the Ed25519 key of seed 00 01 .. 1f
+const (
+	legacyBundleHex = "aa015820622eae330f6672a3e8fabc667ec94e1e179ea803d9896d63f6090914e07d3b8202675348412d32353603" +
+		"63312e3004746d61796f722d737072696e676669656c642d763105010658401ac52d3cc6ae3138e3a7b442c280924ac7" +
+		"3592eb516946b1dbfe226e41cfb5310248de34ddb150930d40669108fefe6575148bb7368670015c99c469178e1e0107" +
+		"584e304c020101170d3236313031373030333733315a0420622eae330f6672a3e8fabc667ec94e1e179ea803d9896d63" +
+		"f6090914e07d3b82060960864801650304020102010113086d6f636b2d747361085820807116ff6fa3952a08290335b6" +
+		"8ba5927dabe20d76eb6a20eef4dc7c407857f909a40100025820e86bcd5d1c054236fc584ca3090ec27a29b4edd4008c" +
+		"1e7303f740713912a4f1030104800a63312e30"
+	legacyBundleContent   = "Springfield city council minutes, 2026-01-12\n"
+	legacyBundlePublicKey = "03a107bff3ce10be1d70dd18e74bc09967e4d6309ba50d5f1ddc8664125531b8"
+)
+
+func TestLegacyBundle(t *testing.T) {
+	data := mustHex(t, legacyBundleHex)
+
+	header, err := bundle.ReadHeader(data)
+	if err != nil {
+		t.Fatalf("ReadHeader failed: %v", err)
+	}
+	if header.BundleVersion != bundle.LegacyVersion || header.CanonicalFormatVersion != canonical.Version1 {
+		t.Fatalf("Unexpected header: %+v", header)
+	}
+
+	var legacy bundle.LegacySignatureBundle
+	if err := canonical.Decode(data, canonical.CBOR, &legacy); err != nil {
+		t.Fatalf("Failed to decode legacy bundle: %v", err)
+	}
+	sigBundle, err := legacy.Upgrade()
+	if err != nil {
+		t.Fatalf("Upgrade failed: %v", err)
+	}
+	decoded, profile, err := bundle.Decode(data)
+	if err != nil || profile.Version != canonical.Version1 || !decoded.ContentHash.Equal(sigBundle.ContentHash) {
+		t.Fatalf("Decode failed: %v (%+v)", err, decoded)
+	}
+
+	// The content hash is the hash of the opaque content
+	var buf bytes.Buffer
+	content := []byte(legacyBundleContent)
+	if err := canonical.EncodeStream(&buf, bytes.NewReader(content), int64(len(content)), canonical.CBOR); err != nil {
+		t.Fatalf("EncodeStream failed: %v", err)
+	}
+	expected, err := hash.Sum(buf.Bytes(), hash.SHA256)
+	if err != nil {
+		t.Fatalf("Sum failed: %v", err)
+	}
+	if !sigBundle.ContentHash.Equal(expected) {
+		t.Errorf("Unexpected content hash: %s", sigBundle.ContentHash)
+	}
+
+	// The signature covers the raw hash
+	publicKey := mustHex(t, legacyBundlePublicKey)
+	message, err := sigBundle.SignedMessage()
+	if err != nil {
+		t.Fatalf("SignedMessage failed: %v", err)
+	}
+	valid, err := signatures.Verify(publicKey, message, sigBundle.Signature, signatures.Ed25519)
+	if err != nil || !valid {
+		t.Errorf("Legacy signature does not verify (err=%v)", err)
+	}
+	if bytes.Equal(message, sigBundle.ContentHash.Bytes()) {
+		t.Error("Legacy bundles must not sign the multihash")
+	}
+	if sigBundle.SignsContentRules() {
+		t.Error("Legacy bundles do not sign their content rules")
+	}
+
+	// The inclusion proof commits to the raw entry hash under the legacy tree scheme
+	proof := sigBundle.MerkleInclusionProof
+	hasher, err := merkle.NewHasher(hash.SHA256, merkle.HashScheme(proof.TreeHashScheme))
+	if err != nil {
+		t.Fatalf("NewHasher failed: %v", err)
+	}
+	leafHash, err := hasher.HashLeaf(sigBundle.LedgerLeafData())
+	if err != nil || !bytes.Equal(leafHash, proof.LeafHash) {
+		t.Errorf("Leaf hash does not match the proof (err=%v)", err)
+	}
+
+	// Current bundles are not legacy bundles
+	legacy.BundleVersion = bundle.CurrentVersion
+	if _, err := legacy.Upgrade(); err == nil {
+		t.Error("Expected Upgrade to refuse a version 1.1 bundle")
+	}
+}
+
+// A bundle written before canonical formats were versioned: canonical format
+// 1.0 naming a content type and normalization, bundle version 1.1, signed for
+// preMigrationBundleContent with the key of legacyBundlePublicKey
+const (
+	preMigrationBundleHex = "ac01582212201b59995559db866b7e683fe9dc67576632a481d9f44ee5508f6817890c8b1ce40363312e300474636c65" +
+		"726b2d737072696e676669656c642d76310501065840840a9d49c3d83fd301210e8fe1d72e40a807f375ccf49cd079d8" +
+		"6369355d49b90e35899fb00abd0683bc53b664b8882f084bd930545a5bb749f04d011628270607584e304c020101170d" +
+		"3236313031373030353630395a04201b59995559db866b7e683fe9dc67576632a481d9f44ee5508f6817890c8b1ce406" +
+		"0960864801650304020102010113086d6f636b2d74736108582212208e1701dbcd91f08e757ef3ea17587e09fa7d40f6" +
+		"cd5d46e3bc725e00d8af5c2909a50100025820760704b3affd3042e63b648c976735740295cf57274efb88b70566ad4d" +
+		"7cfd000301048005010a63312e310b67456432353531390e6a746578742f706c61696e0f634e4643"
+	preMigrationBundleContent = "Agenda: Cafe\u0301 permits\r\nItem 2: zoning  \r\n"
+)
+
+func TestPreMigrationBundle(t *testing.T) {
+	sigBundle, profile, err := bundle.Decode(mustHex(t, preMigrationBundleHex))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if profile.Version != canonical.Version1 || sigBundle.ContentType != canonical.ContentTypeText || sigBundle.UnicodeNormalization != string(canonical.NFC) {
+		t.Fatalf("Unexpected bundle: profile %s, content type %q, normalization %q", profile.Version, sigBundle.ContentType, sigBundle.UnicodeNormalization)
+	}
+
+	// The content hash is reproduced with the text canonicalizer under 1.0
+	var buf bytes.Buffer
+	content := []byte(preMigrationBundleContent)
+	if err := profile.EncodeContent(&buf, bytes.NewReader(content), int64(len(content)), sigBundle.ContentType, canonical.NFC, canonical.CBOR); err != nil {
+		t.Fatalf("EncodeContent failed: %v", err)
+	}
+	expected, err := hash.Sum(buf.Bytes(), sigBundle.ContentHash.Algorithm)
+	if err != nil {
+		t.Fatalf("Sum failed: %v", err)
+	}
+	if !sigBundle.ContentHash.Equal(expected) {
+		t.Errorf("Unexpected content hash: %s", sigBundle.ContentHash)
+	}
+
+	// The signature covers the multihash alone
+	message, err := sigBundle.SignedMessage()
+	if err != nil {
+		t.Fatalf("SignedMessage failed: %v", err)
+	}
+	valid, err := signatures.Verify(mustHex(t, legacyBundlePublicKey), message, sigBundle.Signature, signatures.Ed25519)
+	if err != nil || !valid {
+		t.Errorf("Pre-migration signature does not verify (err=%v)", err)
+	}
+	if sigBundle.SignsContentRules() {
+		t.Error("Version 1.1 bundles do not sign their content rules")
+	}
+}
+
+func TestDecodeLegacyCBOR(t *testing.T) {
+	// Key version 1 written as a non-minimal one-byte integer
+	legacy := bytes.Replace(mustHex(t, legacyBundleHex), []byte("-v1\x05\x01"), []byte("-v1\x05\x18\x01"), 1)
+	if _, _, err := bundle.Decode(legacy); err == nil {
+		t.Error("Expected Decode to refuse a non-canonical 1.0 bundle")
+	}
+	decoded, profile, err := bundle.DecodeLegacy(legacy)
+	if err != nil || profile.Version != canonical.Version1 || decoded.KeyVersion != 1 {
+		t.Errorf("DecodeLegacy failed: %v (%+v)", err, decoded)
+	}
+
+	// Bundles of later canonical formats are never decoded leniently
+	contentHash, err := hash.Sum([]byte("content"), hash.SHA256)
+	if err != nil {
+		t.Fatalf("Sum failed: %v", err)
+	}
+	data, err := canonical.Encode(&bundle.SignatureBundle{
+		ContentHash:            contentHash,
+		CanonicalFormatVersion: canonical.Version2,
+		SignerIdentityID:       "clerk-v1",
+		KeyVersion:             1,
+		Signature:              make([]byte, 64),
+		BundleVersion:          bundle.CurrentVersion,
+	}, canonical.CBOR)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if _, _, err := bundle.Decode(data); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	current := bytes.Replace(data, []byte("-v1\x05\x01"), []byte("-v1\x05\x18\x01"), 1)
+	if bytes.Equal(current, data) {
+		t.Fatal("Key version not found in encoded bundle")
+	}
+	if _, _, err := bundle.DecodeLegacy(current); err == nil {
+		t.Error("Expected DecodeLegacy to refuse a non-canonical 2.0 bundle")
+	}
+}
+
+func TestLedgerEntryHashUnchanged(t *testing.T) {
+	// The entry of the legacy bundle hashes as it did when the bundle was written
+	signatureHash, err := hash.NewDigest(hash.SHA256, mustHex(t, "622eae330f6672a3e8fabc667ec94e1e179ea803d9896d63f6090914e07d3b82"))
+	if err != nil {
+		t.Fatalf("NewDigest failed: %v", err)
+	}
+	entry := &tree.Entry{
+		SignerIdentityID: "mayor-springfield-v1",
+		SignatureHash:    signatureHash,
+		EntryType:        "signature",
+		Timestamp:        time.Date(2026, 10, 17, 0, 37, 31, 0, time.UTC),
+	}
+
+	ledger := tree.NewLedgerTree(hash.SHA256)
+	if err := ledger.Append(entry); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if expected := "807116ff6fa3952a08290335b68ba5927dabe20d76eb6a20eef4dc7c407857f9"; hex.EncodeToString(entry.EntryHash.Value) != expected {
+		t.Errorf("Entry hash changed: %x", entry.EntryHash.Value)
+	}
+}
+
+func TestSignedMessageBindsContentRules(t *testing.T) {
+	contentHash, err := hash.Sum([]byte("ordinance 2026-14"), hash.SHA256)
+	if err != nil {
+		t.Fatalf("Sum failed: %v", err)
+	}
+	signed := bundle.SignatureBundle{
+		ContentHash:            contentHash,
+		CanonicalFormatVersion: canonical.Version2,
+		BundleVersion:          bundle.CurrentVersion,
+		ContentType:            canonical.ContentTypeText,
+		UnicodeNormalization:   string(canonical.NFC),
+	}
+	message, err := signed.SignedMessage()
+	if err != nil {
+		t.Fatalf("SignedMessage failed: %v", err)
+	}
+	if !signed.SignsContentRules() {
+		t.Error("Current bundles sign their content rules")
+	}
+
+	// Changing any field that affects how the content hash is reproduced
+	// changes the signed message
+	for name, tamper := range map[string]func(b *bundle.SignatureBundle){
+		"content type":     func(b *bundle.SignatureBundle) { b.ContentType = canonical.ContentTypeOpaque },
+		"normalization":    func(b *bundle.SignatureBundle) { b.UnicodeNormalization = string(canonical.NFKC) },
+		"canonical format": func(b *bundle.SignatureBundle) { b.CanonicalFormatVersion = canonical.Version1 },
+		"content hash":     func(b *bundle.SignatureBundle) { b.ContentHash.Value = make([]byte, len(b.ContentHash.Value)) },
+	} {
+		tampered := signed
+		tamper(&tampered)
+		other, err := tampered.SignedMessage()
+		if err != nil {
+			t.Fatalf("%s: SignedMessage failed: %v", name, err)
+		}
+		if bytes.Equal(other, message) {
+			t.Errorf("Signed message does not cover the %s", name)
+		}
+	}
+
+	// The message is domain separated from a bare multihash signature
+	if !bytes.Contains(message, []byte(bundle.SigningContext)) || !bytes.Contains(message, contentHash.Bytes()) {
+		t.Error("Signed message does not carry the signing context and content hash multihash")
+	}
+
+	previous := signed
+	previous.BundleVersion = bundle.MultihashVersion
+	if message, err := previous.SignedMessage(); err != nil || !bytes.Equal(message, contentHash.Bytes()) {
+		t.Errorf("Version 1.1 bundles sign the multihash alone (err=%v)", err)
+	}
+	if previous.SignsContentRules() {
+		t.Error("Version 1.1 bundles do not sign their content rules")
+	}
+
+	unknown := signed
+	unknown.BundleVersion = "9.0"
+	if _, err := unknown.SignedMessage(); err == nil {
+		t.Error("Expected error for unknown bundle version")
+	}
+}
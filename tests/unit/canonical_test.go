@@ -0,0 +1,1117 @@
+package unit
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/canonical"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
+)
+
+func TestEncodeStreamMatchesEncode(t *testing.T) {
+	sizes := []int{0, 1, 23, 24, 255, 256, 65535, 65536, 100000}
+
+	for _, format := range []canonical.Format{canonical.CBOR, canonical.JSON} {
+		for _, size := range sizes {
+			content := make([]byte, size)
+			if _, err := rand.Read(content); err != nil {
+				t.Fatalf("Failed to generate content: %v", err)
+			}
+
+			expected, err := canonical.Encode(content, format)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := canonical.EncodeStream(&buf, bytes.NewReader(content), int64(size), format); err != nil {
+				t.Fatalf("%s size %d: EncodeStream failed: %v", format, size, err)
+			}
+			if !bytes.Equal(buf.Bytes(), expected) {
+				t.Errorf("%s size %d: streamed encoding differs from Encode", format, size)
+			}
+		}
+	}
+}
+
+func TestEncodeStreamRejectsSizeMismatch(t *testing.T) {
+	content := []byte("hearing recording")
+
+	var buf bytes.Buffer
+	if err := canonical.EncodeStream(&buf, bytes.NewReader(content), int64(len(content))+1, canonical.CBOR); err == nil {
+		t.Error("Expected error for content shorter than size")
+	}
+	if err := canonical.EncodeStream(&buf, bytes.NewReader(content), int64(len(content))-1, canonical.CBOR); err == nil {
+		t.Error("Expected error for content longer than size")
+	}
+}
+
+func TestContentRegistryLookup(t *testing.T) {
+	tests := []struct {
+		contentType string
+		expected    string
+	}{
+		{"", "opaque"},
+		{"application/octet-stream", "opaque"},
+		{"application/json", "RFC 8785"},
+		{"Application/JSON; charset=utf-8", "RFC 8785"},
+		{"application/ld+json", "RFC 8785"},
+		{"text/plain", "text"},
+		{"text/markdown", "text"},
+		{"application/xml", "exc-c14n"},
+		{"image/svg+xml", "exc-c14n"},
+	}
+
+	for _, tt := range tests {
+		c, err := canonical.DefaultContentRegistry.Lookup(tt.contentType)
+		if err != nil {
+			t.Errorf("%q: Lookup failed: %v", tt.contentType, err)
+			continue
+		}
+		if c.Name() != tt.expected {
+			t.Errorf("%q: expected %s canonicalizer, got %s", tt.contentType, tt.expected, c.Name())
+		}
+	}
+
+	for _, contentType := range []string{"video/mp4", "text/plain; charset=latin1", "not a type"} {
+		if _, err := canonical.DefaultContentRegistry.Lookup(contentType); err == nil {
+			t.Errorf("%q: expected lookup to fail", contentType)
+		}
+	}
+
+	if err := canonical.DefaultContentRegistry.Register("application/json", canonical.JCS{}); err == nil {
+		t.Error("Expected error registering a content type twice")
+	}
+}
+
+func TestDetectContentType(t *testing.T) {
+	tests := map[string]string{
+		"minutes.json":   canonical.ContentTypeJSON,
+		"ORDER.XML":      canonical.ContentTypeXML,
+		"statement.txt":  canonical.ContentTypeText,
+		"hearing.mp4":    canonical.ContentTypeOpaque,
+		"no-extension":   canonical.ContentTypeOpaque,
+		"budget.2025.md": "text/markdown",
+	}
+	for filename, expected := range tests {
+		if got := canonical.DetectContentType(filename); got != expected {
+			t.Errorf("%s: expected %s, got %s", filename, expected, got)
+		}
+	}
+}
+
+func TestEncodeContentOpaqueMatchesEncodeStream(t *testing.T) {
+	content := []byte("{\"b\": 1, \"a\": 2}\r\n")
+
+	var expected, buf bytes.Buffer
+	if err := canonical.EncodeStream(&expected, bytes.NewReader(content), int64(len(content)), canonical.CBOR); err != nil {
+		t.Fatalf("EncodeStream failed: %v", err)
+	}
+	for _, contentType := range []string{"", canonical.ContentTypeOpaque} {
+		buf.Reset()
+		if err := canonical.EncodeContent(&buf, bytes.NewReader(content), int64(len(content)), contentType, "", canonical.CBOR); err != nil {
+			t.Fatalf("EncodeContent failed: %v", err)
+		}
+		if !bytes.Equal(buf.Bytes(), expected.Bytes()) {
+			t.Errorf("%q: opaque encoding differs from EncodeStream", contentType)
+		}
+	}
+}
+
+// assertSameCanonicalForm checks that every document canonicalizes to expected
+func assertSameCanonicalForm(t *testing.T, c canonical.Canonicalizer, expected string, docs ...string) {
+	t.Helper()
+	for _, doc := range docs {
+		got, err := c.Canonicalize([]byte(doc))
+		if err != nil {
+			t.Errorf("%s: Canonicalize(%q) failed: %v", c.Name(), doc, err)
+			continue
+		}
+		if string(got) != expected {
+			t.Errorf("%s: Canonicalize(%q)\n got: %q\nwant: %q", c.Name(), doc, got, expected)
+		}
+	}
+}
+
+func TestJCSCanonicalize(t *testing.T) {
+	assertSameCanonicalForm(t, canonical.JCS{},
+		`{"agenda":["budget","zoning"],"meeting":{"date":"2025-03-04","quorum":true},"motion":null}`,
+		`{"motion":null,"meeting":{"quorum":true,"date":"2025-03-04"},"agenda":["budget","zoning"]}`,
+		"\ufeff{\n  \"meeting\" : { \"date\": \"2025-03-04\", \"quorum\": true },\r\n  \"motion\": null,\n  \"agenda\": [ \"budget\", \"zoning\" ]\n}\n",
+	)
+
+	// Numbers and strings from RFC 8785 section 3.2.2
+	assertSameCanonicalForm(t, canonical.JCS{},
+		`{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		`{"numbers":[333333333.33333329,1E30,4.50,2e-3,0.000000000000000000000000001],"string":"\u20ac$\u000F\u000aA'B\u0022\u005c\u005c\u0022\u002f","literals":[null,true,false]}`,
+	)
+
+	// Member names sort by UTF-16 code units, so U+1F600 sorts before U+FB33
+	assertSameCanonicalForm(t, canonical.JCS{},
+		"{\"\\r\":0,\"1\":0,\"a\":0,\"\u00f6\":0,\"\U0001F600\":0,\"\uFB33\":0}",
+		`{"\ufb33":0,"\ud83d\ude00":0,"\u00f6":0,"a":0,"1":0,"\r":0}`,
+	)
+
+	for _, doc := range []string{
+		`{"a":1,"a":2}`,
+		`{"a":1e400}`,
+		`"\ud800"`,
+		`[1,]`,
+		`{"a":01}`,
+		`{"a":1} {}`,
+		"\"\xff\"",
+	} {
+		if _, err := (canonical.JCS{}).Canonicalize([]byte(doc)); err == nil {
+			t.Errorf("Expected %q to be refused", doc)
+		}
+	}
+}
+
+func TestTextCanonicalize(t *testing.T) {
+	// "Café" with a precomposed and a decomposed é, and three line ending styles
+	assertSameCanonicalForm(t, canonical.Text{},
+		"Caf\u00e9 minutes\nApproved\n",
+		"Cafe\u0301 minutes\r\nApproved\r\n",
+		"Café minutes\rApproved",
+		"\ufeffCafe\u0301 minutes\nApproved",
+	)
+
+	if _, err := (canonical.Text{}).Canonicalize([]byte("bad \xff byte")); err == nil {
+		t.Error("Expected invalid UTF-8 to be refused")
+	}
+}
+
+func TestExcC14NCanonicalize(t *testing.T) {
+	expected := `<r:order xmlns:r="urn:civic:records" date="2025-03-04" id="17"><r:item>Zoning &amp; permits</r:item><note xml:lang="en">a &lt; b</note><empty></empty></r:order>`
+
+	assertSameCanonicalForm(t, canonical.ExcC14N{}, expected,
+		expected,
+		`<?xml version="1.0" encoding="UTF-8"?>
+<!-- exported by records system -->
+<r:order id='17' date="2025-03-04" xmlns:r="urn:civic:records" xmlns:unused="urn:unused"><r:item>Zoning &amp; permits</r:item><note xml:lang="en">a &lt; b</note><empty/></r:order>
+`,
+		`<r:order xmlns:r="urn:civic:records" id="17"  date="2025-03-04"
+><r:item><![CDATA[Zoning & permits]]></r:item><note xml:lang='en'>a &#60; b</note><empty /></r:order>`,
+	)
+
+	// Declarations move to where they are visibly used and are not repeated
+	assertSameCanonicalForm(t, canonical.ExcC14N{},
+		`<a xmlns="urn:a"><b:x xmlns:b="urn:b" j="2" b:k="1"><y></y><b:z></b:z></b:x></a>`,
+		`<a xmlns="urn:a"><b:x xmlns:b="urn:b" b:k="1" j="2"><y xmlns="urn:a"></y><b:z></b:z></b:x></a>`,
+		`<a xmlns="urn:a" xmlns:b="urn:b"><b:x j="2" b:k="1"><y></y><b:z></b:z></b:x></a>`,
+	)
+
+	// Attributes sort by namespace URI before local name
+	assertSameCanonicalForm(t, canonical.ExcC14N{},
+		`<e xmlns:p="urn:z" xmlns:q="urn:a" b="1" q:c="2" p:a="3"></e>`,
+		`<e xmlns:p="urn:z" xmlns:q="urn:a" p:a="3" b="1" q:c="2"></e>`,
+	)
+
+	for _, doc := range []string{
+		`<a><b></a>`,
+		`<a></a><b></b>`,
+		`<p:a></p:a>`,
+		`text<a></a>`,
+		``,
+	} {
+		if _, err := (canonical.ExcC14N{}).Canonicalize([]byte(doc)); err == nil {
+			t.Errorf("Expected %q to be refused", doc)
+		}
+	}
+}
+
+func TestEncodeContentCanonicalizesDocuments(t *testing.T) {
+	a := []byte(`{"title":"Minutes","approved":true}`)
+	b := []byte("{\n  \"approved\": true,\n  \"title\": \"Minutes\"\n}\n")
+
+	var bufA, bufB bytes.Buffer
+	if err := canonical.EncodeContent(&bufA, bytes.NewReader(a), int64(len(a)), canonical.ContentTypeJSON, "", canonical.CBOR); err != nil {
+		t.Fatalf("EncodeContent failed: %v", err)
+	}
+	if err := canonical.EncodeContent(&bufB, bytes.NewReader(b), int64(len(b)), canonical.ContentTypeJSON, "", canonical.CBOR); err != nil {
+		t.Fatalf("EncodeContent failed: %v", err)
+	}
+	if !bytes.Equal(bufA.Bytes(), bufB.Bytes()) {
+		t.Error("Reformatted JSON document encodes differently")
+	}
+
+	expected, err := canonical.Encode([]byte(`{"approved":true,"title":"Minutes"}`), canonical.CBOR)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !bytes.Equal(bufA.Bytes(), expected) {
+		t.Error("Canonical document is not wrapped as a byte string")
+	}
+
+	if err := canonical.EncodeContent(&bufA, bytes.NewReader(a), int64(len(a))+1, canonical.ContentTypeJSON, "", canonical.CBOR); err == nil {
+		t.Error("Expected error for content shorter than size")
+	}
+}
+
+func TestParseNormalization(t *testing.T) {
+	for _, s := range []string{"", "NFC", "NFKC"} {
+		if n, err := canonical.ParseNormalization(s); err != nil || string(n) != s {
+			t.Errorf("%q: ParseNormalization returned %q, %v", s, n, err)
+		}
+	}
+	for _, s := range []string{"NFD", "NFKD", "nfc"} {
+		if _, err := canonical.ParseNormalization(s); err == nil {
+			t.Errorf("%q: expected unsupported normalization", s)
+		}
+	}
+}
+
+func TestLookupNormalized(t *testing.T) {
+	for contentType, expected := range map[string]canonical.Canonicalizer{
+		canonical.ContentTypeJSON: canonical.JCS{Form: canonical.NFKC},
+		canonical.ContentTypeText: canonical.Text{Form: canonical.NFKC},
+		canonical.ContentTypeXML:  canonical.ExcC14N{Form: canonical.NFKC},
+	} {
+		c, err := canonical.DefaultContentRegistry.LookupNormalized(contentType, canonical.NFKC)
+		if err != nil {
+			t.Errorf("%s: LookupNormalized failed: %v", contentType, err)
+			continue
+		}
+		if c != expected {
+			t.Errorf("%s: expected %#v, got %#v", contentType, expected, c)
+		}
+	}
+
+	if _, err := canonical.DefaultContentRegistry.LookupNormalized(canonical.ContentTypeOpaque, canonical.NFC); err == nil {
+		t.Error("Expected opaque content to refuse normalization")
+	}
+	if c, err := canonical.DefaultContentRegistry.LookupNormalized(canonical.ContentTypeOpaque, ""); err != nil || c != (canonical.Opaque{}) {
+		t.Errorf("Expected opaque canonicalizer without normalization, got %v (err=%v)", c, err)
+	}
+}
+
+func TestJCSNormalization(t *testing.T) {
+	// Decomposed and precomposed "\u00e9" in a member name and a string, one escaped
+	nfd := "{\"cafe\u0301\":\"r\\u00e9sume\u0301\"}"
+	nfc := "{\"caf\u00e9\":\"r\u00e9sum\u00e9\"}"
+
+	assertSameCanonicalForm(t, canonical.JCS{Form: canonical.NFC}, nfc, nfd, nfc)
+	if got, _ := (canonical.JCS{}).Canonicalize([]byte(nfd)); string(got) == nfc {
+		t.Error("RFC 8785 without a form should leave strings unnormalized")
+	}
+
+	// NFKC folds the "fi" ligature and full-width digits
+	assertSameCanonicalForm(t, canonical.JCS{Form: canonical.NFKC}, `{"file":"123"}`, "{\"\ufb01le\":\"\uff11\uff12\uff13\"}")
+
+	// Names that differ only before normalization are duplicates after it
+	if _, err := (canonical.JCS{Form: canonical.NFC}).Canonicalize([]byte(fmt.Sprintf("{%q:1,%q:2}", "caf\u00e9", "cafe\u0301"))); err == nil {
+		t.Error("Expected names equal after normalization to be refused as duplicates")
+	}
+}
+
+func TestTextNormalization(t *testing.T) {
+	// A press release with a ligature and a non-breaking space, as saved by two editors
+	assertSameCanonicalForm(t, canonical.Text{Form: canonical.NFKC},
+		"Office of the Mayor: final budget\n",
+		"Office of the Mayor: \ufb01nal budget\r\n",
+		"Office of the Mayor:\u00a0final budget\n",
+	)
+
+	// NFC keeps compatibility characters
+	assertSameCanonicalForm(t, canonical.Text{Form: canonical.NFC}, "\u00a0\ufb01\n", "\u00a0\ufb01\n")
+}
+
+func TestExcC14NNormalization(t *testing.T) {
+	expected := "<p title=\"caf\u00e9\">caf\u00e9</p>"
+	assertSameCanonicalForm(t, canonical.ExcC14N{Form: canonical.NFC}, expected,
+		"<p title=\"cafe\u0301\">cafe\u0301</p>",
+		"<p title=\"caf&#xe9;\">caf<![CDATA[e]]>\u0301</p>",
+		"<p title=\"cafe&#x301;\">cafe<!-- accent -->&#x301;</p>",
+	)
+}
+
+func TestEncodeContentNormalization(t *testing.T) {
+	a := []byte("r\u00e9sum\u00e9\n")
+	b := []byte("re\u0301sume\u0301\n")
+
+	var bufA, bufB bytes.Buffer
+	if err := canonical.EncodeContent(&bufA, bytes.NewReader(a), int64(len(a)), canonical.ContentTypeText, canonical.NFKC, canonical.CBOR); err != nil {
+		t.Fatalf("EncodeContent failed: %v", err)
+	}
+	if err := canonical.EncodeContent(&bufB, bytes.NewReader(b), int64(len(b)), canonical.ContentTypeText, canonical.NFKC, canonical.CBOR); err != nil {
+		t.Fatalf("EncodeContent failed: %v", err)
+	}
+	if !bytes.Equal(bufA.Bytes(), bufB.Bytes()) {
+		t.Error("Normalized text documents encode differently")
+	}
+
+	if err := canonical.EncodeContent(&bufA, bytes.NewReader(a), int64(len(a)), canonical.ContentTypeOpaque, canonical.NFC, canonical.CBOR); err == nil {
+		t.Error("Expected opaque content to refuse normalization")
+	}
+}
+
+// jcsVectors are the input and output files of the RFC 8785 reference implementations
+var jcsVectors = []struct {
+	name     string
+	input    string
+	expected string
+}{
+	{
+		"arrays",
+		`[
+  56,
+  {
+    "d": true,
+    "10": null,
+    "1": [ ]
+  }
+]`,
+		`[56,{"1":[],"10":null,"d":true}]`,
+	},
+	{
+		"french",
+		`{
+  "peach": "This sorting order",
+  "péché": "is wrong according to French",
+  "pêche": "but canonicalization MUST",
+  "sin":   "ignore locale"
+}`,
+		`{"peach":"This sorting order","péché":"is wrong according to French","pêche":"but canonicalization MUST","sin":"ignore locale"}`,
+	},
+	{
+		"structures",
+		`{
+  "1": {"f": {"f": "hi","F": 5} ,"\n": 56.0},
+  "10": { },
+  "": "empty",
+  "a": { },
+  "111": [ {"e": "yes","E": "no" } ],
+  "A": { }
+}`,
+		`{"":"empty","1":{"\n":56,"f":{"F":5,"f":"hi"}},"10":{},"111":[{"E":"no","e":"yes"}],"A":{},"a":{}}`,
+	},
+	{
+		"unicode",
+		`{
+  "Unnormalized Unicode":"A\u030a"
+}`,
+		"{\"Unnormalized Unicode\":\"A\u030a\"}",
+	},
+	{
+		"values",
+		`{
+  "numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+  "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+  "literals": [null, true, false]
+}`,
+		`{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+	},
+	{
+		"weird",
+		`{
+  "\u20ac": "Euro Sign",
+  "\r": "Carriage Return",
+  "\u000a": "Newline",
+  "1": "One",
+  "\u0080": "Control\u007f",
+  "\ud83d\ude02": "Smiley",
+  "\u00f6": "Latin Small Letter O With Diaeresis",
+  "\ufb33": "Hebrew Letter Dalet With Dagesh",
+  "</script>": "Browser Challenge"
+}`,
+		"{\"\\n\":\"Newline\",\"\\r\":\"Carriage Return\",\"1\":\"One\",\"</script>\":\"Browser Challenge\",\"\u0080\":\"Control\u007f\"," +
+			"\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\",\"\U0001F602\":\"Smiley\",\"\uFB33\":\"Hebrew Letter Dalet With Dagesh\"}",
+	},
+}
+
+func TestJCSVectors(t *testing.T) {
+	for _, v := range jcsVectors {
+		got, err := (canonical.JCS{}).Canonicalize([]byte(v.input))
+		if err != nil {
+			t.Errorf("%s: Canonicalize failed: %v", v.name, err)
+			continue
+		}
+		if string(got) != v.expected {
+			t.Errorf("%s: Canonicalize\n got: %s\nwant: %s", v.name, got, v.expected)
+		}
+
+		// Encode reaches the same form through encoding/json
+		encoded, err := canonical.Encode(json.RawMessage(v.input), canonical.JSON)
+		if err != nil {
+			t.Errorf("%s: Encode failed: %v", v.name, err)
+			continue
+		}
+		if string(encoded) != v.expected {
+			t.Errorf("%s: Encode\n got: %s\nwant: %s", v.name, encoded, v.expected)
+		}
+	}
+}
+
+func TestJCSNumberVectors(t *testing.T) {
+	// IEEE 754 bit patterns and their serialization from RFC 8785 appendix B
+	vectors := []struct {
+		bits     string
+		expected string
+	}{
+		{"0000000000000000", "0"},
+		{"8000000000000000", "0"},
+		{"0000000000000001", "5e-324"},
+		{"8000000000000001", "-5e-324"},
+		{"7fefffffffffffff", "1.7976931348623157e+308"},
+		{"ffefffffffffffff", "-1.7976931348623157e+308"},
+		{"4340000000000000", "9007199254740992"},
+		{"c340000000000000", "-9007199254740992"},
+		{"4430000000000000", "295147905179352830000"},
+		{"44b52d02c7e14af5", "9.999999999999997e+22"},
+		{"44b52d02c7e14af6", "1e+23"},
+		{"44b52d02c7e14af7", "1.0000000000000001e+23"},
+		{"444b1ae4d6e2ef4e", "999999999999999700000"},
+		{"444b1ae4d6e2ef4f", "999999999999999900000"},
+		{"444b1ae4d6e2ef50", "1e+21"},
+		{"3eb0c6f7a0b5ed8c", "9.999999999999997e-7"},
+		{"3eb0c6f7a0b5ed8d", "0.000001"},
+		{"41b3de4355555553", "333333333.3333332"},
+		{"41b3de4355555554", "333333333.33333325"},
+		{"41b3de4355555555", "333333333.3333333"},
+		{"41b3de4355555556", "333333333.3333334"},
+		{"41b3de4355555557", "333333333.33333343"},
+		{"becbf647612f3696", "-0.0000033333333333333333"},
+		{"43143ff3c1cb0959", "1424953923781206.2"},
+	}
+
+	for _, v := range vectors {
+		b, err := hex.DecodeString(v.bits)
+		if err != nil {
+			t.Fatalf("Invalid vector %s: %v", v.bits, err)
+		}
+		f := math.Float64frombits(binary.BigEndian.Uint64(b))
+
+		encoded, err := canonical.Encode(f, canonical.JSON)
+		if err != nil {
+			t.Errorf("%s: Encode failed: %v", v.bits, err)
+			continue
+		}
+		if string(encoded) != v.expected {
+			t.Errorf("%s: expected %s, got %s", v.bits, v.expected, encoded)
+		}
+	}
+
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := canonical.Encode(f, canonical.JSON); err == nil {
+			t.Errorf("Expected %v to be refused", f)
+		}
+	}
+}
+
+func TestEncodeJSONStructs(t *testing.T) {
+	type motion struct {
+		Title  string            `json:"title"`
+		Votes  map[string]int    `json:"votes"`
+		Passed bool              `json:"passed"`
+		Ratio  float64           `json:"ratio"`
+		Notes  map[string]string `json:"notes,omitempty"`
+		Script string            `json:"script"`
+	}
+	m := motion{
+		Title:  "Zoning \u2028amendment",
+		Votes:  map[string]int{"yes": 5, "no": 2, "abstain": 0},
+		Passed: true,
+		Ratio:  5.0 / 7.0,
+		Script: "<b>&</b>",
+	}
+
+	encoded, err := canonical.Encode(m, canonical.JSON)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	expected := "{\"passed\":true,\"ratio\":0.7142857142857143,\"script\":\"<b>&</b>\",\"title\":\"Zoning \u2028amendment\"," +
+		"\"votes\":{\"abstain\":0,\"no\":2,\"yes\":5}}"
+	if string(encoded) != expected {
+		t.Errorf("Unexpected encoding\n got: %s\nwant: %s", encoded, expected)
+	}
+
+	// Integers beyond 2^53 would be rounded to a different value
+	if _, err := canonical.Encode(map[string]uint64{"n": 1<<53 + 1}, canonical.JSON); err == nil {
+		t.Error("Expected integer beyond double precision to be refused")
+	}
+	if _, err := canonical.Encode(map[string]int64{"n": 1 << 53}, canonical.JSON); err != nil {
+		t.Errorf("Encode of 2^53 failed: %v", err)
+	}
+	// 2^63 is a double, but its canonical form 9223372036854776000 is a
+	// different integer
+	if _, err := canonical.Encode(map[string]uint64{"n": 1 << 63}, canonical.JSON); err == nil {
+		t.Error("Expected 2^63 to be refused")
+	}
+}
+
+func TestJCSRoundsNumbers(t *testing.T) {
+	// Every form of a number parses to the nearest double
+	for number, expected := range map[string]string{
+		"9007199254740992":           "9007199254740992",
+		"9007199254740993":           "9007199254740992",
+		"9007199254740993.0":         "9007199254740992",
+		"9.007199254740993e15":       "9007199254740992",
+		"90071992547409930e-1":       "9007199254740992",
+		"-9007199254740993":          "-9007199254740992",
+		"9223372036854775808":        "9223372036854776000",
+		"92233720368547760E+2":       "9223372036854776000",
+		"295147905179352825856":      "295147905179352830000",
+		"333333333.33333329":         "333333333.3333333",
+		"0.10000000000000001":        "0.1",
+		"1000e-4":                    "0.1",
+		"1e-400":                     "0",
+		"-0.00e-9999999999999999999": "0",
+		"1e23":                       "1e+23",
+	} {
+		got, err := (canonical.JCS{}).Canonicalize([]byte("[" + number + "]"))
+		if err != nil || string(got) != "["+expected+"]" {
+			t.Errorf("%s: expected [%s], got %s (err=%v)", number, expected, got, err)
+		}
+	}
+
+	// Numbers beyond double range are refused
+	for _, number := range []string{"1e400", "-1e400", "1.7976931348623159e308"} {
+		if _, err := (canonical.JCS{}).Canonicalize([]byte("[" + number + "]")); err == nil {
+			t.Errorf("Expected %s to be refused", number)
+		}
+	}
+}
+
+func TestEncodeJSONNumbers(t *testing.T) {
+	for _, tt := range []struct {
+		f        float64
+		expected string
+	}{
+		{0, "0"},
+		{math.Copysign(0, -1), "0"},
+		{1, "1"},
+		{-1.5, "-1.5"},
+		{1e21, "1e+21"},
+		{1e20, "100000000000000000000"},
+		{123456789012345680000, "123456789012345680000"},
+		{0.000001, "0.000001"},
+		{0.0000001, "1e-7"},
+		{9007199254740992, "9007199254740992"},
+		{5e-324, "5e-324"},
+		{1.7976931348623157e308, "1.7976931348623157e+308"},
+	} {
+		encoded, err := canonical.Encode(tt.f, canonical.JSON)
+		if err != nil || string(encoded) != tt.expected {
+			t.Errorf("Encode(%v): expected %s, got %s (err=%v)", tt.f, tt.expected, encoded, err)
+		}
+	}
+}
+
+// duplicateMembers marshals to an object with a repeated member name
+type duplicateMembers struct{}
+
+func (duplicateMembers) MarshalJSON() ([]byte, error) {
+	return []byte(`{"a":1,"a":2}`), nil
+}
+
+func TestEncodeJSONRejectsDuplicateMembers(t *testing.T) {
+	if _, err := canonical.Encode(duplicateMembers{}, canonical.JSON); err == nil {
+		t.Error("Expected duplicate member names to be refused")
+	}
+}
+
+func TestProfileRegistry(t *testing.T) {
+	if got := canonical.DefaultProfileRegistry.Versions(); !reflect.DeepEqual(got, []string{canonical.Version1, canonical.Version2}) {
+		t.Errorf("Unexpected versions: %v", got)
+	}
+	if _, err := canonical.LookupProfile(canonical.CurrentVersion); err != nil {
+		t.Fatalf("Current version is not registered: %v", err)
+	}
+	for _, version := range []string{"", "1", "3.0"} {
+		if _, err := canonical.LookupProfile(version); err == nil {
+			t.Errorf("%q: expected unknown version to be refused", version)
+		}
+	}
+
+	r := canonical.NewProfileRegistry()
+	if err := r.Register(&canonical.Profile{Version: "9.0", JSON: canonical.JSONRFC8785}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	for _, p := range []*canonical.Profile{
+		{Version: "9.0", JSON: canonical.JSONRFC8785},
+		{Version: "", JSON: canonical.JSONRFC8785},
+		{Version: "9.1", JSON: "relaxed"},
+		{Version: "9.2", JSON: canonical.JSONRFC8785, Normalization: "NFD"},
+	} {
+		if err := r.Register(p); err == nil {
+			t.Errorf("%+v: expected Register to fail", p)
+		}
+	}
+}
+
+func TestProfileContentsAreFrozen(t *testing.T) {
+	// Registering a content type after init must not change how registered
+	// versions hash content: here a JSON type would lose its canonicalization.
+	// The type is unique to the run, as the default registry outlives it.
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	contentType := fmt.Sprintf("application/vnd.frozen-test-%x+json", suffix)
+	doc := []byte(`{"b": 1, "a": 2}`)
+	encode := func(p *canonical.Profile) []byte {
+		var buf bytes.Buffer
+		if err := p.EncodeContent(&buf, bytes.NewReader(doc), int64(len(doc)), contentType, "", canonical.CBOR); err != nil {
+			t.Fatalf("Version %s: EncodeContent failed: %v", p.Version, err)
+		}
+		return buf.Bytes()
+	}
+	before := make(map[string][]byte)
+	for _, version := range canonical.DefaultProfileRegistry.Versions() {
+		p, _ := canonical.LookupProfile(version)
+		before[version] = encode(p)
+	}
+
+	if err := canonical.DefaultContentRegistry.Register(contentType, canonical.Opaque{}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if c, err := canonical.DefaultContentRegistry.Lookup(contentType); err != nil || c != (canonical.Opaque{}) {
+		t.Fatalf("Expected the default registry to use the new canonicalizer, got %#v (err=%v)", c, err)
+	}
+
+	for _, version := range canonical.DefaultProfileRegistry.Versions() {
+		p, _ := canonical.LookupProfile(version)
+		if c, err := p.Canonicalizer(contentType, ""); err != nil || c != (canonical.JCS{}) {
+			t.Errorf("Version %s: expected %s to stay JCS, got %#v (err=%v)", version, contentType, c, err)
+		}
+		if got := encode(p); !bytes.Equal(got, before[version]) {
+			t.Errorf("Version %s: content hash rules changed after registration", version)
+		}
+		if err := p.Contents.Register("application/vnd.frozen-test-2", canonical.Opaque{}); err == nil {
+			t.Errorf("Version %s: expected its content registry to refuse registrations", version)
+		}
+	}
+
+	// A registered profile keeps its own copy of the registry it was given
+	contents := canonical.NewContentRegistry()
+	r := canonical.NewProfileRegistry()
+	p := &canonical.Profile{Version: "9.0", JSON: canonical.JSONRFC8785, Contents: contents}
+	if err := r.Register(p); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := contents.Register(canonical.ContentTypeJSON, canonical.JCS{}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if _, err := p.Canonicalizer(canonical.ContentTypeJSON, ""); err == nil {
+		t.Error("Expected a content type registered after the profile to be unknown to it")
+	}
+}
+
+func TestProfileEncoding(t *testing.T) {
+	v1, _ := canonical.LookupProfile(canonical.Version1)
+	v2, _ := canonical.LookupProfile(canonical.Version2)
+
+	value := map[string]interface{}{"title": "Zoning \u2028amendment", "votes": []int{5, 2}, "ratio": 0.5}
+	for _, format := range []canonical.Format{canonical.CBOR, canonical.JSON} {
+		expected, err := canonical.Encode(value, format)
+		if err != nil {
+			t.Fatalf("%s: Encode failed: %v", format, err)
+		}
+		got, err := v2.Encode(value, format)
+		if err != nil || !bytes.Equal(got, expected) {
+			t.Errorf("%s: version 2.0 encoding differs from Encode: %s (err=%v)", format, got, err)
+		}
+	}
+
+	// Version 1.0 JSON escapes line separators as encoding/json does
+	got, err := v1.Encode(value, canonical.JSON)
+	if err != nil {
+		t.Fatalf("Version 1.0 Encode failed: %v", err)
+	}
+	if expected := `{"ratio":0.5,"title":"Zoning \u2028amendment","votes":[5,2]}`; string(got) != expected {
+		t.Errorf("Unexpected version 1.0 encoding\n got: %s\nwant: %s", got, expected)
+	}
+
+	// Every version refuses non-canonical CBOR; only legacy decoding of
+	// version 1.0 accepts it
+	unsorted := mustHex(t, "a2 02 06 01 05")
+	var nonCanonical *canonical.NonCanonicalError
+	for _, p := range []*canonical.Profile{v1, v2} {
+		var m map[int]int
+		if err := p.Decode(unsorted, canonical.CBOR, &m); !errors.As(err, &nonCanonical) {
+			t.Errorf("Expected version %s to refuse unsorted map, got %v", p.Version, err)
+		}
+	}
+	var m map[int]int
+	if err := v1.DecodeLegacy(unsorted, canonical.CBOR, &m); err != nil || m[1] != 5 || m[2] != 6 {
+		t.Errorf("Version 1.0 DecodeLegacy failed: %v (%v)", err, m)
+	}
+	if err := v2.DecodeLegacy(unsorted, canonical.CBOR, &m); !errors.As(err, &nonCanonical) {
+		t.Errorf("Expected version 2.0 DecodeLegacy to refuse unsorted map, got %v", err)
+	}
+}
+
+func TestProfileCanonicalizer(t *testing.T) {
+	v1, _ := canonical.LookupProfile(canonical.Version1)
+	v2, _ := canonical.LookupProfile(canonical.Version2)
+
+	for _, contentType := range []string{"", canonical.ContentTypeOpaque} {
+		if c, err := v1.Canonicalizer(contentType, ""); err != nil || c != (canonical.Opaque{}) {
+			t.Errorf("%q: expected version 1.0 to hash opaque bytes, got %v (err=%v)", contentType, c, err)
+		}
+	}
+	// Bundles signed before canonical formats were versioned name a content
+	// type and normalization under 1.0
+	if c, err := v1.Canonicalizer(canonical.ContentTypeText, canonical.NFC); err != nil || c != (canonical.Text{Form: canonical.NFC}) {
+		t.Errorf("Expected version 1.0 to canonicalize NFC text, got %#v (err=%v)", c, err)
+	}
+	if c, err := v1.Canonicalizer(canonical.ContentTypeJSON, ""); err != nil || c != (canonical.JCS{}) {
+		t.Errorf("Expected version 1.0 to canonicalize JSON, got %#v (err=%v)", c, err)
+	}
+	if _, err := v1.Canonicalizer("image/png", ""); err == nil {
+		t.Error("Expected version 1.0 to refuse an unknown content type")
+	}
+	if v1.Normalization != "" {
+		t.Errorf("Expected version 1.0 to normalize nothing by default, got %s", v1.Normalization)
+	}
+
+	c, err := v2.Canonicalizer(canonical.ContentTypeJSON, v2.Normalization)
+	if err != nil {
+		t.Fatalf("Version 2.0 Canonicalizer failed: %v", err)
+	}
+	if c != (canonical.JCS{Form: canonical.NFC}) {
+		t.Errorf("Expected NFC JCS, got %#v", c)
+	}
+}
+
+func TestMigrateContent(t *testing.T) {
+	sha256Of := func(doc []byte) hash.Digest {
+		var buf bytes.Buffer
+		if err := canonical.EncodeStream(&buf, bytes.NewReader(doc), int64(len(doc)), canonical.CBOR); err != nil {
+			t.Fatalf("EncodeStream failed: %v", err)
+		}
+		d, err := hash.Sum(buf.Bytes(), hash.SHA256)
+		if err != nil {
+			t.Fatalf("Sum failed: %v", err)
+		}
+		return d
+	}
+
+	legacy := canonical.ContentRules{Version: canonical.Version1}
+	doc := []byte("{\"b\": 1,\n \"a\": 2}")
+	migration, err := canonical.MigrateContent(bytes.NewReader(doc), int64(len(doc)), legacy,
+		canonical.ContentRules{Version: canonical.Version2, ContentType: canonical.ContentTypeJSON, Normalization: canonical.NFC}, canonical.CBOR, hash.SHA256)
+	if err != nil {
+		t.Fatalf("MigrateContent failed: %v", err)
+	}
+	if !migration.FromHash.Equal(sha256Of(doc)) {
+		t.Errorf("Unexpected version 1.0 hash: %s", migration.FromHash)
+	}
+	if !migration.ToHash.Equal(sha256Of([]byte(`{"a":2,"b":1}`))) {
+		t.Errorf("Unexpected version 2.0 hash: %s", migration.ToHash)
+	}
+	if !migration.HashChanged() {
+		t.Error("Expected reformatted JSON to change the hash")
+	}
+
+	// Opaque content and canonical documents hash the same under both versions
+	for _, tt := range []struct {
+		doc string
+		to  canonical.ContentRules
+	}{
+		{"\x89PNG\r\n", canonical.ContentRules{Version: canonical.Version2}},
+		{`{"a":2,"b":1}`, canonical.ContentRules{Version: canonical.Version2, ContentType: canonical.ContentTypeJSON, Normalization: canonical.NFC}},
+		{"Minutes\n", canonical.ContentRules{Version: canonical.Version2, ContentType: canonical.ContentTypeText, Normalization: canonical.NFC}},
+	} {
+		migration, err := canonical.MigrateContent(strings.NewReader(tt.doc), int64(len(tt.doc)), legacy, tt.to, canonical.CBOR, hash.SHA256)
+		if err != nil {
+			t.Fatalf("%q: MigrateContent failed: %v", tt.doc, err)
+		}
+		if migration.HashChanged() {
+			t.Errorf("%q: expected the hash to be unchanged", tt.doc)
+		}
+	}
+
+	for _, to := range []canonical.ContentRules{{Version: "3.0"}, {Version: canonical.Version1, ContentType: "image/png"}} {
+		if _, err := canonical.MigrateContent(bytes.NewReader(doc), int64(len(doc)), legacy, to, canonical.CBOR, hash.SHA256); err == nil {
+			t.Errorf("%+v: expected MigrateContent to fail", to)
+		}
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("Invalid hex %q: %v", s, err)
+	}
+	return b
+}
+
+func TestCheckCanonicalCBOR(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		reason string // substring of the first violation, empty if canonical
+	}{
+		{"small integer", "0a", ""},
+		{"one-byte integer", "18 ff", ""},
+		{"overlong integer", "18 0a", "shortest form"},
+		{"overlong negative integer", "39 0009", "shortest form"},
+		{"overlong string length", "78 01 61", "shortest form"},
+		{"overlong tag", "d8 01 00", "shortest form"},
+		{"indefinite array", "9f 01 02 ff", "indefinite-length array"},
+		{"indefinite byte string", "5f 41 01 41 02 ff", "indefinite-length byte string"},
+		{"sorted map", "a2 01 00 02 00", ""},
+		{"unsorted map", "a2 02 00 01 00", "sorts before"},
+		{"duplicate key", "a2 01 00 01 01", "duplicate map key"},
+		{"shorter key first", "a2 61 61 01 19 03e8 02", ""},
+		{"longer key first", "a2 19 03e8 02 61 61 01", "sorts before"},
+		{"half float", "f9 3c00", ""},
+		{"single float for half", "fa 3f800000", "half precision is exact"},
+		{"double float for single", "fb 3ff0000000000000", "double precision"},
+		{"double float", "fb 3ff199999999999a", ""},
+		{"canonical NaN", "f9 7e00", ""},
+		{"single NaN", "fa 7fc00000", "NaN"},
+		{"single infinity", "fa 7f800000", "infinity"},
+		{"null", "f6", ""},
+		{"undefined", "f7", "simple value 23"},
+		{"invalid UTF-8", "62 c3 28", "UTF-8"},
+		{"trailing data", "01 02", "trailing data"},
+	}
+
+	for _, tt := range tests {
+		violations, err := canonical.CheckCanonical(mustHex(t, tt.data), canonical.CBOR)
+		if err != nil {
+			t.Errorf("%s: CheckCanonical failed: %v", tt.name, err)
+			continue
+		}
+		switch {
+		case tt.reason == "" && len(violations) > 0:
+			t.Errorf("%s: expected canonical, got %v", tt.name, violations)
+		case tt.reason != "" && len(violations) == 0:
+			t.Errorf("%s: expected violation %q", tt.name, tt.reason)
+		case tt.reason != "" && !strings.Contains(violations[0].Reason, tt.reason):
+			t.Errorf("%s: expected violation %q, got %v", tt.name, tt.reason, violations[0])
+		}
+	}
+
+	for _, data := range []string{"", "18", "62 61", "83 01 02", "ff", "1c", "9f 01"} {
+		if _, err := canonical.CheckCanonical(mustHex(t, data), canonical.CBOR); err == nil {
+			t.Errorf("%q: expected malformed CBOR error", data)
+		}
+	}
+}
+
+func TestCheckCanonicalCBORReportsEveryViolation(t *testing.T) {
+	// An overlong key, an unsorted pair and an indefinite array value
+	data := mustHex(t, "a2 18 02 00 01 9f ff")
+
+	violations, err := canonical.CheckCanonical(data, canonical.CBOR)
+	if err != nil {
+		t.Fatalf("CheckCanonical failed: %v", err)
+	}
+	if len(violations) != 3 {
+		t.Fatalf("Expected 3 violations, got %v", violations)
+	}
+	for i, offset := range []int{1, 4, 5} {
+		if violations[i].Offset != offset {
+			t.Errorf("Violation %d: expected offset %d, got %v", i, offset, violations[i])
+		}
+	}
+}
+
+func TestCheckCanonicalAcceptsEncodeOutput(t *testing.T) {
+	type record struct {
+		ID      string            `cbor:"1,keyasint"`
+		Version int               `cbor:"2,keyasint"`
+		Payload []byte            `cbor:"3,keyasint"`
+		Ratio   float64           `cbor:"4,keyasint"`
+		Labels  map[string]string `cbor:"5,keyasint"`
+		Items   []int64           `cbor:"6,keyasint"`
+	}
+	values := []interface{}{
+		record{ID: "office-v1", Version: 3, Payload: make([]byte, 300), Ratio: 0.1,
+			Labels: map[string]string{"b": "2", "aa": "1", "c": "3"}, Items: []int64{-1, 24, 65536, math.MinInt64}},
+		map[interface{}]interface{}{"a": 1, 1000: 2, -1: 3, "": nil},
+		[]float64{0, 1.5, 1e300, math.Inf(1), math.NaN(), 3.4028234663852886e38},
+		strings.Repeat("x", 70000),
+	}
+
+	for i, v := range values {
+		data, err := canonical.Encode(v, canonical.CBOR)
+		if err != nil {
+			t.Fatalf("Value %d: Encode failed: %v", i, err)
+		}
+		violations, err := canonical.CheckCanonical(data, canonical.CBOR)
+		if err != nil || len(violations) > 0 {
+			t.Errorf("Value %d: expected canonical, got %v (err=%v)", i, violations, err)
+		}
+	}
+}
+
+func TestDecodeRejectsNonCanonicalCBOR(t *testing.T) {
+	type pair struct {
+		A int `cbor:"1,keyasint"`
+		B int `cbor:"2,keyasint"`
+	}
+
+	var p pair
+	if err := canonical.Decode(mustHex(t, "a2 01 05 02 06"), canonical.CBOR, &p); err != nil || p.A != 5 || p.B != 6 {
+		t.Fatalf("Decode of canonical data failed: %v (%+v)", err, p)
+	}
+
+	// Each of these decodes to the same value with a lenient decoder
+	for _, data := range []string{
+		"a2 02 06 01 05",
+		"a2 01 18 05 02 06",
+		"bf 01 05 02 06 ff",
+		"a3 01 05 02 06 01 05",
+	} {
+		err := canonical.Decode(mustHex(t, data), canonical.CBOR, &p)
+		var nonCanonical *canonical.NonCanonicalError
+		if !errors.As(err, &nonCanonical) {
+			t.Errorf("%q: expected NonCanonicalError, got %v", data, err)
+		}
+	}
+}
+
+func TestDecodeRejectsMalleableCBOR(t *testing.T) {
+	type record struct {
+		A int    `cbor:"1,keyasint"`
+		B string `cbor:"2,keyasint,omitempty"`
+	}
+	v1, _ := canonical.LookupProfile(canonical.Version1)
+	v2, _ := canonical.LookupProfile(canonical.Version2)
+
+	// Both are canonical CBOR items that decode to {A: 5}, which encodes as a1 01 05
+	for name, data := range map[string]string{
+		"unknown key":          "a2 01 05 03 00",
+		"explicit empty field": "a2 01 05 02 60",
+	} {
+		var r record
+		if err := canonical.Decode(mustHex(t, data), canonical.CBOR, &r); err == nil {
+			t.Errorf("%s: Decode accepted %s", name, data)
+		}
+		for _, p := range []*canonical.Profile{v1, v2} {
+			if err := p.Decode(mustHex(t, data), canonical.CBOR, &r); err == nil {
+				t.Errorf("%s: version %s Decode accepted %s", name, p.Version, data)
+			}
+		}
+		if err := v1.DecodeLegacy(mustHex(t, data), canonical.CBOR, &r); err != nil || r.A != 5 {
+			t.Errorf("%s: version 1.0 DecodeLegacy failed: %v (%+v)", name, err, r)
+		}
+	}
+
+	var r record
+	if err := canonical.Decode(mustHex(t, "a2 01 05 02 61 78"), canonical.CBOR, &r); err != nil || r.A != 5 || r.B != "x" {
+		t.Errorf("Decode of canonical data failed: %v (%+v)", err, r)
+	}
+}
+
+func TestCheckCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		data   string
+		reason string
+	}{
+		{`{"a":1,"b":[true,null]}`, ""},
+		{`{"a": 1}`, "whitespace"},
+		{`{"b":1,"a":2}`, `found "b`},
+		{`{"a":1.50}`, `found "0}"`},
+		{`{"a":"\u0041"}`, `found "\\u0041`},
+	}
+
+	for _, tt := range tests {
+		violations, err := canonical.CheckCanonical([]byte(tt.data), canonical.JSON)
+		if err != nil {
+			t.Errorf("%s: CheckCanonical failed: %v", tt.data, err)
+			continue
+		}
+		switch {
+		case tt.reason == "" && len(violations) > 0:
+			t.Errorf("%s: expected canonical, got %v", tt.data, violations)
+		case tt.reason != "" && (len(violations) != 1 || !strings.Contains(violations[0].Reason, tt.reason)):
+			t.Errorf("%s: expected violation %q, got %v", tt.data, tt.reason, violations)
+		}
+	}
+
+	if _, err := canonical.CheckCanonical([]byte(`{"a":1,"a":1}`), canonical.JSON); err == nil {
+		t.Error("Expected duplicate member names to be refused")
+	}
+}
+
+func TestRecanonicalize(t *testing.T) {
+	// Three encodings of the same map
+	canonicalData := mustHex(t, "a2 01 05 02 06")
+	for _, data := range []string{"a2 01 05 02 06", "a2 02 06 01 05", "bf 01 18 05 02 06 ff"} {
+		got, err := canonical.Recanonicalize(mustHex(t, data), canonical.CBOR)
+		if err != nil || !bytes.Equal(got, canonicalData) {
+			t.Errorf("%q: expected a2 01 05 02 06, got %x (err=%v)", data, got, err)
+		}
+	}
+	for _, data := range []string{"a2 01 05 01 06", "01 02", "83 01"} {
+		if _, err := canonical.Recanonicalize(mustHex(t, data), canonical.CBOR); err == nil {
+			t.Errorf("%q: expected Recanonicalize to fail", data)
+		}
+	}
+
+	got, err := canonical.Recanonicalize([]byte(`{ "b": 1.50, "a": [] }`), canonical.JSON)
+	if err != nil || string(got) != `{"a":[],"b":1.5}` {
+		t.Errorf("Unexpected JSON: %s (err=%v)", got, err)
+	}
+	if _, err := canonical.Recanonicalize([]byte(`{"a":1,"a":2}`), canonical.JSON); err == nil {
+		t.Error("Expected duplicate member names to be refused")
+	}
+}
+
+func TestConformanceVectors(t *testing.T) {
+	vectors := canonical.ConformanceVectors()
+	if len(vectors) == 0 {
+		t.Fatal("No bundled vectors")
+	}
+	for _, v := range vectors {
+		if err := v.Check(); err != nil {
+			t.Errorf("%s: %v", v.Name, err)
+		}
+	}
+}
+
+func TestVectorCheckEncoding(t *testing.T) {
+	v := canonical.Vector{Name: "map", Format: canonical.CBOR, Input: "a2 02 00 01 00", Canonical: "a2 01 00 02 00"}
+
+	if err := v.CheckEncoding(mustHex(t, "a2 01 00 02 00")); err != nil {
+		t.Errorf("CheckEncoding of the canonical encoding failed: %v", err)
+	}
+	var nonCanonical *canonical.NonCanonicalError
+	if err := v.CheckEncoding(mustHex(t, "a2 02 00 01 00")); !errors.As(err, &nonCanonical) {
+		t.Errorf("Expected NonCanonicalError, got %v", err)
+	}
+	// Canonical, but of a different value
+	if err := v.CheckEncoding(mustHex(t, "a2 01 00 02 01")); err == nil || errors.As(err, &nonCanonical) {
+		t.Errorf("Expected a mismatch error, got %v", err)
+	}
+}
+
+func TestLoadVectors(t *testing.T) {
+	for _, data := range []string{
+		`{}`,
+		`[{"format":"CBOR","input":"00","canonical":"00"}]`,
+		`[{"name":"../a","format":"CBOR","input":"00","canonical":"00"}]`,
+		`[{"name":"a","format":"XML","input":"00","canonical":"00"}]`,
+		`[{"name":"a","format":"CBOR","input":"00"}]`,
+		`[{"name":"a","format":"CBOR","input":"00","canonical":"00","refused":"no"}]`,
+		`[{"name":"a","format":"CBOR","input":"00","canonical":"00"},{"name":"a","format":"JSON","input":"1","canonical":"1"}]`,
+	} {
+		if _, err := canonical.LoadVectors([]byte(data)); err == nil {
+			t.Errorf("%s: expected LoadVectors to fail", data)
+		}
+	}
+
+	// Check catches a vector with the wrong expectation
+	vectors, err := canonical.LoadVectors([]byte(`[{"name":"a","format":"JSON","input":"[1.0]","canonical":"[1.0]"}]`))
+	if err != nil {
+		t.Fatalf("LoadVectors failed: %v", err)
+	}
+	if err := vectors[0].Check(); err == nil {
+		t.Error("Expected Check to fail")
+	}
+}
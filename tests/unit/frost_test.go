@@ -0,0 +1,283 @@
+package unit
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/frost"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+)
+
+// runDKG runs a complete key generation among maxSigners participants
+func runDKG(t *testing.T, threshold, maxSigners int) map[frost.Identifier]*frost.KeyShare {
+	t.Helper()
+
+	participants := make(map[frost.Identifier]*frost.DKGParticipant)
+	var round1 []*frost.Round1Package
+	for i := 1; i <= maxSigners; i++ {
+		p, pkg, err := frost.NewDKGParticipant(frost.Identifier(i), threshold, maxSigners)
+		if err != nil {
+			t.Fatalf("Failed to start DKG: %v", err)
+		}
+		participants[frost.Identifier(i)] = p
+		round1 = append(round1, pkg)
+	}
+
+	inbox := make(map[frost.Identifier][]*frost.Round2Package)
+	for _, p := range participants {
+		out, err := p.Round2(round1)
+		if err != nil {
+			t.Fatalf("DKG round 2 failed: %v", err)
+		}
+		for _, pkg := range out {
+			inbox[pkg.To] = append(inbox[pkg.To], pkg)
+		}
+	}
+
+	shares := make(map[frost.Identifier]*frost.KeyShare)
+	for id, p := range participants {
+		share, err := p.Finalize(inbox[id])
+		if err != nil {
+			t.Fatalf("DKG finalize failed: %v", err)
+		}
+		shares[id] = share
+	}
+	return shares
+}
+
+// runSigning signs message with the given participants
+func runSigning(t *testing.T, shares map[frost.Identifier]*frost.KeyShare, signers []frost.Identifier, message []byte) ([]byte, error) {
+	t.Helper()
+
+	nonces := make(map[frost.Identifier]*frost.Nonces)
+	var commitments []*frost.Commitment
+	for _, id := range signers {
+		n, c, err := frost.Commit(shares[id])
+		if err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		nonces[id] = n
+		commitments = append(commitments, c)
+	}
+
+	var sigShares []*frost.SignatureShare
+	for _, id := range signers {
+		s, err := frost.Sign(shares[id], nonces[id], message, commitments)
+		if err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		sigShares = append(sigShares, s)
+	}
+
+	return frost.Aggregate(&shares[signers[0]].PublicKeyPackage, message, commitments, sigShares)
+}
+
+func TestDKGAndThresholdSigning(t *testing.T) {
+	shares := runDKG(t, 3, 5)
+
+	groupKey := shares[1].GroupPublicKey
+	for id, share := range shares {
+		if !bytes.Equal(share.GroupPublicKey, groupKey) {
+			t.Fatalf("Participant %d derived a different group key", id)
+		}
+		if len(share.VerifyingShares) != 5 {
+			t.Errorf("Participant %d has %d verifying shares", id, len(share.VerifyingShares))
+		}
+	}
+
+	message := []byte("revoke identity mayor-springfield-v1")
+	for _, signers := range [][]frost.Identifier{{1, 2, 3}, {2, 4, 5}, {1, 3, 5}, {1, 2, 3, 4, 5}} {
+		sig, err := runSigning(t, shares, signers, message)
+		if err != nil {
+			t.Fatalf("Signing with %v failed: %v", signers, err)
+		}
+
+		// The result is a plain Ed25519 signature
+		valid, err := signatures.Verify(groupKey, message, sig, signatures.Ed25519)
+		if err != nil || !valid {
+			t.Errorf("Signature by %v does not verify with signatures.Verify", signers)
+		}
+		if !ed25519.Verify(groupKey, message, sig) {
+			t.Errorf("Signature by %v does not verify with crypto/ed25519", signers)
+		}
+	}
+}
+
+func TestSigningBelowThreshold(t *testing.T) {
+	shares := runDKG(t, 3, 5)
+
+	n, c1, err := frost.Commit(shares[1])
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	_, c2, err := frost.Commit(shares[2])
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	if _, err := frost.Sign(shares[1], n, []byte("message"), []*frost.Commitment{c1, c2}); err == nil {
+		t.Error("Expected error when fewer than threshold participants sign")
+	}
+}
+
+func TestNonceReuse(t *testing.T) {
+	shares := runDKG(t, 2, 3)
+
+	n1, c1, _ := frost.Commit(shares[1])
+	_, c2, _ := frost.Commit(shares[2])
+	commitments := []*frost.Commitment{c1, c2}
+
+	if _, err := frost.Sign(shares[1], n1, []byte("first"), commitments); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	if _, err := frost.Sign(shares[1], n1, []byte("second"), commitments); err == nil {
+		t.Error("Expected error when reusing nonces")
+	}
+}
+
+func TestAggregateIdentifiesBadShare(t *testing.T) {
+	shares := runDKG(t, 3, 5)
+	message := []byte("message")
+	signers := []frost.Identifier{1, 2, 4}
+
+	nonces := make(map[frost.Identifier]*frost.Nonces)
+	var commitments []*frost.Commitment
+	for _, id := range signers {
+		n, c, _ := frost.Commit(shares[id])
+		nonces[id] = n
+		commitments = append(commitments, c)
+	}
+
+	var sigShares []*frost.SignatureShare
+	for _, id := range signers {
+		s, err := frost.Sign(shares[id], nonces[id], message, commitments)
+		if err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		sigShares = append(sigShares, s)
+	}
+
+	// Shares over one message do not aggregate for another
+	_, err := frost.Aggregate(&shares[1].PublicKeyPackage, []byte("other message"), commitments, sigShares)
+	if err == nil {
+		t.Fatal("Expected error for shares over a different message")
+	}
+
+	sigShares[2].Share[0] ^= 0x01
+	_, err = frost.Aggregate(&shares[1].PublicKeyPackage, message, commitments, sigShares)
+	if err == nil || !strings.Contains(err.Error(), "participants 4") {
+		t.Errorf("Expected participant 4 to be blamed, got %v", err)
+	}
+
+	valid, err := frost.VerifyShare(&shares[1].PublicKeyPackage, message, commitments, sigShares[0])
+	if err != nil || !valid {
+		t.Errorf("Honest share rejected: %v", err)
+	}
+	valid, err = frost.VerifyShare(&shares[1].PublicKeyPackage, message, commitments, sigShares[2])
+	if err != nil || valid {
+		t.Errorf("Tampered share accepted: %v", err)
+	}
+}
+
+func TestCommitmentsRejectSmallOrder(t *testing.T) {
+	shares := runDKG(t, 2, 3)
+	message := []byte("message")
+
+	n1, c1, _ := frost.Commit(shares[1])
+	_, c2, _ := frost.Commit(shares[2])
+	share, err := frost.Sign(shares[1], n1, message, []*frost.Commitment{c1, c2})
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	// The point (0, -1) of order 2 and the identity
+	order2 := bytes.Repeat([]byte{0xff}, 32)
+	order2[0] = 0xec
+	order2[31] = 0x7f
+	identity := append([]byte{1}, make([]byte, 31)...)
+
+	for _, b := range [][]byte{identity, order2} {
+		hiding := *c2
+		hiding.Hiding = b
+		if _, err := frost.VerifyShare(&shares[1].PublicKeyPackage, message, []*frost.Commitment{c1, &hiding}, share); err == nil {
+			t.Errorf("Expected error for hiding commitment %x", b)
+		}
+		binding := *c2
+		binding.Binding = b
+		if _, err := frost.VerifyShare(&shares[1].PublicKeyPackage, message, []*frost.Commitment{c1, &binding}, share); err == nil {
+			t.Errorf("Expected error for binding commitment %x", b)
+		}
+	}
+}
+
+func TestDKGRejectsCheating(t *testing.T) {
+	var participants []*frost.DKGParticipant
+	var round1 []*frost.Round1Package
+	for i := 1; i <= 3; i++ {
+		p, pkg, err := frost.NewDKGParticipant(frost.Identifier(i), 2, 3)
+		if err != nil {
+			t.Fatalf("Failed to start DKG: %v", err)
+		}
+		participants = append(participants, p)
+		round1 = append(round1, pkg)
+	}
+
+	// A rogue key without a valid proof of knowledge is rejected
+	forged := *round1[2]
+	forged.Commitment = append([][]byte{round1[0].Commitment[0]}, forged.Commitment[1:]...)
+	if _, err := participants[0].Round2([]*frost.Round1Package{round1[0], round1[1], &forged}); err == nil {
+		t.Error("Expected error for invalid proof of knowledge")
+	}
+
+	// A share that does not match the sender's commitment is rejected
+	out, err := participants[1].Round2(round1)
+	if err != nil {
+		t.Fatalf("DKG round 2 failed: %v", err)
+	}
+	if _, err := participants[0].Round2(round1); err != nil {
+		t.Fatalf("DKG round 2 failed: %v", err)
+	}
+	out3, err := participants[2].Round2(round1)
+	if err != nil {
+		t.Fatalf("DKG round 2 failed: %v", err)
+	}
+
+	var inbox []*frost.Round2Package
+	for _, pkg := range append(out, out3...) {
+		if pkg.To == 1 {
+			inbox = append(inbox, pkg)
+		}
+	}
+	inbox[0].Share[0] ^= 0x01
+	if _, err := participants[0].Finalize(inbox); err == nil {
+		t.Error("Expected error for share not matching commitment")
+	}
+
+	if _, _, err := frost.NewDKGParticipant(1, 1, 3); err == nil {
+		t.Error("Expected error for threshold below 2")
+	}
+	if _, _, err := frost.NewDKGParticipant(0, 2, 3); err == nil {
+		t.Error("Expected error for zero identifier")
+	}
+}
+
+func TestKeyShareJSON(t *testing.T) {
+	shares := runDKG(t, 2, 3)
+
+	data, err := json.Marshal(shares[2])
+	if err != nil {
+		t.Fatalf("Failed to marshal key share: %v", err)
+	}
+	var decoded frost.KeyShare
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal key share: %v", err)
+	}
+	shares[2] = &decoded
+
+	if _, err := runSigning(t, shares, []frost.Identifier{2, 3}, []byte("message")); err != nil {
+		t.Errorf("Signing with decoded key share failed: %v", err)
+	}
+}
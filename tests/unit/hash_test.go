@@ -0,0 +1,146 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestMultiHasherMatchesHash(t *testing.T) {
+	data := bytes.Repeat([]byte("hearing recording "), 10000)
+	algos := []hash.Algorithm{hash.SHA256, hash.SHA3_512, hash.BLAKE3}
+
+	var progress []int64
+	sums, err := hash.HashReader(iotest.HalfReader(bytes.NewReader(data)), func(n int64) {
+		progress = append(progress, n)
+	}, algos...)
+	if err != nil {
+		t.Fatalf("HashReader failed: %v", err)
+	}
+
+	for _, algo := range algos {
+		expected, err := hash.Hash(data, algo)
+		if err != nil {
+			t.Fatalf("Hash failed: %v", err)
+		}
+		if !bytes.Equal(sums[algo], expected) {
+			t.Errorf("%s: streamed digest differs from Hash", algo)
+		}
+	}
+
+	if len(progress) == 0 || progress[len(progress)-1] != int64(len(data)) {
+		t.Errorf("Progress did not reach %d bytes: %v", len(data), progress)
+	}
+	for i := 1; i < len(progress); i++ {
+		if progress[i] <= progress[i-1] {
+			t.Fatalf("Progress is not increasing: %v", progress)
+		}
+	}
+}
+
+func TestNewMultiHasherRejectsInvalidAlgorithms(t *testing.T) {
+	if _, err := hash.NewMultiHasher(); err == nil {
+		t.Error("Expected error for no algorithms")
+	}
+	if _, err := hash.NewMultiHasher(hash.SHA256, hash.SHA256); err == nil {
+		t.Error("Expected error for duplicate algorithm")
+	}
+	if _, err := hash.NewMultiHasher("MD5"); err == nil {
+		t.Error("Expected error for unsupported algorithm")
+	}
+
+	m, err := hash.NewMultiHasher(hash.SHA256)
+	if err != nil {
+		t.Fatalf("NewMultiHasher failed: %v", err)
+	}
+	if _, err := m.Sum(hash.BLAKE3); err == nil {
+		t.Error("Expected error for an algorithm that was not computed")
+	}
+}
+
+func TestDigestEncoding(t *testing.T) {
+	d, err := hash.Sum([]byte("hello"), hash.SHA256)
+	if err != nil {
+		t.Fatalf("Sum failed: %v", err)
+	}
+
+	// The multihash of a SHA-256 digest is 0x12 0x20 followed by the digest
+	expected := "1220" + "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if got := hex.EncodeToString(d.Bytes()); got != expected {
+		t.Errorf("Expected multihash %s, got %s", expected, got)
+	}
+
+	jsonData, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Failed to encode JSON: %v", err)
+	}
+	if string(jsonData) != `"`+expected+`"` {
+		t.Errorf("Unexpected JSON encoding: %s", jsonData)
+	}
+
+	for _, algo := range []hash.Algorithm{hash.SHA256, hash.SHA3_512, hash.BLAKE3} {
+		d, err := hash.Sum([]byte("hello"), algo)
+		if err != nil {
+			t.Fatalf("Sum failed: %v", err)
+		}
+
+		cborData, err := cbor.Marshal(d)
+		if err != nil {
+			t.Fatalf("Failed to encode CBOR: %v", err)
+		}
+		var fromCBOR hash.Digest
+		if err := cbor.Unmarshal(cborData, &fromCBOR); err != nil || !fromCBOR.Equal(d) {
+			t.Errorf("%s: CBOR round trip failed (err=%v)", algo, err)
+		}
+
+		jsonData, err := json.Marshal(d)
+		if err != nil {
+			t.Fatalf("Failed to encode JSON: %v", err)
+		}
+		var fromJSON hash.Digest
+		if err := json.Unmarshal(jsonData, &fromJSON); err != nil || !fromJSON.Equal(d) {
+			t.Errorf("%s: JSON round trip failed (err=%v)", algo, err)
+		}
+	}
+}
+
+func TestParseDigestRejectsMismatches(t *testing.T) {
+	sha256Value := bytes.Repeat([]byte{0xab}, 32)
+
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"unknown code", append([]byte{0x13, 0x20}, sha256Value...)},
+		{"length does not match algorithm", append([]byte{0x14, 0x20}, sha256Value...)},
+		{"truncated value", append([]byte{0x12, 0x20}, sha256Value[:31]...)},
+		{"trailing bytes", append(append([]byte{0x12, 0x20}, sha256Value...), 0x00)},
+		{"non-minimal varint", append([]byte{0x92, 0x00, 0x20}, sha256Value...)},
+	}
+
+	for _, tc := range testCases {
+		if _, err := hash.ParseDigest(tc.data); err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+
+	if _, err := hash.NewDigest(hash.SHA3_512, sha256Value); err == nil {
+		t.Error("Expected error for SHA-3-512 digest of the wrong length")
+	}
+	if _, err := cbor.Marshal(hash.Digest{Algorithm: hash.SHA256, Value: sha256Value[:16]}); err == nil {
+		t.Error("Expected error encoding an invalid digest")
+	}
+
+	upper := `"1220` + strings.ToUpper(hex.EncodeToString(sha256Value)) + `"`
+	var d hash.Digest
+	if err := json.Unmarshal([]byte(upper), &d); err == nil {
+		t.Error("Expected error for uppercase hex digest")
+	}
+}
@@ -0,0 +1,233 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/canonical"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/merkle"
+	"github.com/IAmSoThirsty/civic-attest/internal/ledger/tree"
+)
+
+func appendEntries(t *testing.T, ledger *tree.LedgerTree, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		signatureHash, err := hash.Sum([]byte{byte(i)}, hash.SHA256)
+		if err != nil {
+			t.Fatalf("Failed to hash signature: %v", err)
+		}
+
+		entry := &tree.Entry{
+			SignerIdentityID: "office-test-v1",
+			SignatureHash:    signatureHash,
+			EntryType:        "signature",
+			Timestamp:        time.Unix(int64(1700000000+i), 0).UTC(),
+		}
+		if err := ledger.Append(entry); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+}
+
+func TestLedgerVerifyConsistency(t *testing.T) {
+	ledger := tree.NewLedgerTree(hash.SHA256)
+
+	appendEntries(t, ledger, 5)
+	oldSTH := ledger.GetSignedTreeHead()
+
+	appendEntries(t, ledger, 8)
+	newSTH := ledger.GetSignedTreeHead()
+
+	proof, err := ledger.GenerateConsistencyProof(oldSTH.TreeSize)
+	if err != nil {
+		t.Fatalf("Failed to generate proof: %v", err)
+	}
+
+	valid, err := ledger.VerifyConsistency(oldSTH, newSTH, proof)
+	if err != nil {
+		t.Fatalf("Failed to verify: %v", err)
+	}
+	if !valid {
+		t.Error("Consistency proof should verify")
+	}
+
+	// A tree head with a substituted root is not consistent
+	tampered := *oldSTH
+	tampered.RootHash = newSTH.RootHash
+	valid, err = ledger.VerifyConsistency(&tampered, newSTH, proof)
+	if err != nil {
+		t.Fatalf("Failed to verify: %v", err)
+	}
+	if valid {
+		t.Error("Consistency proof should not verify against a substituted root")
+	}
+
+	// The proof must cover the tree heads being compared
+	if _, err := ledger.VerifyConsistency(newSTH, newSTH, proof); err == nil {
+		t.Error("Expected error for mismatched proof sizes")
+	}
+}
+
+func TestLedgerHistoricalQueries(t *testing.T) {
+	ledger := tree.NewLedgerTree(hash.SHA256)
+
+	// Cache tree heads as a client would while the ledger grows
+	heads := make([]*tree.SignedTreeHead, 0)
+	for i := 0; i < 6; i++ {
+		appendEntries(t, ledger, 3)
+		heads = append(heads, ledger.GetSignedTreeHead())
+	}
+
+	for _, sth := range heads {
+		root, err := ledger.RootAt(sth.TreeSize)
+		if err != nil {
+			t.Fatalf("RootAt(%d) failed: %v", sth.TreeSize, err)
+		}
+		if !bytes.Equal(root, sth.RootHash) {
+			t.Errorf("RootAt(%d) does not match the cached tree head", sth.TreeSize)
+		}
+
+		// An entry appended before the old head verifies against it
+		index := sth.TreeSize - 1
+		proof, err := ledger.InclusionProofAt(index, sth.TreeSize)
+		if err != nil {
+			t.Fatalf("InclusionProofAt(%d, %d) failed: %v", index, sth.TreeSize, err)
+		}
+		valid, err := merkle.VerifyInclusion(ledger.Hasher(), proof.LeafHash, index, sth.TreeSize, proof.Path, sth.RootHash)
+		if err != nil || !valid {
+			t.Errorf("Proof at size %d did not verify (err=%v)", sth.TreeSize, err)
+		}
+	}
+
+	if _, err := ledger.InclusionProofAt(10, 9); err == nil {
+		t.Error("Expected error for an entry after the requested tree size")
+	}
+	if _, err := ledger.RootAt(ledger.GetSize() + 1); err == nil {
+		t.Error("Expected error for a future tree size")
+	}
+}
+
+func TestLedgerEntryCanonicalDecoding(t *testing.T) {
+	ledger := tree.NewLedgerTree(hash.SHA256)
+	appendEntries(t, ledger, 1)
+	entry, err := ledger.GetEntry(0)
+	if err != nil {
+		t.Fatalf("GetEntry failed: %v", err)
+	}
+
+	data, err := canonical.Encode(entry, canonical.CBOR)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	var decoded tree.Entry
+	if err := canonical.Decode(data, canonical.CBOR, &decoded); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !decoded.EntryHash.Equal(entry.EntryHash) || !decoded.Timestamp.Equal(entry.Timestamp) || decoded.SequenceNumber != entry.SequenceNumber {
+		t.Error("Decoded entry differs from the original")
+	}
+
+	// The same entry with its fields in declaration order rather than sorted
+	unsorted, err := cbor.Marshal(entry)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	violations, err := canonical.CheckCanonical(unsorted, canonical.CBOR)
+	if err != nil || len(violations) == 0 {
+		t.Errorf("Expected violations for unsorted entry, got %v (err=%v)", violations, err)
+	}
+	if err := canonical.Decode(unsorted, canonical.CBOR, &decoded); err == nil {
+		t.Error("Expected unsorted entry to be refused")
+	}
+}
+
+func TestLedgerEntryWithoutSignatureHash(t *testing.T) {
+	ledger := tree.NewLedgerTree(hash.SHA256)
+	entry := &tree.Entry{
+		SignerIdentityID: "office-test-v1",
+		EntryType:        "revocation",
+		Timestamp:        time.Unix(1700000000, 0).UTC(),
+	}
+	if err := ledger.Append(entry); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	// The unset signature hash encodes as null and decodes back to unset
+	jsonData, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatalf("Failed to encode JSON: %v", err)
+	}
+	if !bytes.Contains(jsonData, []byte(`"signature_hash":null`)) {
+		t.Errorf("Expected a null signature hash: %s", jsonData)
+	}
+	var fromJSON tree.Entry
+	if err := json.Unmarshal(jsonData, &fromJSON); err != nil || !fromJSON.SignatureHash.IsZero() || !fromJSON.EntryHash.Equal(entry.EntryHash) {
+		t.Errorf("JSON round trip failed (err=%v)", err)
+	}
+
+	cborData, err := canonical.Encode(entry, canonical.CBOR)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	var fromCBOR tree.Entry
+	if err := canonical.Decode(cborData, canonical.CBOR, &fromCBOR); err != nil || !fromCBOR.SignatureHash.IsZero() {
+		t.Errorf("CBOR round trip failed (err=%v)", err)
+	}
+
+	// A signature hash that is set must be a valid digest
+	invalid := &tree.Entry{SignatureHash: hash.Digest{Algorithm: hash.SHA256, Value: []byte{1, 2, 3}}}
+	if err := ledger.Append(invalid); err == nil {
+		t.Error("Expected error for a malformed signature hash")
+	}
+}
+
+func TestLedgerReplayDeprecatedAlgorithm(t *testing.T) {
+	if err := hash.DefaultRegistry.SetStatus(hash.SHA384, hash.StatusDeprecated, time.Time{}); err != nil {
+		t.Fatalf("SetStatus failed: %v", err)
+	}
+	defer hash.DefaultRegistry.SetStatus(hash.SHA384, hash.StatusAllowed, time.Time{})
+
+	signatureHash, err := hash.Sum([]byte("signature"), hash.SHA384)
+	if err != nil {
+		t.Fatalf("Failed to hash signature: %v", err)
+	}
+	newEntry := func(sequence int64) *tree.Entry {
+		return &tree.Entry{
+			SignerIdentityID: "office-test-v1",
+			SignatureHash:    signatureHash,
+			EntryType:        "signature",
+			Timestamp:        time.Unix(1700000000, 0).UTC(),
+			SequenceNumber:   sequence,
+		}
+	}
+
+	// A deprecated algorithm may not be used for new entries
+	ledger := tree.NewLedgerTree(hash.SHA256)
+	if err := ledger.Append(newEntry(0)); err == nil {
+		t.Error("Expected Append to refuse a deprecated signature hash")
+	}
+
+	// but entries recorded with it still load, in sequence order
+	if err := ledger.Replay(newEntry(1)); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if err := ledger.Replay(newEntry(3)); err == nil {
+		t.Error("Expected Replay to refuse an entry out of sequence")
+	}
+
+	// Forbidden algorithms are refused either way
+	if err := hash.DefaultRegistry.SetStatus(hash.SHA384, hash.StatusForbidden, time.Time{}); err != nil {
+		t.Fatalf("SetStatus failed: %v", err)
+	}
+	if err := ledger.Replay(newEntry(2)); err == nil {
+		t.Error("Expected Replay to refuse a forbidden signature hash")
+	}
+	if ledger.GetSize() != 1 {
+		t.Errorf("Expected 1 entry, got %d", ledger.GetSize())
+	}
+}
@@ -1,6 +1,9 @@
 package unit
 
 import (
+	"bytes"
+	"encoding/hex"
+	"math/rand"
 	"testing"
 
 	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
@@ -63,3 +66,538 @@ func TestInclusionProof(t *testing.T) {
 		t.Errorf("Expected tree size 5, got %d", proof.TreeSize)
 	}
 }
+
+func TestInclusionProofAllSizes(t *testing.T) {
+	for size := 1; size <= 33; size++ {
+		tree := merkle.NewTree(hash.SHA256)
+		for i := 0; i < size; i++ {
+			if err := tree.Append([]byte{byte(i)}); err != nil {
+				t.Fatalf("Failed to append: %v", err)
+			}
+		}
+
+		root := tree.RootHash()
+		for index := 0; index < size; index++ {
+			proof, err := tree.GenerateInclusionProof(index)
+			if err != nil {
+				t.Fatalf("Failed to generate proof: %v", err)
+			}
+
+			valid, err := merkle.VerifyInclusion(tree.Hasher(), proof.LeafHash, index, size, proof.Path, root)
+			if err != nil {
+				t.Fatalf("size %d index %d: verification error: %v", size, index, err)
+			}
+			if !valid {
+				t.Errorf("size %d index %d: proof did not verify", size, index)
+			}
+
+			if !tree.VerifyInclusionProof(proof) {
+				t.Errorf("size %d index %d: tree rejected its own proof", size, index)
+			}
+		}
+	}
+}
+
+func TestInclusionProofDuplicateLeaves(t *testing.T) {
+	tree := merkle.NewTree(hash.SHA256)
+	for i := 0; i < 6; i++ {
+		if err := tree.Append([]byte("same")); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+
+	for index := 0; index < 6; index++ {
+		proof, err := tree.GenerateInclusionProof(index)
+		if err != nil {
+			t.Fatalf("Failed to generate proof: %v", err)
+		}
+
+		valid, err := merkle.VerifyInclusion(tree.Hasher(), proof.LeafHash, index, 6, proof.Path, tree.RootHash())
+		if err != nil || !valid {
+			t.Errorf("index %d: proof did not verify (err=%v)", index, err)
+		}
+	}
+}
+
+func TestInclusionProofRejectsTampering(t *testing.T) {
+	tree := merkle.NewTree(hash.SHA256)
+	for i := 0; i < 7; i++ {
+		if err := tree.Append([]byte{byte(i)}); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+
+	proof, err := tree.GenerateInclusionProof(3)
+	if err != nil {
+		t.Fatalf("Failed to generate proof: %v", err)
+	}
+	root := tree.RootHash()
+
+	// Wrong index
+	if valid, _ := merkle.VerifyInclusion(tree.Hasher(), proof.LeafHash, 2, 7, proof.Path, root); valid {
+		t.Error("Proof should not verify at a different index")
+	}
+
+	// Tampered path element
+	tampered := make([][]byte, len(proof.Path))
+	for i, p := range proof.Path {
+		tampered[i] = append([]byte(nil), p...)
+	}
+	tampered[1][0] ^= 0xff
+	if valid, _ := merkle.VerifyInclusion(tree.Hasher(), proof.LeafHash, 3, 7, tampered, root); valid {
+		t.Error("Proof should not verify with a tampered path")
+	}
+
+	// Truncated and extended paths are malformed
+	if _, err := merkle.VerifyInclusion(tree.Hasher(), proof.LeafHash, 3, 7, proof.Path[:1], root); err == nil {
+		t.Error("Expected error for truncated path")
+	}
+	extended := append(append([][]byte(nil), proof.Path...), root)
+	if _, err := merkle.VerifyInclusion(tree.Hasher(), proof.LeafHash, 3, 7, extended, root); err == nil {
+		t.Error("Expected error for extended path")
+	}
+
+	// Index outside the tree
+	if _, err := merkle.VerifyInclusion(tree.Hasher(), proof.LeafHash, 7, 7, proof.Path, root); err == nil {
+		t.Error("Expected error for out of range index")
+	}
+
+	// Path elements and leaf hashes must be SHA-256 digests
+	for _, length := range []int{0, 31, 33, 64} {
+		resized := append([][]byte(nil), proof.Path...)
+		resized[0] = make([]byte, length)
+		if _, err := merkle.VerifyInclusion(tree.Hasher(), proof.LeafHash, 3, 7, resized, root); err == nil {
+			t.Errorf("Expected error for a %d-byte path element", length)
+		}
+	}
+	if _, err := merkle.VerifyInclusion(tree.Hasher(), append(proof.LeafHash, 0), 3, 7, proof.Path, root); err == nil {
+		t.Error("Expected error for an oversized leaf hash")
+	}
+}
+
+func TestConsistencyProofAllSizes(t *testing.T) {
+	const maxSize = 33
+
+	// Record the root after every append so old states can be checked
+	tree := merkle.NewTree(hash.SHA256)
+	hasher := tree.Hasher()
+	roots := make([][]byte, maxSize+1)
+	for i := 0; i < maxSize; i++ {
+		if err := tree.Append([]byte{byte(i)}); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+		roots[i+1] = tree.RootHash()
+	}
+
+	for newSize := 1; newSize <= maxSize; newSize++ {
+		prefix := merkle.NewTree(hash.SHA256)
+		for i := 0; i < newSize; i++ {
+			if err := prefix.Append([]byte{byte(i)}); err != nil {
+				t.Fatalf("Failed to append: %v", err)
+			}
+		}
+
+		for oldSize := 0; oldSize <= newSize; oldSize++ {
+			proof, err := prefix.GenerateConsistencyProof(oldSize)
+			if err != nil {
+				t.Fatalf("Failed to generate proof: %v", err)
+			}
+
+			valid, err := merkle.VerifyConsistency(hasher, oldSize, newSize, roots[oldSize], roots[newSize], proof.Path)
+			if err != nil {
+				t.Fatalf("%d -> %d: verification error: %v", oldSize, newSize, err)
+			}
+			if !valid {
+				t.Errorf("%d -> %d: proof did not verify", oldSize, newSize)
+			}
+		}
+	}
+}
+
+func TestConsistencyProofRejectsForks(t *testing.T) {
+	honest := merkle.NewTree(hash.SHA256)
+	forked := merkle.NewTree(hash.SHA256)
+	hasher := honest.Hasher()
+	for i := 0; i < 10; i++ {
+		if err := honest.Append([]byte{byte(i)}); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+
+		// The forked log rewrites entry 2
+		data := []byte{byte(i)}
+		if i == 2 {
+			data = []byte("rewritten")
+		}
+		if err := forked.Append(data); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+
+	old := merkle.NewTree(hash.SHA256)
+	for i := 0; i < 6; i++ {
+		if err := old.Append([]byte{byte(i)}); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+
+	proof, err := forked.GenerateConsistencyProof(6)
+	if err != nil {
+		t.Fatalf("Failed to generate proof: %v", err)
+	}
+
+	valid, err := merkle.VerifyConsistency(hasher, 6, 10, old.RootHash(), forked.RootHash(), proof.Path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if valid {
+		t.Error("Consistency proof should not verify for a forked log")
+	}
+
+	// A valid proof must not verify against a different new root
+	proof, err = honest.GenerateConsistencyProof(6)
+	if err != nil {
+		t.Fatalf("Failed to generate proof: %v", err)
+	}
+	if valid, _ := merkle.VerifyConsistency(hasher, 6, 10, old.RootHash(), forked.RootHash(), proof.Path); valid {
+		t.Error("Consistency proof should not verify against the wrong new root")
+	}
+
+	// Malformed paths are errors
+	if _, err := merkle.VerifyConsistency(hasher, 6, 10, old.RootHash(), honest.RootHash(), nil); err == nil {
+		t.Error("Expected error for empty path")
+	}
+	if _, err := merkle.VerifyConsistency(hasher, 10, 6, old.RootHash(), honest.RootHash(), proof.Path); err == nil {
+		t.Error("Expected error for shrinking tree")
+	}
+}
+
+// RFC 6962 test leaves and the expected SHA-256 roots of each prefix
+var rfc6962Leaves = [][]byte{
+	{},
+	{0x00},
+	{0x10},
+	{0x20, 0x21},
+	{0x30, 0x31},
+	{0x40, 0x41, 0x42, 0x43},
+	{0x50, 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57},
+	{0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6a, 0x6b, 0x6c, 0x6d, 0x6e, 0x6f},
+}
+
+var rfc6962Roots = []string{
+	"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+	"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+}
+
+func TestRFC6962Roots(t *testing.T) {
+	tree := merkle.NewTree(hash.SHA256)
+	for i, leaf := range rfc6962Leaves {
+		if err := tree.Append(leaf); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+
+		got := hex.EncodeToString(tree.RootHash())
+		if got != rfc6962Roots[i] {
+			t.Errorf("size %d: expected root %s, got %s", i+1, rfc6962Roots[i], got)
+		}
+	}
+}
+
+func TestLegacyHashSchemeRebuild(t *testing.T) {
+	tree, err := merkle.NewTreeWithScheme(hash.SHA256, merkle.HashSchemeV0)
+	if err != nil {
+		t.Fatalf("Failed to create tree: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := tree.Append([]byte{byte(i)}); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+
+	// Legacy root: H(H(H(0) || H(1)) || H(2)) with no prefixes
+	h := func(data []byte) []byte {
+		out, err := hash.Hash(data, hash.SHA256)
+		if err != nil {
+			t.Fatalf("Hash failed: %v", err)
+		}
+		return out
+	}
+	left := h(append(h([]byte{0}), h([]byte{1})...))
+	expected := h(append(left, h([]byte{2})...))
+
+	if !bytes.Equal(tree.RootHash(), expected) {
+		t.Errorf("Expected legacy root %x, got %x", expected, tree.RootHash())
+	}
+
+	if _, err := merkle.NewTreeWithScheme(hash.SHA256, merkle.HashScheme(7)); err == nil {
+		t.Error("Expected error for unknown hash scheme")
+	}
+}
+
+func TestInteriorNodeCannotPassAsLeaf(t *testing.T) {
+	tree := merkle.NewTree(hash.SHA256)
+	for i := 0; i < 4; i++ {
+		if err := tree.Append([]byte{byte(i)}); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+
+	// Present the concatenated children of the left subtree as a leaf of a 2-leaf tree
+	left := tree.Root.Left
+	forgedData := append(append([]byte(nil), left.Left.Hash...), left.Right.Hash...)
+
+	hasher := tree.Hasher()
+	forgedLeaf, err := hasher.HashLeaf(forgedData)
+	if err != nil {
+		t.Fatalf("Failed to hash leaf: %v", err)
+	}
+
+	valid, err := merkle.VerifyInclusion(hasher, forgedLeaf, 0, 2, [][]byte{tree.Root.Right.Hash}, tree.RootHash())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if valid {
+		t.Error("Interior node data should not verify as a leaf")
+	}
+}
+
+func TestIncrementalTreeMatchesReference(t *testing.T) {
+	const maxSize = 70
+
+	reference := merkle.NewTree(hash.SHA256)
+	incremental := merkle.NewIncrementalTree(hash.SHA256)
+	roots := make([][]byte, maxSize+1)
+
+	for i := 0; i < maxSize; i++ {
+		data := []byte{byte(i), byte(i >> 8)}
+		if err := reference.Append(data); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+		if err := incremental.Append(data); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+
+		if !bytes.Equal(reference.RootHash(), incremental.RootHash()) {
+			t.Fatalf("size %d: roots differ", i+1)
+		}
+		roots[i+1] = reference.RootHash()
+
+		size := i + 1
+		for index := 0; index < size; index++ {
+			want, err := reference.GenerateInclusionProof(index)
+			if err != nil {
+				t.Fatalf("Failed to generate reference proof: %v", err)
+			}
+			got, err := incremental.GenerateInclusionProof(index)
+			if err != nil {
+				t.Fatalf("Failed to generate proof: %v", err)
+			}
+			if !equalPaths(want.Path, got.Path) || !bytes.Equal(want.LeafHash, got.LeafHash) {
+				t.Fatalf("size %d index %d: inclusion proofs differ", size, index)
+			}
+		}
+
+		for oldSize := 0; oldSize <= size; oldSize++ {
+			want, err := reference.GenerateConsistencyProof(oldSize)
+			if err != nil {
+				t.Fatalf("Failed to generate reference proof: %v", err)
+			}
+			got, err := incremental.GenerateConsistencyProof(oldSize)
+			if err != nil {
+				t.Fatalf("Failed to generate proof: %v", err)
+			}
+			if !equalPaths(want.Path, got.Path) {
+				t.Fatalf("%d -> %d: consistency proofs differ", oldSize, size)
+			}
+		}
+	}
+
+	// Historical roots come from stored nodes
+	for size := 0; size <= maxSize; size++ {
+		root, err := incremental.RootAt(size)
+		if err != nil {
+			t.Fatalf("RootAt(%d) failed: %v", size, err)
+		}
+		if !bytes.Equal(root, roots[size]) {
+			t.Errorf("RootAt(%d): expected %x, got %x", size, roots[size], root)
+		}
+	}
+
+	if _, err := incremental.RootAt(maxSize + 1); err == nil {
+		t.Error("Expected error for size beyond the tree")
+	}
+}
+
+func TestOpenIncrementalTree(t *testing.T) {
+	hasher := merkle.Hasher{Algorithm: hash.SHA256, Scheme: merkle.DefaultHashScheme}
+	store := merkle.NewMemoryNodeStore()
+
+	tree, err := merkle.OpenIncrementalTree(hasher, store, 0)
+	if err != nil {
+		t.Fatalf("Failed to open tree: %v", err)
+	}
+	for i := 0; i < 13; i++ {
+		if err := tree.Append([]byte{byte(i)}); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+
+	// Reopening from the store restores the frontier and keeps appending
+	reopened, err := merkle.OpenIncrementalTree(hasher, store, 13)
+	if err != nil {
+		t.Fatalf("Failed to reopen tree: %v", err)
+	}
+	if !bytes.Equal(reopened.RootHash(), tree.RootHash()) {
+		t.Fatal("Reopened tree has a different root")
+	}
+
+	reference := merkle.NewTree(hash.SHA256)
+	for i := 0; i < 14; i++ {
+		if err := reference.Append([]byte{byte(i)}); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+	if err := reopened.Append([]byte{13}); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	if !bytes.Equal(reopened.RootHash(), reference.RootHash()) {
+		t.Error("Reopened tree diverged from the reference after appending")
+	}
+
+	if _, err := merkle.OpenIncrementalTree(hasher, store, 100); err == nil {
+		t.Error("Expected error opening a tree larger than the store")
+	}
+}
+
+func BenchmarkIncrementalTreeAppend(b *testing.B) {
+	tree := merkle.NewIncrementalTree(hash.SHA256)
+	data := make([]byte, 32)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data[0], data[1], data[2] = byte(i), byte(i>>8), byte(i>>16)
+		if err := tree.Append(data); err != nil {
+			b.Fatalf("Failed to append: %v", err)
+		}
+	}
+}
+
+func equalPaths(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMultiInclusionProof(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for size := 1; size <= 40; size++ {
+		tree := merkle.NewIncrementalTree(hash.SHA256)
+		for i := 0; i < size; i++ {
+			if err := tree.Append([]byte{byte(i)}); err != nil {
+				t.Fatalf("Failed to append: %v", err)
+			}
+		}
+		root := tree.RootHash()
+
+		for trial := 0; trial < 5; trial++ {
+			indices := rng.Perm(size)[:1+rng.Intn(size)]
+
+			proof, err := tree.GenerateMultiInclusionProof(indices)
+			if err != nil {
+				t.Fatalf("Failed to generate proof: %v", err)
+			}
+
+			valid, err := merkle.VerifyMultiInclusion(tree.Hasher(), proof.LeafIndices, proof.LeafHashes, size, proof.Path, root)
+			if err != nil {
+				t.Fatalf("size %d indices %v: verification error: %v", size, proof.LeafIndices, err)
+			}
+			if !valid {
+				t.Errorf("size %d indices %v: proof did not verify", size, proof.LeafIndices)
+			}
+
+			// Never larger than the single-leaf proofs it replaces
+			total := 0
+			for _, index := range proof.LeafIndices {
+				single, err := tree.GenerateInclusionProof(index)
+				if err != nil {
+					t.Fatalf("Failed to generate proof: %v", err)
+				}
+				total += len(single.Path)
+			}
+			if len(proof.Path) > total {
+				t.Errorf("size %d: multiproof has %d hashes, single proofs %d", size, len(proof.Path), total)
+			}
+		}
+	}
+}
+
+func TestMultiInclusionProofRejectsTampering(t *testing.T) {
+	tree := merkle.NewIncrementalTree(hash.SHA256)
+	for i := 0; i < 100; i++ {
+		if err := tree.Append([]byte{byte(i)}); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+	root := tree.RootHash()
+	hasher := tree.Hasher()
+
+	// Duplicate and unsorted indices are normalized
+	proof, err := tree.GenerateMultiInclusionProof([]int{70, 3, 4, 70, 99})
+	if err != nil {
+		t.Fatalf("Failed to generate proof: %v", err)
+	}
+	if len(proof.LeafIndices) != 4 {
+		t.Fatalf("Expected 4 unique indices, got %v", proof.LeafIndices)
+	}
+
+	// Swapping leaf hashes between indices fails
+	swapped := [][]byte{proof.LeafHashes[1], proof.LeafHashes[0], proof.LeafHashes[2], proof.LeafHashes[3]}
+	if valid, _ := merkle.VerifyMultiInclusion(hasher, proof.LeafIndices, swapped, 100, proof.Path, root); valid {
+		t.Error("Proof should not verify with swapped leaf hashes")
+	}
+
+	// Claiming a different index fails
+	moved := []int{3, 4, 71, 99}
+	if valid, _ := merkle.VerifyMultiInclusion(hasher, moved, proof.LeafHashes, 100, proof.Path, root); valid {
+		t.Error("Proof should not verify for different indices")
+	}
+
+	// Malformed inputs are errors
+	if _, err := merkle.VerifyMultiInclusion(hasher, proof.LeafIndices, proof.LeafHashes, 100, proof.Path[1:], root); err == nil {
+		t.Error("Expected error for short path")
+	}
+	if _, err := merkle.VerifyMultiInclusion(hasher, proof.LeafIndices, proof.LeafHashes, 100, append(proof.Path, root), root); err == nil {
+		t.Error("Expected error for long path")
+	}
+	if _, err := merkle.VerifyMultiInclusion(hasher, []int{4, 3, 70, 99}, proof.LeafHashes, 100, proof.Path, root); err == nil {
+		t.Error("Expected error for unsorted indices")
+	}
+	if _, err := tree.GenerateMultiInclusionProof(nil); err == nil {
+		t.Error("Expected error for empty index set")
+	}
+
+	// A duplicated index cannot smuggle in an unchecked leaf hash
+	single, err := tree.GenerateInclusionProof(3)
+	if err != nil {
+		t.Fatalf("Failed to generate proof: %v", err)
+	}
+	forged := [][]byte{proof.LeafHashes[0], make([]byte, 32)}
+	if valid, err := merkle.VerifyMultiInclusion(hasher, []int{3, 3}, forged, 100, single.Path, root); err == nil || valid {
+		t.Errorf("Expected error for duplicate indices, got valid=%v", valid)
+	}
+	if _, err := tree.GenerateMultiInclusionProof([]int{100}); err == nil {
+		t.Error("Expected error for out of range index")
+	}
+}
@@ -0,0 +1,59 @@
+//go:build pkcs11
+
+package unit
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+	"github.com/IAmSoThirsty/civic-attest/internal/signer/backend"
+)
+
+// TestPKCS11Backend runs against SoftHSM. Set it up with
+//
+//	softhsm2-util --init-token --free --label civic-test --pin 1234 --so-pin 1234
+//	PKCS11_TEST_MODULE=/usr/lib/softhsm/libsofthsm2.so PKCS11_TEST_TOKEN=civic-test \
+//	PKCS11_TEST_PIN=1234 go test -tags pkcs11 ./tests/unit/
+func TestPKCS11Backend(t *testing.T) {
+	module := os.Getenv("PKCS11_TEST_MODULE")
+	if module == "" {
+		t.Skip("PKCS11_TEST_MODULE not set")
+	}
+
+	for _, algo := range []signatures.Algorithm{signatures.Ed25519, signatures.Ed448, signatures.ECDSAP256, signatures.ECDSAP384} {
+		t.Run(string(algo), func(t *testing.T) {
+			cfg := &backend.PKCS11Config{
+				Module: module,
+				Token:  os.Getenv("PKCS11_TEST_TOKEN"),
+				Object: fmt.Sprintf("test-%s-%d", algo, time.Now().UnixNano()),
+				PIN:    os.Getenv("PKCS11_TEST_PIN"),
+			}
+
+			generated, err := backend.GeneratePKCS11(cfg, algo)
+			if err != nil {
+				t.Fatalf("Failed to generate key: %v", err)
+			}
+			defer generated.Close()
+			testSign(t, generated)
+
+			if _, err := backend.GeneratePKCS11(cfg, algo); err == nil {
+				t.Error("Expected error when generating a key with an existing label")
+			}
+
+			opened, err := backend.OpenPKCS11(cfg)
+			if err != nil {
+				t.Fatalf("Failed to open key: %v", err)
+			}
+			defer opened.Close()
+
+			if opened.Algorithm() != algo || !bytes.Equal(opened.PublicKey(), generated.PublicKey()) {
+				t.Error("Opened key does not match generated key")
+			}
+			testSign(t, opened)
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package unit
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+	"github.com/IAmSoThirsty/civic-attest/internal/identity/models"
+	"github.com/IAmSoThirsty/civic-attest/internal/identity/pki"
+)
+
+var (
+	testNotBefore = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	testNotAfter  = time.Date(2028, 1, 1, 0, 0, 0, 0, time.UTC)
+	testNow       = time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// testCA is a certificate and its key
+type testCA struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// newCA creates a CA certificate, self-signed if parent is nil
+func newCA(t *testing.T, name string, parent *testCA) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             testNotBefore,
+		NotAfter:              testNotAfter,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	issuer, issuerKey := template, crypto.Signer(key)
+	if parent != nil {
+		issuer, issuerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), issuerKey)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+// issue certifies a public key under ca and returns the chain up to the root
+func issue(t *testing.T, ca *testCA, publicKey crypto.PublicKey, usage x509.KeyUsage, chain ...*x509.Certificate) [][]byte {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "Mayor of Springfield"},
+		NotBefore:    testNotBefore,
+		NotAfter:     testNotAfter,
+		KeyUsage:     usage,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, publicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create leaf certificate: %v", err)
+	}
+	result := [][]byte{der}
+	for _, cert := range chain {
+		result = append(result, cert.Raw)
+	}
+	return result
+}
+
+// identityFor creates an identity for a key pair bound to chain
+func identityFor(kp *signatures.KeyPair, chain [][]byte) *models.Identity {
+	return &models.Identity{
+		PublicKey:        kp.PublicKey,
+		KeyAlgorithm:     string(kp.Algorithm),
+		IdentityID:       "mayor-springfield-v1",
+		CertificateChain: chain,
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	root := newCA(t, "Government Root CA", nil)
+	intermediate := newCA(t, "Agency Issuing CA", root)
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+
+	kp, err := signatures.GenerateKeyPair(signatures.ECDSAP256)
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	priv, _ := x509.ParsePKCS8PrivateKey(mustMarshal(t, kp))
+	ecKey := priv.(*ecdsa.PrivateKey)
+
+	usage := x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment
+	chain := issue(t, intermediate, ecKey.Public(), usage, intermediate.cert)
+	identity := identityFor(kp, chain)
+
+	if err := pki.VerifyChain(identity, roots, testNow); err != nil {
+		t.Fatalf("Valid chain rejected: %v", err)
+	}
+
+	publicKey, algo, err := pki.LeafPublicKey(chain)
+	if err != nil || algo != signatures.ECDSAP256 || string(publicKey) != string(kp.PublicKey) {
+		t.Errorf("Leaf public key does not match (algo=%s err=%v)", algo, err)
+	}
+
+	// An Ed25519 identity key can be certified too
+	edKP, _ := signatures.GenerateKeyPair(signatures.Ed25519)
+	edChain := issue(t, intermediate, ed25519.PublicKey(edKP.PublicKey), usage, intermediate.cert)
+	if err := pki.VerifyChain(identityFor(edKP, edChain), roots, testNow); err != nil {
+		t.Errorf("Valid Ed25519 chain rejected: %v", err)
+	}
+
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(newCA(t, "Other Root CA", nil).cert)
+	other, _ := signatures.GenerateKeyPair(signatures.ECDSAP256)
+
+	tests := []struct {
+		name     string
+		identity *models.Identity
+		roots    *x509.CertPool
+		at       time.Time
+		wantErr  string
+	}{
+		{"untrusted root", identity, otherRoots, testNow, "failed to verify"},
+		{"expired", identity, roots, testNotAfter.AddDate(0, 0, 1), "failed to verify"},
+		{"missing intermediate", identityFor(kp, chain[:1]), roots, testNow, "failed to verify"},
+		{"different key", identityFor(other, chain), roots, testNow, "does not certify"},
+		{"no digital signature usage", identityFor(kp, issue(t, intermediate, ecKey.Public(), x509.KeyUsageKeyAgreement, intermediate.cert)), roots, testNow, "digital signatures"},
+		{"empty chain", identityFor(kp, nil), roots, testNow, "empty"},
+	}
+	for _, tt := range tests {
+		err := pki.VerifyChain(tt.identity, tt.roots, tt.at)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestParseChainPEM(t *testing.T) {
+	root := newCA(t, "Government Root CA", nil)
+	intermediate := newCA(t, "Agency Issuing CA", root)
+
+	var data []byte
+	for _, cert := range []*x509.Certificate{intermediate.cert, root.cert} {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+
+	chain, err := pki.ParseChainPEM(data)
+	if err != nil {
+		t.Fatalf("Failed to parse chain: %v", err)
+	}
+	if len(chain) != 2 || string(chain[0]) != string(intermediate.cert.Raw) {
+		t.Error("Chain is not in file order")
+	}
+
+	if _, err := pki.LoadRoots(data); err != nil {
+		t.Errorf("Failed to load roots: %v", err)
+	}
+	if _, err := pki.LoadRoots([]byte("no certificates")); err == nil {
+		t.Error("Expected error for a root bundle without certificates")
+	}
+	if _, err := pki.ParseChainPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{0}})); err == nil {
+		t.Error("Expected error for a non-certificate PEM block")
+	}
+}
+
+func mustMarshal(t *testing.T, kp *signatures.KeyPair) []byte {
+	t.Helper()
+	der, err := signatures.MarshalPrivateKey(kp.PrivateKey, kp.Algorithm)
+	if err != nil {
+		t.Fatalf("Failed to encode private key: %v", err)
+	}
+	return der
+}
@@ -0,0 +1,146 @@
+package unit
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/shamir"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("ceremony key material, 48 bytes of PKCS#8 DER..")
+
+	shares, err := shamir.Split(secret, 3, 5)
+	if err != nil {
+		t.Fatalf("Failed to split: %v", err)
+	}
+	if len(shares) != 5 {
+		t.Fatalf("Expected 5 shares, got %d", len(shares))
+	}
+
+	// Every subset of at least the threshold recovers the secret
+	for mask := 0; mask < 1<<5; mask++ {
+		var subset []shamir.Share
+		for i := range shares {
+			if mask&(1<<i) != 0 {
+				subset = append(subset, shares[i])
+			}
+		}
+		if len(subset) < 2 {
+			continue
+		}
+
+		recovered, err := shamir.Combine(subset)
+		if err != nil {
+			t.Fatalf("Failed to combine %d shares: %v", len(subset), err)
+		}
+		if got := bytes.Equal(recovered, secret); got != (len(subset) >= 3) {
+			t.Errorf("Combining %d shares (mask %05b): recovered secret = %v", len(subset), mask, got)
+		}
+	}
+}
+
+func TestCombineEveryIndexDifference(t *testing.T) {
+	secret := []byte("ceremony key material")
+
+	shares, err := shamir.Split(secret, 2, shamir.MaxShares)
+	if err != nil {
+		t.Fatalf("Failed to split: %v", err)
+	}
+
+	// Index differences are XORs, so pairing share 1 with every other share
+	// and shares 2 and 3 divides by every non-zero field element
+	pairs := [][2]int{{1, 2}}
+	for i := 1; i < len(shares); i++ {
+		pairs = append(pairs, [2]int{0, i})
+	}
+	for _, p := range pairs {
+		recovered, err := shamir.Combine([]shamir.Share{shares[p[0]], shares[p[1]]})
+		if err != nil {
+			t.Fatalf("Failed to combine shares %d and %d: %v", shares[p[0]].Index, shares[p[1]].Index, err)
+		}
+		if !bytes.Equal(recovered, secret) {
+			t.Errorf("Shares %d and %d recovered %x", shares[p[0]].Index, shares[p[1]].Index, recovered)
+		}
+	}
+}
+
+func TestCombineRejectsMalformedShares(t *testing.T) {
+	shares, err := shamir.Split([]byte("secret"), 2, 3)
+	if err != nil {
+		t.Fatalf("Failed to split: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		shares []shamir.Share
+	}{
+		{"single share", shares[:1]},
+		{"duplicate index", []shamir.Share{shares[0], shares[0]}},
+		{"zero index", []shamir.Share{shares[0], {Index: 0, Value: shares[1].Value}}},
+		{"length mismatch", []shamir.Share{shares[0], {Index: 2, Value: []byte("x")}}},
+	}
+	for _, tt := range tests {
+		if _, err := shamir.Combine(tt.shares); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+
+	if _, err := shamir.Split([]byte("secret"), 1, 3); err == nil {
+		t.Error("Expected error for threshold below 2")
+	}
+	if _, err := shamir.Split([]byte("secret"), 4, 3); err == nil {
+		t.Error("Expected error for threshold above share count")
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	shares, err := shamir.Split([]byte("secret"), 2, 2)
+	if err != nil {
+		t.Fatalf("Failed to split: %v", err)
+	}
+	priv, pub, err := shamir.GenerateTrusteeKey()
+	if err != nil {
+		t.Fatalf("Failed to generate trustee key: %v", err)
+	}
+
+	sealed, err := shamir.Seal(shares[1], pub)
+	if err != nil {
+		t.Fatalf("Failed to seal: %v", err)
+	}
+	if bytes.Contains(sealed.Ciphertext, shares[1].Value) {
+		t.Error("Sealed share contains the share in the clear")
+	}
+
+	opened, err := shamir.Open(sealed, priv)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	if opened.Index != shares[1].Index || !bytes.Equal(opened.Value, shares[1].Value) {
+		t.Error("Opened share does not match")
+	}
+	if !bytes.Equal(shamir.Commit(opened), shamir.Commit(shares[1])) {
+		t.Error("Commitment of opened share does not match")
+	}
+
+	// Another trustee's key cannot open it
+	other, _, _ := shamir.GenerateTrusteeKey()
+	if _, err := shamir.Open(sealed, other); err == nil {
+		t.Error("Expected error opening with the wrong key")
+	}
+
+	// The index is authenticated
+	moved := *sealed
+	moved.Index = 1
+	if _, err := shamir.Open(&moved, priv); err == nil {
+		t.Error("Expected error for a share moved to another index")
+	}
+}
+
+func TestCommitBindsIndex(t *testing.T) {
+	a := shamir.Commit(shamir.Share{Index: 1, Value: []byte{1, 2, 3}})
+	b := shamir.Commit(shamir.Share{Index: 2, Value: []byte{1, 2, 3}})
+	if bytes.Equal(a, b) {
+		t.Error("Commitment does not bind the share index")
+	}
+}
@@ -0,0 +1,261 @@
+package unit
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/smt"
+)
+
+func mustKey(t *testing.T, algo hash.Algorithm, id string) []byte {
+	t.Helper()
+	key, err := smt.KeyFor(algo, id)
+	if err != nil {
+		t.Fatalf("KeyFor failed: %v", err)
+	}
+	return key
+}
+
+func mustValue(t *testing.T, algo hash.Algorithm, data string) []byte {
+	t.Helper()
+	value, err := hash.Hash([]byte(data), algo)
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	return value
+}
+
+// smtReferenceRoot recomputes the root of a sparse Merkle tree from scratch by
+// splitting the key set bit by bit: leaves hash 0x00 || key || value, nodes
+// 0x01 || left || right, and empty subtrees are all zero bytes
+func smtReferenceRoot(t *testing.T, tree *smt.Tree, algo hash.Algorithm, keys [][]byte, height int) []byte {
+	t.Helper()
+	empty := make([]byte, len(mustValue(t, algo, "")))
+	if len(keys) == 0 {
+		return empty
+	}
+	if height == 0 {
+		value, err := tree.Get(keys[0])
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		return mustHash(t, algo, append(append([]byte{0x00}, keys[0]...), value...))
+	}
+
+	var left, right [][]byte
+	depth := 8 * len(keys[0])
+	for _, k := range keys {
+		i := depth - height
+		if (k[i/8]>>(7-i%8))&1 == 0 {
+			left = append(left, k)
+		} else {
+			right = append(right, k)
+		}
+	}
+
+	l := smtReferenceRoot(t, tree, algo, left, height-1)
+	r := smtReferenceRoot(t, tree, algo, right, height-1)
+	if bytes.Equal(l, empty) && bytes.Equal(r, empty) {
+		return empty
+	}
+	return mustHash(t, algo, append(append([]byte{0x01}, l...), r...))
+}
+
+func mustHash(t *testing.T, algo hash.Algorithm, data []byte) []byte {
+	t.Helper()
+	h, err := hash.Hash(data, algo)
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	return h
+}
+
+func TestMembershipAndNonMembership(t *testing.T) {
+	for _, algo := range []hash.Algorithm{hash.SHA256, hash.SHA3_512, hash.BLAKE3} {
+		t.Run(string(algo), func(t *testing.T) {
+			tree, err := smt.NewTree(algo)
+			if err != nil {
+				t.Fatalf("Failed to create tree: %v", err)
+			}
+
+			empty := make([]byte, len(mustValue(t, algo, "")))
+			if !bytes.Equal(tree.Root(), empty) {
+				t.Error("Empty tree should have an all-zero root")
+			}
+
+			for i := 0; i < 20; i++ {
+				id := fmt.Sprintf("office-%d-v1", i)
+				if err := tree.Set(mustKey(t, algo, id), mustValue(t, algo, id)); err != nil {
+					t.Fatalf("Failed to set: %v", err)
+				}
+			}
+
+			root := tree.Root()
+			if !bytes.Equal(root, smtReferenceRoot(t, tree, algo, tree.Keys(), 8*len(empty))) {
+				t.Fatal("Root does not match reference computation")
+			}
+
+			// Membership
+			key := mustKey(t, algo, "office-7-v1")
+			proof, err := tree.Prove(key)
+			if err != nil {
+				t.Fatalf("Failed to prove: %v", err)
+			}
+			valid, err := smt.VerifyMembership(algo, root, key, mustValue(t, algo, "office-7-v1"), proof)
+			if err != nil || !valid {
+				t.Errorf("Membership proof did not verify (err=%v)", err)
+			}
+			if valid, _ := smt.VerifyNonMembership(algo, root, key, proof); valid {
+				t.Error("Membership proof must not prove absence")
+			}
+
+			// Non-membership
+			absent := mustKey(t, algo, "revoked-office-v1")
+			proof, err = tree.Prove(absent)
+			if err != nil {
+				t.Fatalf("Failed to prove: %v", err)
+			}
+			valid, err = smt.VerifyNonMembership(algo, root, absent, proof)
+			if err != nil || !valid {
+				t.Errorf("Non-membership proof did not verify (err=%v)", err)
+			}
+
+			// Proofs are compact: only non-empty siblings are carried
+			if len(proof.Siblings) > 20 {
+				t.Errorf("Expected a compact proof, got %d siblings", len(proof.Siblings))
+			}
+
+			// A non-membership proof does not hold once the key is added
+			if err := tree.Set(absent, mustValue(t, algo, "revoked")); err != nil {
+				t.Fatalf("Failed to set: %v", err)
+			}
+			valid, err = smt.VerifyNonMembership(algo, tree.Root(), absent, proof)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if valid {
+				t.Error("Stale non-membership proof should not verify against the new root")
+			}
+		})
+	}
+}
+
+func TestBatchMatchesSequential(t *testing.T) {
+	sequential, err := smt.NewTree(hash.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to create tree: %v", err)
+	}
+	batched, err := smt.NewTree(hash.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to create tree: %v", err)
+	}
+
+	updates := make([]smt.Update, 0)
+	for i := 0; i < 100; i++ {
+		id := fmt.Sprintf("identity-%d", i)
+		updates = append(updates, smt.Update{Key: mustKey(t, hash.SHA256, id), Value: mustValue(t, hash.SHA256, id)})
+	}
+	// Remove a few again, and overwrite one
+	updates = append(updates,
+		smt.Update{Key: mustKey(t, hash.SHA256, "identity-3")},
+		smt.Update{Key: mustKey(t, hash.SHA256, "identity-50")},
+		smt.Update{Key: mustKey(t, hash.SHA256, "identity-9"), Value: mustValue(t, hash.SHA256, "rotated")},
+	)
+
+	for _, u := range updates {
+		if err := sequential.Apply([]smt.Update{u}); err != nil {
+			t.Fatalf("Failed to apply: %v", err)
+		}
+	}
+	if err := batched.Apply(updates); err != nil {
+		t.Fatalf("Failed to apply batch: %v", err)
+	}
+
+	if !bytes.Equal(sequential.Root(), batched.Root()) {
+		t.Error("Batched and sequential updates produce different roots")
+	}
+	if batched.Size() != 98 {
+		t.Errorf("Expected 98 keys, got %d", batched.Size())
+	}
+
+	// Deleting every key returns to the empty root, and no stale node is
+	// left behind to show up as a proof sibling
+	for _, key := range batched.Keys() {
+		if err := batched.Delete(key); err != nil {
+			t.Fatalf("Failed to delete: %v", err)
+		}
+	}
+	if !bytes.Equal(batched.Root(), make([]byte, 32)) || batched.Size() != 0 {
+		t.Error("Deleting every key should leave an empty tree")
+	}
+	for _, u := range updates {
+		proof, err := batched.Prove(u.Key)
+		if err != nil || len(proof.Siblings) != 0 {
+			t.Fatalf("Expected an empty proof, got %v (err=%v)", proof, err)
+		}
+	}
+}
+
+func TestVerifyProofRejectsMalformed(t *testing.T) {
+	tree, err := smt.NewTree(hash.SHA256)
+	if err != nil {
+		t.Fatalf("Failed to create tree: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("id-%d", i)
+		if err := tree.Set(mustKey(t, hash.SHA256, id), mustValue(t, hash.SHA256, id)); err != nil {
+			t.Fatalf("Failed to set: %v", err)
+		}
+	}
+
+	key := mustKey(t, hash.SHA256, "id-2")
+	proof, err := tree.Prove(key)
+	if err != nil {
+		t.Fatalf("Failed to prove: %v", err)
+	}
+
+	// Tampered sibling
+	tampered := *proof
+	tampered.Siblings = append([][]byte(nil), proof.Siblings...)
+	tampered.Siblings[0] = append([]byte(nil), proof.Siblings[0]...)
+	tampered.Siblings[0][0] ^= 1
+	if valid, _ := smt.VerifyProof(hash.SHA256, tree.Root(), &tampered); valid {
+		t.Error("Tampered proof should not verify")
+	}
+
+	// Missing sibling
+	tampered = *proof
+	tampered.Siblings = proof.Siblings[1:]
+	if _, err := smt.VerifyProof(hash.SHA256, tree.Root(), &tampered); err == nil {
+		t.Error("Expected error for missing sibling")
+	}
+
+	// Claiming a value for the wrong key
+	tampered = *proof
+	tampered.Key = mustKey(t, hash.SHA256, "id-3")
+	if valid, _ := smt.VerifyProof(hash.SHA256, tree.Root(), &tampered); valid {
+		t.Error("Proof should not verify for a different key")
+	}
+
+	if err := tree.Set([]byte("short"), nil); err == nil {
+		t.Error("Expected error for invalid key length")
+	}
+}
+
+func BenchmarkApplyBatch(b *testing.B) {
+	updates := make([]smt.Update, 1000)
+	for i := range updates {
+		key, _ := smt.KeyFor(hash.SHA256, fmt.Sprintf("identity-%d", i))
+		updates[i] = smt.Update{Key: key, Value: key}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree, _ := smt.NewTree(hash.SHA256)
+		if err := tree.Apply(updates); err != nil {
+			b.Fatalf("Failed to apply: %v", err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/merkle"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/signatures"
+	"github.com/IAmSoThirsty/civic-attest/internal/ledger/tiles"
+)
+
+func TestTilePath(t *testing.T) {
+	testCases := []struct {
+		level, index, width int
+		expected            string
+	}{
+		{0, 0, tiles.Width, "tile/0/000"},
+		{0, 0, 5, "tile/0/000.p/5"},
+		{1, 999, tiles.Width, "tile/1/999"},
+		{0, 1000, tiles.Width, "tile/0/x001/000"},
+		{2, 1234067, tiles.Width, "tile/2/x001/x234/067"},
+		{0, 1234067, 8, "tile/0/x001/x234/067.p/8"},
+	}
+
+	for _, tc := range testCases {
+		if got := tiles.TilePath(tc.level, tc.index, tc.width); got != tc.expected {
+			t.Errorf("TilePath(%d, %d, %d): expected %s, got %s", tc.level, tc.index, tc.width, tc.expected, got)
+		}
+	}
+
+	if got := tiles.EntriesPath(1234067, 3); got != "tile/entries/x001/x234/067.p/3" {
+		t.Errorf("Unexpected entries path: %s", got)
+	}
+}
+
+// memorySource adapts an incremental tree and its leaf data to Source
+type memorySource struct {
+	tree   *merkle.IncrementalTree
+	leaves [][]byte
+}
+
+func (s *memorySource) NodeHash(level, index int) ([]byte, error) {
+	return s.tree.NodeHash(level, index)
+}
+
+func (s *memorySource) LeafData(index int) ([]byte, error) {
+	return s.leaves[index], nil
+}
+
+func (s *memorySource) append(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		data := []byte(fmt.Sprintf("entry-%d", len(s.leaves)))
+		if err := s.tree.Append(data); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+		s.leaves = append(s.leaves, data)
+	}
+}
+
+func TestWriteAndReadTiles(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := tiles.NewWriter(dir)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+
+	src := &memorySource{tree: merkle.NewIncrementalTree(hash.SHA256)}
+	hasher := src.tree.Hasher()
+
+	// Grow in uneven steps so partial tiles are written and later completed
+	roots := make(map[int][]byte)
+	for _, step := range []int{1, 6, 250, 300, 1, 66000} {
+		oldSize := src.tree.Size()
+		src.append(t, step)
+		if err := writer.Update(src, oldSize, src.tree.Size()); err != nil {
+			t.Fatalf("Failed to write tiles: %v", err)
+		}
+		roots[src.tree.Size()] = src.tree.RootHash()
+	}
+
+	// Every published size can be reopened from tiles alone
+	for size, root := range roots {
+		reader, err := tiles.NewReader(hasher, tiles.DirFetcher(dir), size)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+
+		tree, err := merkle.OpenIncrementalTree(hasher, reader, size)
+		if err != nil {
+			t.Fatalf("size %d: failed to open tree from tiles: %v", size, err)
+		}
+		if !bytes.Equal(tree.RootHash(), root) {
+			t.Fatalf("size %d: root from tiles differs", size)
+		}
+
+		for _, index := range []int{0, size / 2, size - 1} {
+			proof, err := tree.GenerateInclusionProof(index)
+			if err != nil {
+				t.Fatalf("size %d index %d: failed to generate proof: %v", size, index, err)
+			}
+			valid, err := merkle.VerifyInclusion(hasher, proof.LeafHash, index, size, proof.Path, root)
+			if err != nil || !valid {
+				t.Errorf("size %d index %d: proof from tiles did not verify (err=%v)", size, index, err)
+			}
+		}
+	}
+
+	// The last entry bundle holds the leaf data
+	size := src.tree.Size()
+	last := (size - 1) / tiles.Width
+	data, err := tiles.DirFetcher(dir)(tiles.EntriesPath(last, size-last*tiles.Width))
+	if err != nil {
+		t.Fatalf("Failed to read entry bundle: %v", err)
+	}
+	entries, err := tiles.ParseEntries(data)
+	if err != nil {
+		t.Fatalf("Failed to parse entry bundle: %v", err)
+	}
+	if len(entries) != size-last*tiles.Width || !bytes.Equal(entries[len(entries)-1], src.leaves[size-1]) {
+		t.Error("Entry bundle does not match leaf data")
+	}
+}
+
+func TestReaderRejectsTruncatedTile(t *testing.T) {
+	hasher := merkle.Hasher{Algorithm: hash.SHA256, Scheme: merkle.DefaultHashScheme}
+	fetch := func(path string) ([]byte, error) {
+		return make([]byte, 31), nil
+	}
+
+	reader, err := tiles.NewReader(hasher, fetch, 1)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	if _, err := reader.GetNode(0, 0); err == nil {
+		t.Error("Expected error for truncated tile")
+	}
+	if _, err := reader.GetNode(0, 1); err == nil {
+		t.Error("Expected error for node outside the tree")
+	}
+}
+
+func TestSignedCheckpoint(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	checkpoint := &tiles.Checkpoint{
+		Origin: "example.gov/ledger",
+		Size:   42,
+		Root:   bytes.Repeat([]byte{0xab}, 32),
+	}
+
+	signer, err := signatures.NewKeySigner(priv, signatures.Ed25519)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	note, err := tiles.SignNote(context.Background(), checkpoint.Marshal(), "example.gov/ledger", signer)
+	if err != nil {
+		t.Fatalf("Failed to sign note: %v", err)
+	}
+
+	body, err := tiles.OpenNote(note, "example.gov/ledger", pub)
+	if err != nil {
+		t.Fatalf("Failed to open note: %v", err)
+	}
+
+	parsed, err := tiles.ParseCheckpoint(body)
+	if err != nil {
+		t.Fatalf("Failed to parse checkpoint: %v", err)
+	}
+	if parsed.Origin != checkpoint.Origin || parsed.Size != checkpoint.Size || !bytes.Equal(parsed.Root, checkpoint.Root) {
+		t.Errorf("Checkpoint round trip mismatch: %+v", parsed)
+	}
+
+	// Tampering with the body invalidates the signature
+	tampered := bytes.Replace(note, []byte("\n42\n"), []byte("\n43\n"), 1)
+	if _, err := tiles.OpenNote(tampered, "example.gov/ledger", pub); err == nil {
+		t.Error("Expected error for tampered checkpoint")
+	}
+
+	// A different key is not accepted
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err := tiles.OpenNote(note, "example.gov/ledger", otherPub); err == nil {
+		t.Error("Expected error for unknown key")
+	}
+
+	if _, err := tiles.ParseCheckpoint([]byte("origin\n01\nAAAA\n")); err == nil {
+		t.Error("Expected error for non-canonical tree size")
+	}
+}
@@ -0,0 +1,43 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/hash"
+	"github.com/IAmSoThirsty/civic-attest/internal/crypto/timestamp"
+)
+
+func TestTimestampTokenRoundTrip(t *testing.T) {
+	imprint, err := hash.Sum([]byte("signed content"), hash.SHA384)
+	if err != nil {
+		t.Fatalf("Failed to hash: %v", err)
+	}
+	token, err := timestamp.NewMockTSAClient().Request(imprint)
+	if err != nil {
+		t.Fatalf("Failed to request timestamp: %v", err)
+	}
+	data, err := token.Encode()
+	if err != nil {
+		t.Fatalf("Failed to encode token: %v", err)
+	}
+
+	decoded, err := timestamp.Decode(data)
+	if err != nil {
+		t.Fatalf("Failed to decode token: %v", err)
+	}
+	// DER times have second precision
+	if !decoded.GenTime.Equal(token.GenTime.Truncate(time.Second)) || decoded.SerialNumber != token.SerialNumber || decoded.TSA != token.TSA {
+		t.Errorf("Decoded token %+v does not match %+v", decoded, token)
+	}
+	if !decoded.Verify(imprint) {
+		t.Error("Decoded token does not verify its imprint")
+	}
+
+	if _, err := timestamp.Decode(append(data, 0)); err == nil {
+		t.Error("Expected error for trailing data")
+	}
+	if _, err := timestamp.Decode(data[:len(data)-1]); err == nil {
+		t.Error("Expected error for truncated token")
+	}
+}